@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKeyPrefix — начало каждого ключа API; по нему ключ легко найти в коде и журналах
+// сканерами секретов
+const APIKeyPrefix = "gbk_"
+
+const (
+	// keyIDBytes — длина случайной открытой части ключа
+	keyIDBytes = 6
+	// keySecretBytes — длина секретной части ключа
+	keySecretBytes = 32
+)
+
+// GenerateAPIKey создает новый ключ вида "gbk_<id>_<секрет>". Возвращает ключ целиком (его
+// нужно один раз показать клиенту), открытую часть "gbk_<id>" для поиска в хранилище и хэш
+// для сохранения.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	id := make([]byte, keyIDBytes)
+	secret := make([]byte, keySecretBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", "", fmt.Errorf("auth.GenerateAPIKey: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", fmt.Errorf("auth.GenerateAPIKey: %w", err)
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(id)
+	key = prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// ParseAPIKey возвращает открытую часть ключа или false, если строка не похожа на ключ API
+func ParseAPIKey(key string) (prefix string, ok bool) {
+	rest, found := strings.CutPrefix(key, APIKeyPrefix)
+	if !found {
+		return "", false
+	}
+	id, secret, found := strings.Cut(rest, "_")
+	if !found || len(id) != 2*keyIDBytes || secret == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", false
+	}
+	return APIKeyPrefix + id, true
+}
+
+// HashAPIKey возвращает SHA-256 ключа в hex. Ключ содержит 256 случайных бит, поэтому
+// медленный хэш, как для паролей, не нужен.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAPIKey сравнивает хэш ключа с сохраненным за постоянное время
+func VerifyAPIKey(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,114 @@
+// Package auth проверяет, кто обращается к API и что ему разрешено.
+//
+// Клиенты API передают ключ в заголовке "Authorization: Bearer <ключ>" или "X-API-Key".
+// Вместо ключа в Authorization можно передать JWT провайдера OIDC: JWTVerifier проверяет
+// его подпись по ключам из JWKS (JWKSCache) и переводит роли из claims в области доступа.
+// Фронтенд получает cookie сессии в обмен на ключ (SessionCodec) и дальше обходится без
+// заголовков. Пользователи сервиса входят по email и паролю (PasswordAuth) и получают
+// cookie сессии, которая хранится на сервере и может быть отозвана. Authenticator находит по учетным данным Principal — субъект запроса с его
+// областями доступа (Scope*), которые обработчики сверяют с требованиями маршрута.
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// Области доступа ключей API
+const (
+	// ScopeUsersRead — чтение пользователей, их истории и версий
+	ScopeUsersRead = "users:read"
+	// ScopeUsersWrite — создание, изменение, удаление и восстановление пользователей
+	ScopeUsersWrite = "users:write"
+	// ScopeUsersAdmin — окончательное удаление пользователей в обход корзины
+	ScopeUsersAdmin = "users:admin"
+	// ScopeAuditRead — чтение журнала аудита
+	ScopeAuditRead = "audit:read"
+	// ScopeKeysAdmin — управление ключами API
+	ScopeKeysAdmin = "keys:admin"
+)
+
+// Scopes перечисляет все известные области доступа
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeUsersAdmin, ScopeAuditRead, ScopeKeysAdmin}
+
+// KnownScope сообщает, существует ли область доступа scope
+func KnownScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Способы, которыми субъект подтвердил свою личность
+const (
+	// ViaAPIKey — ключ API в заголовке запроса
+	ViaAPIKey = "api_key"
+	// ViaSession — cookie сессии фронтенда
+	ViaSession = "session"
+	// ViaJWT — токен провайдера OIDC в заголовке Authorization
+	ViaJWT = "jwt"
+	// ViaUserSession — cookie сессии пользователя, вошедшего по паролю
+	ViaUserSession = "user_session"
+)
+
+// Principal — субъект запроса: ключ API, пользователь провайдера OIDC или пользователь
+// сервиса, вошедший по паролю
+type Principal struct {
+	// KeyID и Name — ID и имя ключа API. Для JWT KeyID равен 0, а Name — имя пользователя из токена.
+	KeyID int64
+	Name  string
+	// Subject и Roles — claim sub и роли пользователя из JWT
+	Subject string
+	Roles   []string
+	// UserID и SessionID — пользователь сервиса и его сессия; только для ViaUserSession
+	UserID    int64
+	SessionID int64
+	// Scopes — области доступа ключа или ролей пользователя
+	Scopes []string
+	// Via — как были переданы учетные данные: ViaAPIKey, ViaSession, ViaJWT
+	// или ViaUserSession
+	Via string
+}
+
+// HasScope сообщает, разрешена ли субъекту область доступа scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor возвращает имя субъекта для журнала аудита, например "api_key:3:billing",
+// "jwt:<sub>:alice" или "user:42"
+func (p *Principal) Actor() string {
+	switch p.Via {
+	case ViaJWT:
+		return fmt.Sprintf("%s:%s:%s", ViaJWT, p.Subject, p.Name)
+	case ViaUserSession:
+		return fmt.Sprintf("user:%d", p.UserID)
+	}
+	return fmt.Sprintf("%s:%d:%s", ViaAPIKey, p.KeyID, p.Name)
+}
+
+// FromCookie сообщает, что учетные данные пришли в cookie. Такие запросы браузер
+// отправляет сам, поэтому изменяющие запросы нужно проверять на CSRF.
+func (p *Principal) FromCookie() bool {
+	return p.Via == ViaSession || p.Via == ViaUserSession
+}
+
+type principalKey struct{}
+
+// WithPrincipal возвращает контекст с субъектом запроса
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext возвращает субъекта, сохраненного WithPrincipal, или nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
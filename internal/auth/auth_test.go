@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+func TestAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey вернул ошибку: %v", err)
+	}
+	if !strings.HasPrefix(key, prefix+"_") || !strings.HasPrefix(prefix, APIKeyPrefix) {
+		t.Errorf("ключ %q не начинается с открытой части %q", key, prefix)
+	}
+	if parsed, ok := ParseAPIKey(key); !ok || parsed != prefix {
+		t.Errorf("ParseAPIKey: ожидалось %q, получено %q (ok=%v)", prefix, parsed, ok)
+	}
+	if !VerifyAPIKey(key, hash) {
+		t.Error("VerifyAPIKey не принял собственный ключ")
+	}
+	if VerifyAPIKey(key+"x", hash) {
+		t.Error("VerifyAPIKey принял измененный ключ")
+	}
+
+	other, _, _, _ := GenerateAPIKey()
+	if other == key {
+		t.Error("два вызова GenerateAPIKey вернули один ключ")
+	}
+
+	for _, invalid := range []string{"", "gbk_", "gbk_0123456789ab", "gbk_0123456789ab_", "gbk_xyz_secret", "gbk_0123456789zz_secret", "token"} {
+		if _, ok := ParseAPIKey(invalid); ok {
+			t.Errorf("ParseAPIKey принял некорректный ключ %q", invalid)
+		}
+	}
+}
+
+func TestSessionCodec(t *testing.T) {
+	if _, err := NewSessionCodec([]byte("short"), time.Hour); err == nil {
+		t.Error("NewSessionCodec должен отклонять короткий ключ подписи")
+	}
+	codec, err := NewSessionCodec(nil, 0)
+	if err != nil {
+		t.Fatalf("NewSessionCodec вернул ошибку: %v", err)
+	}
+	if codec.TTL != DefaultSessionTTL {
+		t.Errorf("TTL по умолчанию: ожидалось %s, получено %s", DefaultSessionTTL, codec.TTL)
+	}
+
+	now := time.Now()
+	key := &models.APIKey{ID: 7, Prefix: "gbk_0123456789ab"}
+	value := codec.Encode(key, now.Add(time.Hour))
+
+	id, err := codec.Decode(value, now)
+	if err != nil || id != 7 {
+		t.Fatalf("Decode: ожидался ID 7, получено %d (ошибка %v)", id, err)
+	}
+	if !codec.Verify(value, key) {
+		t.Error("Verify не принял собственную сессию")
+	}
+	if _, err := codec.Decode(value, now.Add(2*time.Hour)); err == nil {
+		t.Error("Decode принял истекшую сессию")
+	}
+	if codec.Verify(value, &models.APIKey{ID: 7, Prefix: "gbk_ba9876543210"}) {
+		t.Error("Verify принял сессию после ротации ключа")
+	}
+	if codec.Verify(strings.Replace(value, "7.", "8.", 1), key) {
+		t.Error("Verify принял сессию с подмененным ID")
+	}
+	other, _ := NewSessionCodec(nil, 0)
+	if other.Verify(value, key) {
+		t.Error("Verify принял сессию, подписанную другим ключом")
+	}
+}
+
+// newTestKey сохраняет в хранилище ключ с областями доступа scopes и возвращает его значение
+func newTestKey(t *testing.T, keys *storage.MockAPIKeyStorage, scopes ...string) (string, *models.APIKey) {
+	t.Helper()
+	secret, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey вернул ошибку: %v", err)
+	}
+	key := &models.APIKey{Name: "test", Prefix: prefix, Hash: hash, Scopes: scopes}
+	if err := keys.CreateAPIKey(context.Background(), key); err != nil {
+		t.Fatalf("CreateAPIKey вернул ошибку: %v", err)
+	}
+	return secret, key
+}
+
+func TestAuthenticator(t *testing.T) {
+	keys := storage.NewMockAPIKeyStorage()
+	sessions, _ := NewSessionCodec(nil, time.Hour)
+	a := NewAuthenticator(keys, sessions)
+	secret, key := newTestKey(t, keys, ScopeUsersRead)
+
+	request := func(header, value string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return req
+	}
+
+	t.Run("Bearer и X-API-Key", func(t *testing.T) {
+		for _, req := range []*http.Request{request("Authorization", "Bearer "+secret), request(APIKeyHeader, secret)} {
+			p, err := a.Authenticate(req)
+			if err != nil {
+				t.Fatalf("Authenticate вернул ошибку: %v", err)
+			}
+			if p.KeyID != key.ID || p.Via != ViaAPIKey || !p.HasScope(ScopeUsersRead) || p.HasScope(ScopeUsersWrite) {
+				t.Errorf("неверный субъект: %+v", p)
+			}
+		}
+	})
+
+	t.Run("Отмечается время использования", func(t *testing.T) {
+		stored, _ := keys.GetAPIKey(context.Background(), key.ID)
+		if stored.LastUsedAt == nil {
+			t.Error("last_used_at не заполнен после успешной проверки")
+		}
+	})
+
+	t.Run("Ошибки учетных данных", func(t *testing.T) {
+		testCases := map[string]struct {
+			req      *http.Request
+			expected error
+		}{
+			"Без учетных данных":  {request("", ""), ErrNoCredentials},
+			"Схема Basic":         {request("Authorization", "Basic dXNlcjpwYXNz"), ErrInvalidCredentials},
+			"Неизвестный формат":  {request("Authorization", "Bearer token"), ErrInvalidCredentials},
+			"Неверный секрет":     {request(APIKeyHeader, secret+"x"), ErrInvalidCredentials},
+			"Неизвестный ключ":    {request(APIKeyHeader, "gbk_0123456789ab_secret"), ErrInvalidCredentials},
+			"Поврежденная сессия": {withCookie(request("", ""), "1.2.3"), ErrInvalidCredentials},
+		}
+		for name, tc := range testCases {
+			if _, err := a.Authenticate(tc.req); !errors.Is(err, tc.expected) {
+				t.Errorf("%s: ожидалась %v, получено %v", name, tc.expected, err)
+			}
+		}
+	})
+
+	t.Run("Сессия", func(t *testing.T) {
+		cookie := a.NewSessionCookie(key, true)
+		if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+			t.Errorf("небезопасные атрибуты cookie: %+v", cookie)
+		}
+		p, err := a.Authenticate(withCookie(request("", ""), cookie.Value))
+		if err != nil {
+			t.Fatalf("Authenticate вернул ошибку: %v", err)
+		}
+		if p.KeyID != key.ID || p.Via != ViaSession {
+			t.Errorf("неверный субъект сессии: %+v", p)
+		}
+	})
+
+	t.Run("Ошибка хранилища не считается неверным ключом", func(t *testing.T) {
+		keys.SimulateError = storage.ErrUnavailable
+		defer func() { keys.SimulateError = nil }()
+		_, err := a.Authenticate(request(APIKeyHeader, secret))
+		if !errors.Is(err, storage.ErrUnavailable) || errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("ожидалась ErrUnavailable, получено %v", err)
+		}
+	})
+
+	t.Run("Отозванный и истекший ключ", func(t *testing.T) {
+		cookie := a.NewSessionCookie(key, false)
+		if _, err := keys.RevokeAPIKey(context.Background(), key.ID); err != nil {
+			t.Fatalf("RevokeAPIKey вернул ошибку: %v", err)
+		}
+		if _, err := a.Authenticate(request(APIKeyHeader, secret)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("отозванный ключ: ожидалась ErrInvalidCredentials, получено %v", err)
+		}
+		if _, err := a.Authenticate(withCookie(request("", ""), cookie.Value)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("сессия отозванного ключа: ожидалась ErrInvalidCredentials, получено %v", err)
+		}
+
+		expiredSecret, expired := newTestKey(t, keys, ScopeUsersRead)
+		past := time.Now().Add(-time.Minute)
+		keys.Keys[expired.ID].ExpiresAt = &past
+		if _, err := a.Authenticate(request(APIKeyHeader, expiredSecret)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("истекший ключ: ожидалась ErrInvalidCredentials, получено %v", err)
+		}
+	})
+}
+
+func withCookie(req *http.Request, value string) *http.Request {
+	req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: value})
+	return req
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+// APIKeyHeader — альтернатива заголовку Authorization для передачи ключа API
+const APIKeyHeader = "X-API-Key"
+
+// DefaultTouchInterval — как часто обновляется время последнего использования ключа
+const DefaultTouchInterval = time.Minute
+
+var (
+	// ErrNoCredentials — запрос не содержит учетных данных
+	ErrNoCredentials = errors.New("учетные данные не переданы")
+	// ErrInvalidCredentials — учетные данные неизвестны, отозваны, истекли или повреждены.
+	// Причина пишется в журнал, но клиенту не сообщается.
+	ErrInvalidCredentials = errors.New("недействительные учетные данные")
+)
+
+// Authenticator находит субъекта запроса по ключу API, JWT или cookie сессии
+type Authenticator struct {
+	Keys storage.APIKeyStorage
+	// Sessions проверяет cookie сессий фронтенда; nil отключает сессии
+	Sessions *SessionCodec
+	// Passwords проверяет cookie сессий пользователей, вошедших по паролю; nil отключает вход по паролю
+	Passwords *PasswordAuth
+	// JWT проверяет токены провайдера OIDC; nil отключает JWT
+	JWT *JWTVerifier
+	// TouchInterval — время последнего использования ключа обновляется не чаще этого,
+	// чтобы каждый запрос не превращался в запись в БД
+	TouchInterval time.Duration
+	// Now возвращает текущее время; подменяется в тестах
+	Now func() time.Time
+}
+
+// NewAuthenticator создает Authenticator с ключами из keys и сессиями sessions
+func NewAuthenticator(keys storage.APIKeyStorage, sessions *SessionCodec) *Authenticator {
+	return &Authenticator{Keys: keys, Sessions: sessions, TouchInterval: DefaultTouchInterval, Now: time.Now}
+}
+
+// Authenticate возвращает субъекта запроса. Ключ из заголовков имеет приоритет перед cookie,
+// cookie сессии фронтенда — перед cookie сессии пользователя.
+// Ошибки: ErrNoCredentials, ErrInvalidCredentials, ErrProviderUnavailable или ошибка хранилища.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, err := credentialsFromHeaders(r)
+	if err != nil {
+		return nil, err
+	}
+	// JWT принимается только в Authorization: X-API-Key предназначен для ключей API
+	if a.JWT != nil && r.Header.Get("Authorization") != "" && LooksLikeJWT(token) {
+		return a.authenticateJWT(r.Context(), token)
+	}
+	if token != "" {
+		key, err := a.AuthenticateKey(r.Context(), token)
+		if err != nil {
+			return nil, err
+		}
+		return newPrincipal(key, ViaAPIKey), nil
+	}
+	// Недействительная cookie сессии фронтенда не мешает входу по cookie сессии пользователя:
+	// иначе устаревшая cookie закрывала бы доступ, пока ее не удалят вручную
+	var sessionErr error
+	if a.Sessions != nil {
+		if cookie, err := r.Cookie(SessionCookieName); err == nil {
+			principal, err := a.authenticateSession(r.Context(), cookie.Value)
+			if !errors.Is(err, ErrInvalidCredentials) {
+				return principal, err
+			}
+			sessionErr = err
+		}
+	}
+	if a.Passwords != nil {
+		if cookie, err := r.Cookie(UserSessionCookieName); err == nil {
+			return a.Passwords.AuthenticateSession(r.Context(), cookie.Value)
+		}
+	}
+	if sessionErr != nil {
+		return nil, sessionErr
+	}
+	return nil, ErrNoCredentials
+}
+
+// credentialsFromHeaders возвращает ключ из Authorization: Bearer или X-API-Key.
+// Authorization с другой схемой (например, Basic) — ErrInvalidCredentials.
+func credentialsFromHeaders(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return "", fmt.Errorf("%w: ожидается схема Bearer", ErrInvalidCredentials)
+		}
+		return strings.TrimSpace(token), nil
+	}
+	return strings.TrimSpace(r.Header.Get(APIKeyHeader)), nil
+}
+
+// AuthenticateKey проверяет ключ API и возвращает его запись из хранилища
+func (a *Authenticator) AuthenticateKey(ctx context.Context, token string) (*models.APIKey, error) {
+	prefix, ok := ParseAPIKey(token)
+	if !ok {
+		return nil, fmt.Errorf("%w: неизвестный формат ключа", ErrInvalidCredentials)
+	}
+	key, err := a.Keys.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: ключ %s не найден", ErrInvalidCredentials, prefix)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth.AuthenticateKey: %w", err)
+	}
+	if !VerifyAPIKey(token, key.Hash) {
+		return nil, fmt.Errorf("%w: неверный секрет ключа %s", ErrInvalidCredentials, prefix)
+	}
+	if err := a.checkActive(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// authenticateSession проверяет cookie сессии и ключ, с которым она открыта
+func (a *Authenticator) authenticateSession(ctx context.Context, value string) (*Principal, error) {
+	keyID, err := a.Sessions.Decode(value, a.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	key, err := a.Keys.GetAPIKey(ctx, keyID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: ключ сессии не найден", ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth.Authenticate: %w", err)
+	}
+	if !a.Sessions.Verify(value, key) {
+		return nil, fmt.Errorf("%w: подпись сессии не совпадает", ErrInvalidCredentials)
+	}
+	if err := a.checkActive(ctx, key); err != nil {
+		return nil, err
+	}
+	return newPrincipal(key, ViaSession), nil
+}
+
+// authenticateJWT проверяет токен провайдера OIDC и переводит роли пользователя в области доступа
+func (a *Authenticator) authenticateJWT(ctx context.Context, token string) (*Principal, error) {
+	claims, err := a.JWT.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{
+		Name:    claims.Name,
+		Subject: claims.Subject,
+		Roles:   claims.Roles,
+		Scopes:  a.JWT.Scopes(claims.Roles),
+		Via:     ViaJWT,
+	}, nil
+}
+
+// checkActive отвергает отозванный или истекший ключ и отмечает использование действующего
+func (a *Authenticator) checkActive(ctx context.Context, key *models.APIKey) error {
+	now := a.Now()
+	if !key.Active(now) {
+		return fmt.Errorf("%w: ключ %s отозван или истек", ErrInvalidCredentials, key.Prefix)
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= a.TouchInterval {
+		// Ошибка записи времени использования не должна отклонять запрос
+		if err := a.Keys.TouchAPIKey(ctx, key.ID, now); err != nil {
+			slog.WarnContext(ctx, "Не удалось обновить время использования ключа API", "key_id", key.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// NewSessionCookie возвращает cookie новой сессии ключа key. secure добавляет атрибут Secure:
+// его нужно включать, когда клиент обращается к серверу по HTTPS.
+func (a *Authenticator) NewSessionCookie(key *models.APIKey, secure bool) *http.Cookie {
+	expires := a.Now().Add(a.Sessions.TTL)
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    a.Sessions.Encode(key, expires),
+		Path:     "/api/",
+		Expires:  expires,
+		MaxAge:   int(a.Sessions.TTL.Seconds()),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// ExpiredSessionCookie возвращает cookie, удаляющую сессию в браузере
+func ExpiredSessionCookie(secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/api/",
+		MaxAge:   -1,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+func newPrincipal(key *models.APIKey, via string) *Principal {
+	return &Principal{KeyID: key.ID, Name: key.Name, Scopes: key.Scopes, Via: via}
+}
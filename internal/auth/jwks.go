@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultJWKSRefreshInterval — сколько ключи из JWKS считаются актуальными
+	DefaultJWKSRefreshInterval = time.Hour
+	// DefaultJWKSMinRefreshInterval — минимальная пауза между запросами JWKS. Токен с неизвестным
+	// kid запускает внеочередное обновление (ротация ключей у провайдера), и пауза не дает
+	// поддельным токенам превратить сервер в генератор запросов к провайдеру.
+	DefaultJWKSMinRefreshInterval = time.Minute
+	// DefaultJWKSFetchTimeout ограничивает время загрузки JWKS
+	DefaultJWKSFetchTimeout = 10 * time.Second
+	// maxJWKSBytes ограничивает размер ответа JWKS
+	maxJWKSBytes = 1 << 20
+	// minRSAKeyBits — ключи RSA короче не принимаются
+	minRSAKeyBits = 2048
+)
+
+// ErrProviderUnavailable — не удалось получить ключи провайдера OIDC, а сохраненных нет.
+// Это сбой сервера, а не ошибка учетных данных клиента.
+var ErrProviderUnavailable = errors.New("провайдер OIDC недоступен")
+
+// jsonWebKey — открытый ключ из JWKS (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC и OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey — ключ подписи, готовый к проверке
+type publicKey struct {
+	kid string
+	// alg — алгоритм, указанный провайдером; пустая строка — любой подходящий к типу ключа
+	alg string
+	key crypto.PublicKey
+}
+
+// parseJWK разбирает открытый ключ RSA, EC P-256 или Ed25519
+func parseJWK(k jsonWebKey) (crypto.PublicKey, error) {
+	decode := func(name, value string) ([]byte, error) {
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("некорректное поле %s", name)
+		}
+		return b, nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) > 4 {
+			return nil, fmt.Errorf("слишком большая экспонента")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("ключ RSA короче %d бит", minRSAKeyBits)
+		}
+		return key, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("неподдерживаемая кривая %s", k.Crv)
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("некорректная длина координат P-256")
+		}
+		// ecdh проверяет, что точка лежит на кривой
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("точка не лежит на кривой P-256")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("неподдерживаемая кривая %s", k.Crv)
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("некорректная длина ключа Ed25519")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип ключа %s", k.Kty)
+	}
+}
+
+// JWKSCache загружает открытые ключи провайдера OIDC по URL JWKS и хранит их
+// RefreshInterval. Если провайдер недоступен, используются ранее загруженные ключи.
+// Безопасен для параллельного использования.
+type JWKSCache struct {
+	URL    string
+	Client *http.Client
+	// RefreshInterval — срок, после которого ключи загружаются заново
+	RefreshInterval time.Duration
+	// MinRefreshInterval — минимальная пауза между запросами к провайдеру
+	MinRefreshInterval time.Duration
+	// FetchTimeout ограничивает загрузку JWKS; она не зависит от контекста запроса,
+	// который ее запустил
+	FetchTimeout time.Duration
+	// Now возвращает текущее время; подменяется в тестах
+	Now func() time.Time
+
+	// refreshes объединяет одновременные загрузки JWKS в одну
+	refreshes singleflight.Group
+
+	mu          sync.Mutex
+	keys        []publicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// NewJWKSCache создает кэш ключей из JWKS по адресу url
+func NewJWKSCache(url string) *JWKSCache {
+	return &JWKSCache{
+		URL:                url,
+		Client:             &http.Client{},
+		RefreshInterval:    DefaultJWKSRefreshInterval,
+		MinRefreshInterval: DefaultJWKSMinRefreshInterval,
+		FetchTimeout:       DefaultJWKSFetchTimeout,
+		Now:                time.Now,
+	}
+}
+
+// lookup возвращает ключи с идентификатором kid (все ключи, если kid пустой). Устаревшие ключи
+// обновляются в фоне, пока запрос проверяется сохраненными. Неизвестный kid или пустой кэш
+// ждут загрузки JWKS; загрузки выполняются не чаще MinRefreshInterval.
+func (c *JWKSCache) lookup(ctx context.Context, kid string) ([]publicKey, error) {
+	c.mu.Lock()
+	stale := c.keys == nil || c.Now().Sub(c.fetchedAt) >= c.RefreshInterval
+	found := c.find(kid)
+	c.mu.Unlock()
+
+	if len(found) > 0 {
+		if stale {
+			c.refresh(ctx)
+		}
+		return found, nil
+	}
+
+	// Незнакомый kid: возможно, провайдер уже подписывает новым ключом
+	select {
+	case <-c.refresh(ctx):
+	case <-ctx.Done():
+		return nil, fmt.Errorf("auth.JWKSCache: %w", ctx.Err())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, c.lastErr)
+	}
+	return c.find(kid), nil
+}
+
+// find возвращает ключи с идентификатором kid. Вызывается под c.mu
+func (c *JWKSCache) find(kid string) []publicKey {
+	if kid == "" {
+		return c.keys
+	}
+	var found []publicKey
+	for _, k := range c.keys {
+		if k.kid == kid {
+			found = append(found, k)
+		}
+	}
+	return found
+}
+
+// refresh запускает загрузку JWKS, если с прошлой попытки прошло не меньше MinRefreshInterval,
+// и возвращает канал, в который придет результат по ее завершении. Одновременные вызовы ждут одну загрузку.
+// Загрузка идет без c.mu и с собственным таймаутом, поэтому медленный провайдер не задерживает
+// запросы с известным kid, а отмена запроса не прерывает загрузку. При ошибке прежние ключи
+// сохраняются.
+func (c *JWKSCache) refresh(ctx context.Context) <-chan singleflight.Result {
+	return c.refreshes.DoChan("jwks", func() (interface{}, error) {
+		c.mu.Lock()
+		now := c.Now()
+		throttled := !c.lastAttempt.IsZero() && now.Sub(c.lastAttempt) < c.MinRefreshInterval
+		c.mu.Unlock()
+		if throttled {
+			return nil, nil
+		}
+
+		timeout := c.FetchTimeout
+		if timeout <= 0 {
+			timeout = DefaultJWKSFetchTimeout
+		}
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		keys, err := c.fetch(fetchCtx)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err != nil {
+			// Пауза до следующей попытки — только после ответа провайдера или его таймаута
+			if !errors.Is(err, context.Canceled) {
+				c.lastAttempt = now
+			}
+			c.lastErr = err
+			slog.WarnContext(ctx, "Не удалось загрузить ключи OIDC, используются сохраненные",
+				"url", c.URL, "cached_keys", len(c.keys), "error", err)
+			return nil, nil
+		}
+		c.keys, c.fetchedAt, c.lastAttempt, c.lastErr = keys, now, now, nil
+		slog.InfoContext(ctx, "Загружены ключи OIDC", "url", c.URL, "keys", len(keys))
+		return nil, nil
+	})
+}
+
+// fetch запрашивает JWKS и разбирает ключи подписи. Ключи неподдерживаемых типов
+// пропускаются, чтобы один такой ключ не ломал проверку остальных.
+func (c *JWKSCache) fetch(ctx context.Context) ([]publicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("auth.JWKSCache: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth.JWKSCache: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth.JWKSCache: ответ %s", resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSBytes)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("auth.JWKSCache: некорректный JWKS: %w", err)
+	}
+
+	keys := make([]publicKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			slog.WarnContext(ctx, "Ключ JWKS пропущен", "kid", k.Kid, "kty", k.Kty, "error", err)
+			continue
+		}
+		keys = append(keys, publicKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("auth.JWKSCache: в JWKS нет поддерживаемых ключей подписи")
+	}
+	return keys, nil
+}
@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Алгоритмы подписи JWT, которые принимает JWTVerifier. HS256 и "none" не поддерживаются
+// намеренно: открытый ключ из JWKS не должен становиться общим секретом.
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// DefaultClockSkew — допустимое расхождение часов сервера и провайдера OIDC
+const DefaultClockSkew = time.Minute
+
+// DefaultRolesClaim — claim токена со списком ролей
+const DefaultRolesClaim = "roles"
+
+// Claims — проверенные claims JWT
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	// Name — имя для журналов: preferred_username, email или sub
+	Name string
+	// Roles — роли из claim, заданного JWTVerifier.RolesClaim
+	Roles []string
+}
+
+// JWTVerifier проверяет JWT провайдера OIDC: подпись ключом из JWKS, издателя, аудиторию
+// и срок действия — и переводит роли из claims в области доступа
+type JWTVerifier struct {
+	Keys *JWKSCache
+	// Issuer — ожидаемое значение iss
+	Issuer string
+	// Audience — значение, которое должно входить в aud, обычно client_id приложения
+	Audience string
+	// ClockSkew допускает расхождение часов при проверке exp, nbf и iat
+	ClockSkew time.Duration
+	// RolesClaim — путь к claim с ролями; вложенные объекты разделяются точкой,
+	// например "realm_access.roles". Значение — массив строк или строка через пробел.
+	RolesClaim string
+	// RoleScopes — области доступа каждой роли. Токен без ролей из этого списка
+	// проходит аутентификацию, но не получает доступа ни к одному маршруту.
+	RoleScopes map[string][]string
+	// Now возвращает текущее время; подменяется в тестах
+	Now func() time.Time
+}
+
+// NewJWTVerifier создает JWTVerifier для издателя issuer и аудитории audience
+// с ключами из keys
+func NewJWTVerifier(keys *JWKSCache, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		Keys:       keys,
+		Issuer:     issuer,
+		Audience:   audience,
+		ClockSkew:  DefaultClockSkew,
+		RolesClaim: DefaultRolesClaim,
+		Now:        time.Now,
+	}
+}
+
+// LooksLikeJWT сообщает, что токен состоит из трех частей JWS. Так Authenticator отличает
+// JWT от ключа API, не проверяя подпись.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && !strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// jwtHeader — заголовок JWS
+type jwtHeader struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Typ  string   `json:"typ"`
+	Crit []string `json:"crit"`
+}
+
+// registeredClaims — стандартные claims JWT (RFC 7519)
+type registeredClaims struct {
+	Issuer    string       `json:"iss"`
+	Subject   string       `json:"sub"`
+	Audience  audience     `json:"aud"`
+	ExpiresAt *numericDate `json:"exp"`
+	NotBefore *numericDate `json:"nbf"`
+	IssuedAt  *numericDate `json:"iat"`
+	Username  string       `json:"preferred_username"`
+	Email     string       `json:"email"`
+}
+
+// audience — claim aud: строка или массив строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("aud должен быть строкой или массивом строк")
+	}
+	*a = list
+	return nil
+}
+
+// numericDate — время в секундах от начала эпохи, возможно дробное
+type numericDate struct {
+	time.Time
+}
+
+func (d *numericDate) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return fmt.Errorf("ожидается число секунд")
+	}
+	whole, frac := math.Modf(seconds)
+	d.Time = time.Unix(int64(whole), int64(frac*1e9))
+	return nil
+}
+
+// Verify проверяет токен и возвращает его claims. Ошибки токена оборачивают
+// ErrInvalidCredentials, недоступность JWKS — ErrProviderUnavailable.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: JWT должен состоять из трех частей", ErrInvalidCredentials)
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: заголовок JWT: %v", ErrInvalidCredentials, err)
+	}
+	switch header.Alg {
+	case AlgRS256, AlgES256, AlgEdDSA:
+	default:
+		return nil, fmt.Errorf("%w: алгоритм %q не поддерживается", ErrInvalidCredentials, header.Alg)
+	}
+	if header.Typ != "" && !strings.EqualFold(header.Typ, "JWT") && !strings.EqualFold(header.Typ, "at+jwt") {
+		return nil, fmt.Errorf("%w: тип токена %q", ErrInvalidCredentials, header.Typ)
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("%w: неизвестные критичные расширения %v", ErrInvalidCredentials, header.Crit)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: подпись JWT не в base64url", ErrInvalidCredentials)
+	}
+
+	keys, err := v.Keys.lookup(ctx, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("auth.JWTVerifier: %w", err)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if (k.alg == "" || k.alg == header.Alg) && verifySignature(header.Alg, k.key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w: подпись JWT не подходит ни к одному ключу (kid %q)", ErrInvalidCredentials, header.Kid)
+	}
+
+	// Claims разбираются только после проверки подписи
+	var registered registeredClaims
+	if err := decodeSegment(parts[1], &registered); err != nil {
+		return nil, fmt.Errorf("%w: claims JWT: %v", ErrInvalidCredentials, err)
+	}
+	var all map[string]interface{}
+	if err := decodeSegment(parts[1], &all); err != nil {
+		return nil, fmt.Errorf("%w: claims JWT: %v", ErrInvalidCredentials, err)
+	}
+	if err := v.checkClaims(&registered); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	claims := &Claims{
+		Issuer:    registered.Issuer,
+		Subject:   registered.Subject,
+		Audience:  registered.Audience,
+		ExpiresAt: registered.ExpiresAt.Time,
+		Name:      firstNonEmpty(registered.Username, registered.Email, registered.Subject),
+		Roles:     claimStrings(all, v.RolesClaim),
+	}
+	return claims, nil
+}
+
+// checkClaims проверяет издателя, аудиторию и сроки действия токена
+func (v *JWTVerifier) checkClaims(c *registeredClaims) error {
+	if c.Issuer != v.Issuer {
+		return fmt.Errorf("издатель %q, ожидался %q", c.Issuer, v.Issuer)
+	}
+	if c.Subject == "" {
+		return fmt.Errorf("нет claim sub")
+	}
+	if !containsString(c.Audience, v.Audience) {
+		return fmt.Errorf("аудитория %v не содержит %q", []string(c.Audience), v.Audience)
+	}
+	now := v.Now()
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("нет claim exp")
+	}
+	if !now.Before(c.ExpiresAt.Add(v.ClockSkew)) {
+		return fmt.Errorf("токен истек %s", c.ExpiresAt.Format(time.RFC3339))
+	}
+	if c.NotBefore != nil && now.Add(v.ClockSkew).Before(c.NotBefore.Time) {
+		return fmt.Errorf("токен действует с %s", c.NotBefore.Format(time.RFC3339))
+	}
+	if c.IssuedAt != nil && now.Add(v.ClockSkew).Before(c.IssuedAt.Time) {
+		return fmt.Errorf("токен выпущен в будущем: %s", c.IssuedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Scopes возвращает области доступа, которые дают роли roles, без повторов
+func (v *JWTVerifier) Scopes(roles []string) []string {
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, role := range roles {
+		for _, scope := range v.RoleScopes[role] {
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// ParseRoleScopes разбирает соответствие ролей областям доступа в формате
+// "роль=область,область;роль=область", например
+// "user-admin=users:read,users:write;auditor=audit:read"
+func ParseRoleScopes(s string) (map[string][]string, error) {
+	result := make(map[string][]string)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, list, ok := strings.Cut(entry, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("ожидается роль=область,область, получено %q", entry)
+		}
+		for _, scope := range strings.Split(list, ",") {
+			scope = strings.TrimSpace(scope)
+			if !KnownScope(scope) {
+				return nil, fmt.Errorf("роль %s: неизвестная область доступа %q", role, scope)
+			}
+			result[role] = append(result[role], scope)
+		}
+	}
+	return result, nil
+}
+
+// decodeSegment декодирует часть JWT из base64url и JSON
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("не base64url")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	return nil
+}
+
+// verifySignature проверяет подпись signature данных signed ключом key по алгоритму alg.
+// Тип ключа должен соответствовать алгоритму: ключ RSA не проверяет подпись ES256 и наоборот.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) bool {
+	switch alg {
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		sum := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], signature) == nil
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		sum := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, sum[:], r, s)
+	case AlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, signed, signature)
+	default:
+		return false
+	}
+}
+
+// claimStrings возвращает строки claim по пути path ("realm_access.roles"). Массив строк
+// возвращается как есть, строка разбивается по пробелам, как claim scope.
+func claimStrings(claims map[string]interface{}, path string) []string {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,442 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+const (
+	testIssuer   = "https://idp.test/realms/main"
+	testAudience = "giperboreya"
+)
+
+// testSigner — ключ подписи тестового провайдера OIDC
+type testSigner struct {
+	kid, alg string
+	private  crypto.Signer
+}
+
+func (s *testSigner) jwk() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := s.private.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": s.kid, "alg": s.alg, "use": "sig",
+			"n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		return map[string]string{"kty": "EC", "kid": s.kid, "crv": "P-256", "x": b64(x), "y": b64(y)}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": s.kid, "crv": "Ed25519", "x": b64(pub)}
+	}
+	panic("неизвестный тип ключа")
+}
+
+// sign выпускает JWT с заголовком header (поверх alg и kid ключа) и claims
+func (s *testSigner) sign(t *testing.T, header, claims map[string]interface{}) string {
+	t.Helper()
+	h := map[string]interface{}{"alg": s.alg, "kid": s.kid, "typ": "JWT"}
+	for k, v := range header {
+		h[k] = v
+	}
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal вернул ошибку: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(h) + "." + encode(claims)
+	sum := sha256.Sum256([]byte(signed))
+	var signature []byte
+	var err error
+	switch key := s.private.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	case *ecdsa.PrivateKey:
+		var r, sig *big.Int
+		r, sig, err = ecdsa.Sign(rand.Reader, key, sum[:])
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			sig.FillBytes(signature[32:])
+		}
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signed))
+	}
+	if err != nil {
+		t.Fatalf("не удалось подписать JWT: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testProvider — JWKS провайдера OIDC на httptest.Server. Набор ключей можно заменить
+// (ротация), отключить ответы (провайдер недоступен) или задержать их до закрытия hold.
+type testProvider struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	signers  []*testSigner
+	down     bool
+	hold     chan struct{}
+	requests int
+}
+
+func newTestProvider(t *testing.T, signers ...*testSigner) *testProvider {
+	t.Helper()
+	p := &testProvider{signers: signers}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.requests++
+		hold := p.hold
+		p.mu.Unlock()
+		if hold != nil {
+			<-hold
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		keys := []map[string]string{
+			// Ключ шифрования и ключ неподдерживаемого типа пропускаются
+			{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+			{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		}
+		for _, s := range p.signers {
+			keys = append(keys, s.jwk())
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testProvider) set(down bool, signers ...*testSigner) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = down
+	if signers != nil {
+		p.signers = signers
+	}
+}
+
+func (p *testProvider) requestCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests
+}
+
+func newRSASigner(t *testing.T, kid string) *testSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey вернул ошибку: %v", err)
+	}
+	return &testSigner{kid: kid, alg: AlgRS256, private: key}
+}
+
+func newECSigner(t *testing.T, kid string) *testSigner {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey вернул ошибку: %v", err)
+	}
+	return &testSigner{kid: kid, alg: AlgES256, private: key}
+}
+
+func newEdSigner(t *testing.T, kid string) *testSigner {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey вернул ошибку: %v", err)
+	}
+	return &testSigner{kid: kid, alg: AlgEdDSA, private: key}
+}
+
+// testClock — управляемые часы для кэша JWKS и проверки сроков
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+// validClaims возвращает claims действующего токена пользователя alice
+func validClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                testIssuer,
+		"sub":                "5f1c",
+		"aud":                []string{testAudience, "account"},
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"preferred_username": "alice",
+		"roles":              []string{"admin"},
+	}
+}
+
+func newTestVerifier(p *testProvider, clock *testClock) *JWTVerifier {
+	jwks := NewJWKSCache(p.server.URL)
+	jwks.Now = clock.Now
+	v := NewJWTVerifier(jwks, testIssuer, testAudience)
+	v.Now = clock.Now
+	v.RoleScopes = map[string][]string{
+		"admin":   {ScopeUsersRead, ScopeUsersWrite},
+		"auditor": {ScopeAuditRead, ScopeUsersRead},
+	}
+	return v
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaSigner, ecSigner, edSigner := newRSASigner(t, "rsa-1"), newECSigner(t, "ec-1"), newEdSigner(t, "ed-1")
+	provider := newTestProvider(t, rsaSigner, ecSigner, edSigner)
+	clock := &testClock{now: time.Now()}
+	v := newTestVerifier(provider, clock)
+
+	t.Run("Алгоритмы подписи", func(t *testing.T) {
+		for _, s := range []*testSigner{rsaSigner, ecSigner, edSigner} {
+			claims, err := v.Verify(t.Context(), s.sign(t, nil, validClaims(clock.now)))
+			if err != nil {
+				t.Errorf("%s: Verify вернул ошибку: %v", s.alg, err)
+				continue
+			}
+			if claims.Subject != "5f1c" || claims.Name != "alice" || len(claims.Roles) != 1 {
+				t.Errorf("%s: неверные claims %+v", s.alg, claims)
+			}
+		}
+		if n := provider.requestCount(); n != 1 {
+			t.Errorf("ключи должны загружаться один раз, запросов JWKS: %d", n)
+		}
+	})
+
+	t.Run("Отклоненные токены", func(t *testing.T) {
+		with := func(name string, value interface{}) map[string]interface{} {
+			c := validClaims(clock.now)
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+			return c
+		}
+		valid := rsaSigner.sign(t, nil, validClaims(clock.now))
+		parts := strings.Split(valid, ".")
+		forged, _ := json.Marshal(with("roles", []string{"admin", "auditor"}))
+		hmacHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"rsa-1"}`))
+		noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+
+		testCases := map[string]string{
+			"Другой издатель":        rsaSigner.sign(t, nil, with("iss", "https://evil.test")),
+			"Другая аудитория":       rsaSigner.sign(t, nil, with("aud", "other")),
+			"Без exp":                rsaSigner.sign(t, nil, with("exp", nil)),
+			"Без sub":                rsaSigner.sign(t, nil, with("sub", nil)),
+			"Истек больше допуска":   rsaSigner.sign(t, nil, with("exp", clock.now.Add(-2*time.Minute).Unix())),
+			"nbf в будущем":          rsaSigner.sign(t, nil, with("nbf", clock.now.Add(2*time.Minute).Unix())),
+			"iat в будущем":          rsaSigner.sign(t, nil, with("iat", clock.now.Add(time.Hour).Unix())),
+			"Подмененные claims":     parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2],
+			"alg none":               noneHeader + "." + parts[1] + ".",
+			"HS256":                  hmacHeader + "." + parts[1] + "." + parts[2],
+			"Ключ другого типа":      ecSigner.sign(t, map[string]interface{}{"alg": AlgRS256}, validClaims(clock.now)),
+			"Критичное расширение":   rsaSigner.sign(t, map[string]interface{}{"crit": []string{"exp"}}, validClaims(clock.now)),
+			"Неизвестный тип токена": rsaSigner.sign(t, map[string]interface{}{"typ": "logout+jwt"}, validClaims(clock.now)),
+			"Не JWT":                 "a.b",
+			"Подпись не в base64url": parts[0] + "." + parts[1] + ".***",
+		}
+		for name, token := range testCases {
+			if _, err := v.Verify(t.Context(), token); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("%s: ожидалась ErrInvalidCredentials, получено %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Допуск расхождения часов", func(t *testing.T) {
+		c := validClaims(clock.now)
+		c["exp"] = clock.now.Add(-30 * time.Second).Unix()
+		c["nbf"] = clock.now.Add(30 * time.Second).Unix()
+		if _, err := v.Verify(t.Context(), edSigner.sign(t, nil, c)); err != nil {
+			t.Errorf("токен в пределах допуска отклонен: %v", err)
+		}
+	})
+
+	t.Run("Ротация ключей", func(t *testing.T) {
+		rotated := newRSASigner(t, "rsa-2")
+		provider.set(false, rotated, ecSigner, edSigner)
+		clock.now = clock.now.Add(DefaultJWKSMinRefreshInterval)
+		before := provider.requestCount()
+		if _, err := v.Verify(t.Context(), rotated.sign(t, nil, validClaims(clock.now))); err != nil {
+			t.Fatalf("токен с новым kid отклонен: %v", err)
+		}
+		if provider.requestCount() != before+1 {
+			t.Errorf("неизвестный kid должен запускать загрузку JWKS")
+		}
+		// Старый ключ провайдер больше не публикует
+		if _, err := v.Verify(t.Context(), rsaSigner.sign(t, nil, validClaims(clock.now))); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("токен удаленного ключа: ожидалась ErrInvalidCredentials, получено %v", err)
+		}
+
+		unknown := newRSASigner(t, "rsa-unknown")
+		before = provider.requestCount()
+		for i := 0; i < 3; i++ {
+			v.Verify(t.Context(), unknown.sign(t, nil, validClaims(clock.now)))
+		}
+		if provider.requestCount() != before {
+			t.Errorf("загрузки JWKS по неизвестному kid чаще MinRefreshInterval: %d запросов", provider.requestCount()-before)
+		}
+	})
+
+	t.Run("Провайдер недоступен", func(t *testing.T) {
+		provider.set(true)
+		clock.now = clock.now.Add(2 * DefaultJWKSRefreshInterval)
+		// Ключи устарели, но провайдер не отвечает: используются сохраненные
+		if _, err := v.Verify(t.Context(), ecSigner.sign(t, nil, validClaims(clock.now))); err != nil {
+			t.Errorf("при недоступном провайдере сохраненные ключи не используются: %v", err)
+		}
+
+		fresh := newTestVerifier(provider, clock)
+		_, err := fresh.Verify(t.Context(), ecSigner.sign(t, nil, validClaims(clock.now)))
+		if !errors.Is(err, ErrProviderUnavailable) || errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("без сохраненных ключей: ожидалась ErrProviderUnavailable, получено %v", err)
+		}
+		provider.set(false)
+	})
+}
+
+func TestJWKSCacheSlowProvider(t *testing.T) {
+	cached, rotated := newRSASigner(t, "rsa-1"), newRSASigner(t, "rsa-2")
+	provider := newTestProvider(t, cached)
+	clock := &testClock{now: time.Now()}
+	v := newTestVerifier(provider, clock)
+	if _, err := v.Verify(t.Context(), cached.sign(t, nil, validClaims(clock.now))); err != nil {
+		t.Fatalf("Verify вернул ошибку: %v", err)
+	}
+
+	hold := make(chan struct{})
+	provider.mu.Lock()
+	provider.signers = []*testSigner{cached, rotated}
+	provider.hold = hold
+	provider.mu.Unlock()
+	clock.now = clock.now.Add(2 * DefaultJWKSRefreshInterval)
+
+	// Запрос с новым kid ждет загрузки JWKS и отменяется раньше, чем провайдер ответит
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := v.Verify(ctx, rotated.sign(t, nil, validClaims(clock.now))); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("отмененный запрос: ожидалась context.DeadlineExceeded, получено %v", err)
+	}
+
+	// Известный kid проверяется сохраненными ключами, не дожидаясь провайдера
+	done := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(t.Context(), cached.sign(t, nil, validClaims(clock.now)))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("токен сохраненного ключа отклонен: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("проверка токена с известным kid ждет ответа провайдера")
+	}
+
+	// Отмена запроса не прерывает загрузку и не включает паузу между загрузками
+	close(hold)
+	if _, err := v.Verify(t.Context(), rotated.sign(t, nil, validClaims(clock.now))); err != nil {
+		t.Errorf("токен с новым kid отклонен после ответа провайдера: %v", err)
+	}
+	if n := provider.requestCount(); n != 2 {
+		t.Errorf("одновременные обновления должны объединяться в одну загрузку, запросов JWKS: %d", n)
+	}
+}
+
+func TestRoleScopes(t *testing.T) {
+	roleScopes, err := ParseRoleScopes(" admin = users:read, users:write ; auditor=audit:read;")
+	if err != nil {
+		t.Fatalf("ParseRoleScopes вернул ошибку: %v", err)
+	}
+	if len(roleScopes) != 2 || len(roleScopes["admin"]) != 2 || roleScopes["auditor"][0] != ScopeAuditRead {
+		t.Errorf("неверный разбор: %v", roleScopes)
+	}
+	for _, invalid := range []string{"admin", "=users:read", "admin=users:delete", "admin=users:read,"} {
+		if _, err := ParseRoleScopes(invalid); err == nil {
+			t.Errorf("ParseRoleScopes принял %q", invalid)
+		}
+	}
+
+	claims := map[string]interface{}{
+		"roles":        "auditor admin",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"auditor", 42}},
+	}
+	if got := claimStrings(claims, "roles"); len(got) != 2 || got[1] != "admin" {
+		t.Errorf("роли из строки через пробел: %v", got)
+	}
+	if got := claimStrings(claims, "realm_access.roles"); len(got) != 1 || got[0] != "auditor" {
+		t.Errorf("роли из вложенного claim: %v", got)
+	}
+	if got := claimStrings(claims, "resource_access.app.roles"); got != nil {
+		t.Errorf("отсутствующий claim: %v", got)
+	}
+
+	v := &JWTVerifier{RoleScopes: roleScopes}
+	scopes := v.Scopes([]string{"admin", "auditor", "guest"})
+	if strings.Join(scopes, " ") != "audit:read users:read users:write" {
+		t.Errorf("неверные области доступа: %v", scopes)
+	}
+}
+
+func TestAuthenticatorJWT(t *testing.T) {
+	signer := newECSigner(t, "ec-1")
+	provider := newTestProvider(t, signer)
+	clock := &testClock{now: time.Now()}
+	keys := storage.NewMockAPIKeyStorage()
+	a := NewAuthenticator(keys, nil)
+	a.JWT = newTestVerifier(provider, clock)
+	apiKey, _ := newTestKey(t, keys, ScopeUsersRead)
+
+	claims := validClaims(clock.now)
+	claims["realm_access"] = map[string]interface{}{"roles": []string{"auditor"}}
+	a.JWT.RolesClaim = "realm_access.roles"
+	token := signer.sign(t, nil, claims)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/audit", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	p, err := a.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Authenticate вернул ошибку: %v", err)
+	}
+	if p.Via != ViaJWT || !p.HasScope(ScopeAuditRead) || p.HasScope(ScopeUsersWrite) || p.Actor() != "jwt:5f1c:alice" {
+		t.Errorf("неверный субъект: %+v", p)
+	}
+
+	// Ключи API по-прежнему принимаются в Authorization, а JWT в X-API-Key — нет
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if p, err := a.Authenticate(req); err != nil || p.Via != ViaAPIKey {
+		t.Errorf("ключ API в Authorization: получено %+v, %v", p, err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set(APIKeyHeader, token)
+	if _, err := a.Authenticate(req); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("JWT в X-API-Key: ожидалась ErrInvalidCredentials, получено %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+// UserSessionCookieName — cookie сессии пользователя, вошедшего по паролю
+const UserSessionCookieName = "gb_user_session"
+
+const (
+	// DefaultUserSessionTTL — срок сессии пользователя независимо от активности
+	DefaultUserSessionTTL = 12 * time.Hour
+	// DefaultUserSessionIdleTimeout — сессия без запросов дольше этого завершается
+	DefaultUserSessionIdleTimeout = 30 * time.Minute
+	// DefaultMaxLoginFailures — после стольких неудачных входов подряд вход блокируется
+	DefaultMaxLoginFailures = 5
+	// DefaultLockoutDuration — на сколько блокируется вход
+	DefaultLockoutDuration = 15 * time.Minute
+	// DefaultLoginThrottleLimit и DefaultLoginThrottleWindow — сколько неудачных попыток
+	// допускается с одного адреса или для одного email за окно
+	DefaultLoginThrottleLimit  = 10
+	DefaultLoginThrottleWindow = time.Minute
+
+	// sessionTokenBytes — длина случайного токена сессии
+	sessionTokenBytes = 32
+)
+
+var (
+	// ErrAccountLocked — вход заблокирован после серии неудачных попыток (LockedError)
+	ErrAccountLocked = errors.New("вход временно заблокирован")
+	// ErrTooManyAttempts — слишком много неудачных попыток с адреса или для email (ThrottledError)
+	ErrTooManyAttempts = errors.New("слишком много попыток входа")
+)
+
+// LockedError — вход заблокирован до Until
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v до %s", ErrAccountLocked, e.Until.Format(time.RFC3339))
+}
+
+func (e *LockedError) Is(target error) bool { return target == ErrAccountLocked }
+
+// ThrottledError — следующую попытку можно сделать через RetryAfter
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%v, повторите через %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottledError) Is(target error) bool { return target == ErrTooManyAttempts }
+
+// ClientInfo — клиент, который входит в систему; сохраняется в сессии
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// LoginResult — пользователь и открытая для него сессия. Token — значение cookie,
+// на сервере хранится только его хэш.
+type LoginResult struct {
+	User    *models.User
+	Session *models.Session
+	Token   string
+}
+
+// PasswordAuth проверяет пароли пользователей, открывает их сессии и блокирует вход
+// после серии неудачных попыток
+type PasswordAuth struct {
+	Storage storage.CredentialStorage
+	// SessionTTL и IdleTimeout — срок сессии и допустимый простой (0 — без ограничения)
+	SessionTTL  time.Duration
+	IdleTimeout time.Duration
+	// MaxFailures неудачных входов подряд блокируют вход на LockoutDuration; 0 отключает блокировку
+	MaxFailures     int
+	LockoutDuration time.Duration
+	// Throttle ограничивает неудачные попытки с одного адреса и для одного email; nil — без ограничения
+	Throttle *Throttle
+	// TouchInterval — время последнего запроса сессии обновляется не чаще этого
+	TouchInterval time.Duration
+	// Now возвращает текущее время; подменяется в тестах
+	Now func() time.Time
+}
+
+// NewPasswordAuth создает PasswordAuth с настройками по умолчанию
+func NewPasswordAuth(s storage.CredentialStorage) *PasswordAuth {
+	return &PasswordAuth{
+		Storage:         s,
+		SessionTTL:      DefaultUserSessionTTL,
+		IdleTimeout:     DefaultUserSessionIdleTimeout,
+		MaxFailures:     DefaultMaxLoginFailures,
+		LockoutDuration: DefaultLockoutDuration,
+		Throttle:        NewThrottle(DefaultLoginThrottleLimit, DefaultLoginThrottleWindow),
+		TouchInterval:   DefaultTouchInterval,
+		Now:             time.Now,
+	}
+}
+
+// Login проверяет email и пароль и открывает сессию. Ошибки: ErrInvalidCredentials
+// (неизвестный email, пароль не задан или неверен — клиенту они неразличимы),
+// *LockedError, *ThrottledError или ошибка хранилища.
+func (p *PasswordAuth) Login(ctx context.Context, email, password string, client ClientInfo) (*LoginResult, error) {
+	now := p.Now()
+	keys := []string{"email:" + strings.ToLower(strings.TrimSpace(email))}
+	if client.IP != "" {
+		keys = append(keys, "ip:"+client.IP)
+	}
+	for _, key := range keys {
+		if wait, ok := p.Throttle.Allow(key, now); !ok {
+			return nil, &ThrottledError{RetryAfter: wait}
+		}
+	}
+
+	user, cred, err := p.Storage.GetPasswordByEmail(ctx, email)
+	if errors.Is(err, storage.ErrNotFound) {
+		verifyDummyPassword(password)
+		p.fail(keys, now)
+		return nil, fmt.Errorf("%w: нет пользователя с паролем и таким email", ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth.Login: %w", err)
+	}
+	// Хэш проверяется и во время блокировки, чтобы ответ о ней приходил не быстрее, чем
+	// для неизвестного email: иначе по времени ответа видно, что у аккаунта есть пароль.
+	// Результат проверки при блокировке отбрасывается, поэтому перебор не продолжается.
+	ok, err := VerifyPassword(password, cred.Hash)
+	if cred.Locked(now) {
+		return nil, &LockedError{Until: *cred.LockedUntil}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth.Login: пароль пользователя %d: %w", user.ID, err)
+	}
+	if !ok {
+		p.fail(keys, now)
+		updated, err := p.Storage.RecordLoginFailure(ctx, user.ID, p.MaxFailures, now.Add(p.LockoutDuration))
+		if err != nil {
+			return nil, fmt.Errorf("auth.Login: %w", err)
+		}
+		if updated.Locked(now) {
+			slog.WarnContext(ctx, "Вход заблокирован после неудачных попыток",
+				"user_id", user.ID, "locked_until", *updated.LockedUntil)
+			return nil, &LockedError{Until: *updated.LockedUntil}
+		}
+		return nil, fmt.Errorf("%w: неверный пароль пользователя %d", ErrInvalidCredentials, user.ID)
+	}
+
+	if cred.FailedAttempts > 0 || cred.LockedUntil != nil {
+		if err := p.Storage.ResetLoginFailures(ctx, user.ID); err != nil {
+			return nil, fmt.Errorf("auth.Login: %w", err)
+		}
+	}
+	p.Throttle.Reset(keys[0])
+
+	token, hash, err := GenerateSessionToken()
+	if err != nil {
+		return nil, fmt.Errorf("auth.Login: %w", err)
+	}
+	session := &models.Session{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: now.Add(p.SessionTTL),
+		IP:        client.IP,
+		UserAgent: truncate(client.UserAgent, 500),
+	}
+	if err := p.Storage.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("auth.Login: %w", err)
+	}
+	return &LoginResult{User: user, Session: session, Token: token}, nil
+}
+
+// fail учитывает неудачную попытку во всех счетчиках Throttle
+func (p *PasswordAuth) fail(keys []string, now time.Time) {
+	for _, key := range keys {
+		p.Throttle.Fail(key, now)
+	}
+}
+
+// Logout отзывает сессию с токеном token. Неизвестная или уже завершенная сессия
+// не считается ошибкой.
+func (p *PasswordAuth) Logout(ctx context.Context, token string) error {
+	session, err := p.Storage.GetSessionByTokenHash(ctx, HashSessionToken(token))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("auth.Logout: %w", err)
+	}
+	if err := p.Storage.RevokeSession(ctx, session.UserID, session.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("auth.Logout: %w", err)
+	}
+	return nil
+}
+
+// AuthenticateSession находит сессию по токену из cookie и возвращает ее пользователя
+func (p *PasswordAuth) AuthenticateSession(ctx context.Context, token string) (*Principal, error) {
+	session, err := p.Storage.GetSessionByTokenHash(ctx, HashSessionToken(token))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: сессия пользователя не найдена или отозвана", ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth.AuthenticateSession: %w", err)
+	}
+	now := p.Now()
+	if !session.Active(now, p.IdleTimeout) {
+		return nil, fmt.Errorf("%w: сессия %d истекла", ErrInvalidCredentials, session.ID)
+	}
+	if now.Sub(session.LastSeenAt) >= p.TouchInterval {
+		// Ошибка записи времени запроса не должна отклонять запрос
+		if err := p.Storage.TouchSession(ctx, session.ID, now); err != nil {
+			slog.WarnContext(ctx, "Не удалось обновить время запроса сессии", "session_id", session.ID, "error", err)
+		}
+	}
+	return &Principal{UserID: session.UserID, SessionID: session.ID, Via: ViaUserSession}, nil
+}
+
+// GenerateSessionToken возвращает случайный токен сессии и его хэш для хранилища
+func GenerateSessionToken() (token, hash string, err error) {
+	b := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("auth.GenerateSessionToken: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashSessionToken(token), nil
+}
+
+// HashSessionToken возвращает SHA-256 токена в hex. Токен случайный и длинный,
+// поэтому медленный хэш, как для паролей, не нужен.
+func HashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewUserSessionCookie возвращает cookie сессии пользователя с токеном token
+func NewUserSessionCookie(token string, expires time.Time, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     UserSessionCookieName,
+		Value:    token,
+		Path:     "/api/",
+		Expires:  expires,
+		MaxAge:   int(time.Until(expires).Seconds()),
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// ExpiredUserSessionCookie возвращает cookie, удаляющую сессию пользователя в браузере
+func ExpiredUserSessionCookie(secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     UserSessionCookieName,
+		Path:     "/api/",
+		MaxAge:   -1,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// truncate обрезает строку до n байт, не разрывая символы UTF-8
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+// testPasswordParams — дешевые параметры argon2id, чтобы тесты не тратили время на хэширование
+var testPasswordParams = PasswordParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatalf("HashPassword вернул ошибку: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("неожиданный формат хэша: %s", hash)
+	}
+	if ok, err := VerifyPassword("correct horse battery", hash); !ok || err != nil {
+		t.Errorf("VerifyPassword не принял верный пароль: %v, %v", ok, err)
+	}
+	if ok, _ := VerifyPassword("correct horse battery!", hash); ok {
+		t.Error("VerifyPassword принял неверный пароль")
+	}
+	if other, _ := HashPassword("correct horse battery"); other == hash {
+		t.Error("два хэша одного пароля совпали: соль не случайна")
+	}
+
+	cheap, _ := testPasswordParams.Hash("secret password")
+	if ok, err := VerifyPassword("secret password", cheap); !ok || err != nil {
+		t.Errorf("VerifyPassword не принял пароль с параметрами из хэша: %v, %v", ok, err)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"$2a$10$abcdefghijklmnopqrstuv",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=4194304,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$",
+	} {
+		if _, err := VerifyPassword("secret password", invalid); err == nil {
+			t.Errorf("VerifyPassword принял некорректный хэш %q", invalid)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	now := time.Now()
+	throttle := NewThrottle(2, time.Minute)
+	throttle.Fail("ip:10.0.0.1", now)
+	if _, ok := throttle.Allow("ip:10.0.0.1", now); !ok {
+		t.Error("Allow отклонил попытку до исчерпания лимита")
+	}
+	throttle.Fail("ip:10.0.0.1", now.Add(10*time.Second))
+	wait, ok := throttle.Allow("ip:10.0.0.1", now.Add(20*time.Second))
+	if ok || wait != 40*time.Second {
+		t.Errorf("Allow после исчерпания лимита: ожидалось ожидание 40s, получено %s (ok=%v)", wait, ok)
+	}
+	if _, ok := throttle.Allow("ip:10.0.0.2", now); !ok {
+		t.Error("лимит одного ключа повлиял на другой")
+	}
+	if _, ok := throttle.Allow("ip:10.0.0.1", now.Add(time.Minute)); !ok {
+		t.Error("Allow отклонил попытку после выхода первой неудачи из окна")
+	}
+	throttle.Reset("ip:10.0.0.1")
+	if _, ok := throttle.Allow("ip:10.0.0.1", now.Add(20*time.Second)); !ok {
+		t.Error("Allow отклонил попытку после Reset")
+	}
+
+	throttle.Fail("ip:10.0.0.3", now)
+	throttle.Fail("ip:10.0.0.4", now.Add(2*time.Minute))
+	if _, stale := throttle.failures["ip:10.0.0.3"]; stale {
+		t.Error("устаревший ключ не удален из памяти")
+	}
+
+	var disabled *Throttle
+	disabled.Fail("ip:10.0.0.1", now)
+	if _, ok := disabled.Allow("ip:10.0.0.1", now); !ok {
+		t.Error("nil-Throttle должен пропускать все попытки")
+	}
+}
+
+// setupPasswordAuth создает PasswordAuth поверх моков с пользователем alice@example.com,
+// у которого задан пароль "correct horse battery"
+func setupPasswordAuth(t *testing.T) (*PasswordAuth, *storage.MockCredentialStorage, *testClock, int64) {
+	t.Helper()
+	users := storage.NewMockUserStorage()
+	id, err := users.CreateUser(context.Background(), &models.User{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser вернул ошибку: %v", err)
+	}
+	credentials := storage.NewMockCredentialStorage(users)
+	hash, _ := testPasswordParams.Hash("correct horse battery")
+	if err := credentials.SetPassword(context.Background(), id, hash); err != nil {
+		t.Fatalf("SetPassword вернул ошибку: %v", err)
+	}
+	clock := &testClock{now: time.Now()}
+	p := NewPasswordAuth(credentials)
+	p.MaxFailures = 3
+	p.Throttle = NewThrottle(5, time.Minute)
+	p.Now = clock.Now
+	return p, credentials, clock, id
+}
+
+func TestPasswordAuthLogin(t *testing.T) {
+	ctx := context.Background()
+	p, credentials, clock, id := setupPasswordAuth(t)
+	client := ClientInfo{IP: "10.0.0.1", UserAgent: "test"}
+
+	if _, err := p.Login(ctx, "bob@example.com", "correct horse battery", client); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("вход неизвестного пользователя: ожидалось ErrInvalidCredentials, получено %v", err)
+	}
+	if _, err := p.Login(ctx, "alice@example.com", "wrong password", client); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("вход с неверным паролем: ожидалось ErrInvalidCredentials, получено %v", err)
+	}
+
+	result, err := p.Login(ctx, "alice@example.com", "correct horse battery", client)
+	if err != nil {
+		t.Fatalf("Login вернул ошибку: %v", err)
+	}
+	if result.User.ID != id || result.Session.UserID != id || result.Token == "" {
+		t.Errorf("неверный результат входа: %+v", result)
+	}
+	if result.Session.TokenHash != HashSessionToken(result.Token) || strings.Contains(result.Session.TokenHash, result.Token) {
+		t.Error("хранилище должно получать только хэш токена")
+	}
+	if !result.Session.ExpiresAt.Equal(clock.now.Add(DefaultUserSessionTTL)) || result.Session.IP != "10.0.0.1" {
+		t.Errorf("неверная сессия: %+v", result.Session)
+	}
+	if cred, _ := credentials.GetPassword(ctx, id); cred.FailedAttempts != 0 {
+		t.Errorf("успешный вход должен сбрасывать счетчик неудач, осталось %d", cred.FailedAttempts)
+	}
+
+	principal, err := p.AuthenticateSession(ctx, result.Token)
+	if err != nil {
+		t.Fatalf("AuthenticateSession вернул ошибку: %v", err)
+	}
+	if principal.UserID != id || principal.SessionID != result.Session.ID || principal.Via != ViaUserSession || len(principal.Scopes) != 0 {
+		t.Errorf("неверный субъект сессии: %+v", principal)
+	}
+	if principal.Actor() != "user:1" {
+		t.Errorf("Actor: ожидалось user:1, получено %s", principal.Actor())
+	}
+	if _, err := p.AuthenticateSession(ctx, result.Token+"x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("чужой токен: ожидалось ErrInvalidCredentials, получено %v", err)
+	}
+
+	if err := p.Logout(ctx, result.Token); err != nil {
+		t.Fatalf("Logout вернул ошибку: %v", err)
+	}
+	if _, err := p.AuthenticateSession(ctx, result.Token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("сессия после выхода: ожидалось ErrInvalidCredentials, получено %v", err)
+	}
+	if err := p.Logout(ctx, result.Token); err != nil {
+		t.Errorf("повторный выход не должен быть ошибкой: %v", err)
+	}
+}
+
+func TestPasswordAuthLockout(t *testing.T) {
+	ctx := context.Background()
+	p, credentials, clock, id := setupPasswordAuth(t)
+	p.Throttle = nil
+	client := ClientInfo{IP: "10.0.0.1"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Login(ctx, "alice@example.com", "wrong password", client); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("попытка %d: ожидалось ErrInvalidCredentials, получено %v", i+1, err)
+		}
+	}
+	_, err := p.Login(ctx, "alice@example.com", "wrong password", client)
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("третья неудача: ожидалась блокировка, получено %v", err)
+	}
+	if !locked.Until.Equal(clock.now.Add(DefaultLockoutDuration)) {
+		t.Errorf("блокировка до %s, ожидалось %s", locked.Until, clock.now.Add(DefaultLockoutDuration))
+	}
+	if _, err := p.Login(ctx, "alice@example.com", "correct horse battery", client); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("верный пароль во время блокировки: ожидалась блокировка, получено %v", err)
+	}
+
+	entries := credentials.Audit.(*storage.MockAuditStorage).Entries
+	if last := entries[len(entries)-1]; last.Action != models.AuditActionLock || last.UserID != id {
+		t.Errorf("блокировка не записана в журнал аудита: %+v", last)
+	}
+
+	clock.now = clock.now.Add(DefaultLockoutDuration)
+	if _, err := p.Login(ctx, "alice@example.com", "correct horse battery", client); err != nil {
+		t.Errorf("вход после окончания блокировки вернул ошибку: %v", err)
+	}
+	if cred, _ := credentials.GetPassword(ctx, id); cred.LockedUntil != nil || cred.FailedAttempts != 0 {
+		t.Errorf("успешный вход должен снимать блокировку: %+v", cred)
+	}
+
+	p.MaxFailures = 0
+	for i := 0; i < 5; i++ {
+		if _, err := p.Login(ctx, "alice@example.com", "wrong password", client); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("MaxFailures 0, попытка %d: ожидалось ErrInvalidCredentials, получено %v", i+1, err)
+		}
+	}
+}
+
+func TestPasswordAuthThrottle(t *testing.T) {
+	ctx := context.Background()
+	p, _, clock, _ := setupPasswordAuth(t)
+	p.MaxFailures = 0
+	p.Throttle = NewThrottle(2, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		p.Login(ctx, "nobody@example.com", "wrong password", ClientInfo{IP: "10.0.0.1"})
+	}
+	_, err := p.Login(ctx, "alice@example.com", "correct horse battery", ClientInfo{IP: "10.0.0.1"})
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter != time.Minute {
+		t.Fatalf("попытка с исчерпанным лимитом адреса: ожидалось ThrottledError на минуту, получено %v", err)
+	}
+	if _, err := p.Login(ctx, "alice@example.com", "correct horse battery", ClientInfo{IP: "10.0.0.2"}); err != nil {
+		t.Errorf("вход с другого адреса вернул ошибку: %v", err)
+	}
+
+	// Перебор пароля одного email с разных адресов упирается в лимит email
+	for _, ip := range []string{"10.0.1.1", "10.0.1.2"} {
+		p.Login(ctx, "alice@example.com", "wrong password", ClientInfo{IP: ip})
+	}
+	if _, err := p.Login(ctx, "Alice@Example.com", "correct horse battery", ClientInfo{IP: "10.0.1.3"}); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("попытка с исчерпанным лимитом email: ожидалось ErrTooManyAttempts, получено %v", err)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if _, err := p.Login(ctx, "alice@example.com", "correct horse battery", ClientInfo{IP: "10.0.0.1"}); err != nil {
+		t.Errorf("вход после окончания окна вернул ошибку: %v", err)
+	}
+}
+
+func TestPasswordAuthSessions(t *testing.T) {
+	ctx := context.Background()
+	p, credentials, clock, id := setupPasswordAuth(t)
+	login := func() string {
+		t.Helper()
+		result, err := p.Login(ctx, "alice@example.com", "correct horse battery", ClientInfo{})
+		if err != nil {
+			t.Fatalf("Login вернул ошибку: %v", err)
+		}
+		return result.Token
+	}
+
+	token := login()
+	clock.now = clock.now.Add(20 * time.Minute)
+	if _, err := p.AuthenticateSession(ctx, token); err != nil {
+		t.Fatalf("AuthenticateSession до истечения простоя вернул ошибку: %v", err)
+	}
+	// Запрос продлил сессию: простой отсчитывается от него
+	clock.now = clock.now.Add(20 * time.Minute)
+	if _, err := p.AuthenticateSession(ctx, token); err != nil {
+		t.Errorf("сессия с недавним запросом отклонена: %v", err)
+	}
+	clock.now = clock.now.Add(DefaultUserSessionIdleTimeout)
+	if _, err := p.AuthenticateSession(ctx, token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("сессия после простоя: ожидалось ErrInvalidCredentials, получено %v", err)
+	}
+
+	clock.now = time.Now()
+	p.IdleTimeout = 0
+	token = login()
+	clock.now = clock.now.Add(DefaultUserSessionTTL)
+	if _, err := p.AuthenticateSession(ctx, token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("сессия после срока: ожидалось ErrInvalidCredentials, получено %v", err)
+	}
+
+	clock.now = time.Now()
+	token = login()
+	hash, _ := testPasswordParams.Hash("another password")
+	if err := credentials.SetPassword(ctx, id, hash); err != nil {
+		t.Fatalf("SetPassword вернул ошибку: %v", err)
+	}
+	if _, err := p.AuthenticateSession(ctx, token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("сессия после смены пароля: ожидалось ErrInvalidCredentials, получено %v", err)
+	}
+	if _, err := p.Login(ctx, "alice@example.com", "correct horse battery", ClientInfo{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("вход со старым паролем: ожидалось ErrInvalidCredentials, получено %v", err)
+	}
+}
+
+func TestAuthenticatorUserSession(t *testing.T) {
+	p, _, _, _ := setupPasswordAuth(t)
+	result, err := p.Login(context.Background(), "alice@example.com", "correct horse battery", ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login вернул ошибку: %v", err)
+	}
+	a := NewAuthenticator(storage.NewMockAPIKeyStorage(), nil)
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/auth/session", nil)
+	req.AddCookie(NewUserSessionCookie(result.Token, result.Session.ExpiresAt, false))
+
+	if _, err := a.Authenticate(req); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("без PasswordAuth cookie пользователя не должна учитываться, получено %v", err)
+	}
+	a.Passwords = p
+	principal, err := a.Authenticate(req)
+	if err != nil || principal.Via != ViaUserSession || !principal.FromCookie() {
+		t.Errorf("ожидался субъект сессии пользователя, получено %+v (ошибка %v)", principal, err)
+	}
+
+	// Устаревшая cookie сессии фронтенда не закрывает вход по сессии пользователя
+	sessions, _ := NewSessionCodec(nil, time.Hour)
+	a.Sessions = sessions
+	stale := sessions.Encode(&models.APIKey{ID: 1, Prefix: "gbk_0123456789ab"}, time.Now().Add(-time.Minute))
+	req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: stale})
+	principal, err = a.Authenticate(req)
+	if err != nil || principal.Via != ViaUserSession {
+		t.Errorf("с устаревшей cookie %s: ожидался субъект сессии пользователя, получено %+v (ошибка %v)", SessionCookieName, principal, err)
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Ограничения длины пароля в символах. Верхняя граница защищает от хэширования
+// мегабайтных строк на каждый запрос входа.
+const (
+	MinPasswordLength = 12
+	MaxPasswordLength = 128
+)
+
+// PasswordParams — параметры argon2id. Хранятся в каждом хэше, поэтому их можно менять:
+// старые хэши проверяются со своими параметрами.
+type PasswordParams struct {
+	// Memory — объем памяти в КиБ
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultPasswordParams — минимальная рекомендация OWASP для argon2id: 19 МиБ памяти, 2 прохода
+var DefaultPasswordParams = PasswordParams{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// maxPasswordMemory ограничивает память при проверке хэша, чтобы поврежденный
+// или подмененный хэш не заставил сервер выделить гигабайты
+const maxPasswordMemory = 1 << 20
+
+// HashPassword хэширует пароль argon2id с DefaultPasswordParams
+func HashPassword(password string) (string, error) {
+	return DefaultPasswordParams.Hash(password)
+}
+
+// Hash хэширует пароль со случайной солью и возвращает строку в формате PHC:
+// $argon2id$v=19$m=19456,t=2,p=1$<соль>$<хэш>
+func (p PasswordParams) Hash(password string) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("auth.HashPassword: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	b64 := base64.RawStdEncoding.EncodeToString
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism, b64(salt), b64(key)), nil
+}
+
+// VerifyPassword сравнивает пароль с хэшем HashPassword за время, не зависящее от того,
+// сколько байт совпало. Ошибка означает поврежденный хэш, а не неверный пароль.
+func VerifyPassword(password, encoded string) (bool, error) {
+	p, salt, key, err := parsePasswordHash(encoded)
+	if err != nil {
+		return false, fmt.Errorf("auth.VerifyPassword: %w", err)
+	}
+	actual := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
+
+// parsePasswordHash разбирает хэш в формате PHC
+func parsePasswordHash(encoded string) (PasswordParams, []byte, []byte, error) {
+	var p PasswordParams
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return p, nil, nil, fmt.Errorf("ожидается хэш argon2id в формате PHC")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("неподдерживаемая версия argon2 %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("некорректные параметры %q", parts[3])
+	}
+	if p.Memory == 0 || p.Memory > maxPasswordMemory || p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, fmt.Errorf("недопустимые параметры %q", parts[3])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return p, nil, nil, fmt.Errorf("некорректная соль")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("некорректный хэш")
+	}
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// verifyDummyPassword тратит на проверку столько же времени, сколько VerifyPassword.
+// Вызывается, когда пользователь не найден, чтобы по времени ответа нельзя было
+// узнать, есть ли у email пароль.
+func verifyDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("dummy password for timing")
+	})
+	VerifyPassword(password, dummyHash)
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// SessionCookieName — имя cookie сессии фронтенда
+const SessionCookieName = "gb_session"
+
+// DefaultSessionTTL — срок действия сессии фронтенда по умолчанию
+const DefaultSessionTTL = 12 * time.Hour
+
+// MinSessionSecretBytes — минимальная длина ключа подписи сессий
+const MinSessionSecretBytes = 32
+
+// errInvalidSession — cookie сессии повреждена, подделана или истекла
+var errInvalidSession = errors.New("недействительная сессия")
+
+// SessionCodec выпускает и проверяет cookie сессий фронтенда. Cookie содержит ID ключа API,
+// срок действия и подпись HMAC-SHA256, в которую входит открытая часть ключа: ротация или
+// отзыв ключа завершает и все сессии, открытые с ним. На сервере сессии не хранятся, поэтому
+// при нескольких репликах у всех должен быть один ключ подписи.
+type SessionCodec struct {
+	secret []byte
+	// TTL — срок действия новых сессий
+	TTL time.Duration
+}
+
+// NewSessionCodec создает SessionCodec с ключом подписи secret. Пустой secret заменяется
+// случайным: сессии тогда не переживают перезапуск процесса.
+func NewSessionCodec(secret []byte, ttl time.Duration) (*SessionCodec, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, MinSessionSecretBytes)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("auth.NewSessionCodec: %w", err)
+		}
+	}
+	if len(secret) < MinSessionSecretBytes {
+		return nil, fmt.Errorf("auth.NewSessionCodec: ключ подписи короче %d байт", MinSessionSecretBytes)
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &SessionCodec{secret: secret, TTL: ttl}, nil
+}
+
+// Encode возвращает значение cookie сессии ключа key, действующей до expires
+func (c *SessionCodec) Encode(key *models.APIKey, expires time.Time) string {
+	payload := strconv.FormatInt(key.ID, 10) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + c.sign(payload, key.Prefix)
+}
+
+// Decode разбирает значение cookie и возвращает ID ключа. Подпись проверяет Verify, когда
+// ключ уже прочитан из хранилища.
+func (c *SessionCodec) Decode(value string, now time.Time) (int64, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return 0, errInvalidSession
+	}
+	keyID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, errInvalidSession
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || !now.Before(time.Unix(expires, 0)) {
+		return 0, errInvalidSession
+	}
+	return keyID, nil
+}
+
+// Verify проверяет подпись cookie для ключа key
+func (c *SessionCodec) Verify(value string, key *models.APIKey) bool {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return false
+	}
+	return hmac.Equal([]byte(value[i+1:]), []byte(c.sign(value[:i], key.Prefix)))
+}
+
+// sign возвращает подпись payload, привязанную к открытой части ключа
+func (c *SessionCodec) sign(payload, keyPrefix string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload + "." + keyPrefix))
+	return hex.EncodeToString(mac.Sum(nil))
+}
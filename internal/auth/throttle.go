@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle ограничивает число неудачных попыток входа по ключу (адрес клиента, email)
+// в скользящем окне Window. Счетчики хранятся в памяти процесса: это первая линия защиты
+// от перебора, а блокировку учетной записи, общую для всех реплик, ведет хранилище.
+// nil-Throttle и Throttle с Limit 0 ничего не ограничивают.
+// Безопасен для параллельного использования.
+type Throttle struct {
+	// Limit — сколько неудачных попыток разрешено за Window
+	Limit  int
+	Window time.Duration
+
+	mu        sync.Mutex
+	failures  map[string][]time.Time
+	lastSweep time.Time
+}
+
+// NewThrottle создает Throttle, допускающий limit неудачных попыток за window
+func NewThrottle(limit int, window time.Duration) *Throttle {
+	return &Throttle{Limit: limit, Window: window, failures: make(map[string][]time.Time)}
+}
+
+// Allow сообщает, можно ли сейчас попытаться войти с ключом key. Если нельзя,
+// возвращает время до освобождения попытки.
+func (t *Throttle) Allow(key string, now time.Time) (time.Duration, bool) {
+	if t == nil || t.Limit <= 0 {
+		return 0, true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	recent := t.recent(key, now)
+	if len(recent) < t.Limit {
+		return 0, true
+	}
+	return recent[0].Add(t.Window).Sub(now), false
+}
+
+// Fail учитывает неудачную попытку входа с ключом key
+func (t *Throttle) Fail(key string, now time.Time) {
+	if t == nil || t.Limit <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sweep(now)
+	recent := append(t.recent(key, now), now)
+	// Для решения нужны только последние Limit попыток
+	if len(recent) > t.Limit {
+		recent = recent[len(recent)-t.Limit:]
+	}
+	t.failures[key] = recent
+}
+
+// Reset забывает неудачные попытки с ключом key, например после успешного входа
+func (t *Throttle) Reset(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, key)
+}
+
+// recent возвращает попытки с ключом key за последнее окно. Вызывается под t.mu
+func (t *Throttle) recent(key string, now time.Time) []time.Time {
+	attempts := t.failures[key]
+	cutoff := now.Add(-t.Window)
+	for len(attempts) > 0 && !attempts[0].After(cutoff) {
+		attempts = attempts[1:]
+	}
+	if len(attempts) == 0 {
+		delete(t.failures, key)
+		return nil
+	}
+	t.failures[key] = attempts
+	return attempts
+}
+
+// sweep раз в окно удаляет ключи без недавних попыток, чтобы перебор по множеству
+// адресов и email не раздувал память. Вызывается под t.mu
+func (t *Throttle) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.Window {
+		return
+	}
+	t.lastSweep = now
+	for key := range t.failures {
+		t.recent(key, now)
+	}
+}
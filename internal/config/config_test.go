@@ -0,0 +1,347 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env возвращает lookupEnv по словарю переменных
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+// writeFile создает файл во временном каталоге теста
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Не удалось создать файл %s: %v", name, err)
+	}
+	return path
+}
+
+// database — минимальные переменные окружения для успешной проверки
+func database() map[string]string {
+	return map[string]string{"DB_HOST": "db", "DB_USER": "app", "DB_PASSWORD": "secret", "DB_NAME": "app"}
+}
+
+func TestLoadLayers(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+http:
+  port: 9000
+  read_timeout: 20s
+database:
+  host: file-host
+  sslmode: require
+log:
+  level: debug
+`)
+	vars := database()
+	vars["CONFIG_FILE"] = file
+	vars["DB_HOST"] = "env-host"
+	vars["HTTP_READ_TIMEOUT"] = "25s"
+
+	cfg, opts, err := Load([]string{"--http-port=9100", "--log-show-pii", "migrate", "down", "2"}, env(vars))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"значение по умолчанию", cfg.HTTP.WriteTimeout, 30 * time.Second},
+		{"файл поверх значения по умолчанию", cfg.Database.SSLMode, "require"},
+		{"файл", cfg.Log.Level, "debug"},
+		{"окружение поверх файла", cfg.Database.Host, "env-host"},
+		{"окружение поверх файла (длительность)", cfg.HTTP.ReadTimeout, 25 * time.Second},
+		{"флаг поверх файла", cfg.HTTP.Port, 9100},
+		{"логический флаг без значения", cfg.Log.ShowPII, true},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: ожидалось %v, получено %v", c.name, c.want, c.got)
+		}
+	}
+	if strings.Join(opts.Args, " ") != "migrate down 2" {
+		t.Errorf("аргументы после флагов: ожидалось 'migrate down 2', получено %q", opts.Args)
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	vars := database()
+	delete(vars, "DB_PASSWORD")
+	vars["DB_PASSWORD_FILE"] = writeFile(t, "password", "from-file\n")
+
+	cfg, _, err := Load(nil, env(vars))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Database.Password != "from-file" {
+		t.Errorf("пароль из файла: ожидалось 'from-file', получено %q", cfg.Database.Password)
+	}
+
+	vars["DB_PASSWORD"] = "from-env"
+	if _, _, err := Load(nil, env(vars)); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD_FILE") {
+		t.Errorf("DB_PASSWORD и DB_PASSWORD_FILE одновременно: ожидалась ошибка, получено %v", err)
+	}
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	file := writeFile(t, "config.yaml", "http:\n  prot: 80\n")
+	vars := map[string]string{
+		"CONFIG_FILE":          file,
+		"DB_PORT":              "five",
+		"TRASH_RETENTION":      "-1h",
+		"LOG_FORMAT":           "xml",
+		"OTEL_TRACES_EXPORTER": "zipkin",
+	}
+
+	cfg, opts, err := Load([]string{"--http-port=0"}, env(vars))
+	if err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+	if cfg == nil || opts == nil {
+		t.Error("при ошибках значений Load должен вернуть настройки для диагностики")
+	}
+	for _, want := range []string{
+		"prot",
+		"DB_PORT: ожидается целое число",
+		"http.port (APP_PORT, --http-port)",
+		"database.host (DB_HOST, --database-host)",
+		"database.password",
+		"trash.retention",
+		"log.format",
+		"tracing.exporter",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("в ошибке нет '%s':\n%v", want, err)
+		}
+	}
+
+	if _, _, err := Load([]string{"--no-such-flag"}, env(nil)); err == nil {
+		t.Error("неизвестный флаг: ожидалась ошибка")
+	}
+	if _, _, err := Load([]string{"--help"}, env(nil)); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("--help: ожидалась flag.ErrHelp, получено %v", err)
+	}
+}
+
+func TestDatabaseURL(t *testing.T) {
+	vars := map[string]string{"DATABASE_URL": "postgres://app:p%40ss@db:5432/app?sslmode=verify-full"}
+	cfg, _, err := Load(nil, env(vars))
+	if err != nil {
+		t.Fatalf("DATABASE_URL заменяет остальные параметры подключения: %v", err)
+	}
+	if cfg.Database.DSN() != vars["DATABASE_URL"] {
+		t.Errorf("DSN: ожидался URL без изменений, получено %q", cfg.Database.DSN())
+	}
+	if redacted := cfg.Database.Redacted(); strings.Contains(redacted, "p%40ss") || !strings.Contains(redacted, "app:") {
+		t.Errorf("Redacted должен скрыть только пароль, получено %q", redacted)
+	}
+
+	vars["DATABASE_URL"] = "mysql://db/app"
+	if _, _, err := Load(nil, env(vars)); err == nil || !strings.Contains(err.Error(), "database.url") {
+		t.Errorf("URL с чужой схемой: ожидалась ошибка database.url, получено %v", err)
+	}
+}
+
+func TestDSN(t *testing.T) {
+	d := DatabaseConfig{Host: "db", Port: 5432, User: "app", Password: `it's \ secret`, Name: "app", SSLMode: "require"}
+	want := `host='db' port=5432 user='app' password='it\'s \\ secret' dbname='app' sslmode='require'`
+	if got := d.DSN(); got != want {
+		t.Errorf("DSN:\nожидалось %s\nполучено  %s", want, got)
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	vars := database()
+	vars["DB_PASSWORD"] = "hunter2"
+	cfg, _, err := Load(nil, env(vars))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := cfg.PrintConfig(&buf); err != nil {
+		t.Fatalf("PrintConfig: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "hunter2") || !strings.Contains(out, `password: "***"`) {
+		t.Errorf("пароль должен быть скрыт:\n%s", out)
+	}
+	if !strings.Contains(out, `host: "db" # DB_HOST`) || !strings.Contains(out, "port: 8080 # APP_PORT") {
+		t.Errorf("в выводе нет значений с переменными окружения:\n%s", out)
+	}
+
+	// Вывод PrintConfig — корректный файл настроек
+	vars = database()
+	vars["CONFIG_FILE"] = writeFile(t, "printed.yaml", out)
+	vars["DB_PASSWORD"] = "hunter2"
+	if _, _, err := Load(nil, env(vars)); err != nil {
+		t.Errorf("вывод PrintConfig не читается как файл настроек: %v", err)
+	}
+
+	cfg.Database.URL = "postgres://app:hunter2@db/app"
+	buf.Reset()
+	if err := cfg.PrintConfig(&buf); err != nil {
+		t.Fatalf("PrintConfig: %v", err)
+	}
+	if strings.Contains(buf.String(), "hunter2") || !strings.Contains(buf.String(), "app:xxxxx@db") {
+		t.Errorf("пароль в DATABASE_URL должен быть скрыт:\n%s", buf.String())
+	}
+}
+
+func TestTLSSettings(t *testing.T) {
+	cert := writeFile(t, "server.crt", "cert")
+	key := writeFile(t, "server.key", "key")
+
+	vars := database()
+	vars["HTTP_TLS_CERT_FILE"] = cert
+	vars["HTTP_TLS_KEY_FILE"] = key
+	vars["HTTP_TLS_CLIENT_AUTH"] = "require"
+	vars["HTTP_TLS_MIN_VERSION"] = "1.1"
+	vars["DB_SSLCERT"] = cert
+	vars["DB_SSLROOTCERT"] = filepath.Join(t.TempDir(), "missing.crt")
+	_, _, err := Load(nil, env(vars))
+	if err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+	for _, want := range []string{
+		"http.tls_client_ca_file (HTTP_TLS_CLIENT_CA_FILE",
+		"http.tls_min_version",
+		"database.sslmode",
+		"database.sslcert (DB_SSLCERT, --database-sslcert): клиентский сертификат и ключ",
+		"database.sslrootcert",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("в ошибке нет '%s':\n%v", want, err)
+		}
+	}
+
+	vars = database()
+	vars["HTTP_TLS_CERT_FILE"] = cert
+	vars["HTTP_TLS_KEY_FILE"] = key
+	vars["HTTP_TLS_CLIENT_CA_FILE"] = cert
+	vars["HTTP_TLS_CLIENT_AUTH"] = "optional"
+	vars["DB_SSLMODE"] = "verify-full"
+	vars["DB_SSLROOTCERT"] = cert
+	vars["DB_SSLCERT"] = cert
+	vars["DB_SSLKEY"] = key
+	cfg, _, err := Load(nil, env(vars))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.HTTP.TLSEnabled() || cfg.HTTP.TLSOptions().ClientAuth != "optional" {
+		t.Errorf("настройки TLS сервера: %+v", cfg.HTTP.TLSOptions())
+	}
+	dsn := cfg.Database.DSN()
+	for _, want := range []string{"sslmode='verify-full'", "sslrootcert='" + cert + "'", "sslcert='" + cert + "'", "sslkey='" + key + "'"} {
+		if !strings.Contains(dsn, want) {
+			t.Errorf("в DSN нет %s: %s", want, dsn)
+		}
+	}
+}
+
+func TestAuthSettings(t *testing.T) {
+	vars := database()
+	vars["AUTH_SESSION_SECRET_FILE"] = writeFile(t, "session_secret", strings.Repeat("s", 32)+"\n")
+	vars["AUTH_SESSION_TTL"] = "1h"
+	cfg, _, err := Load([]string{"--auth-cookie-secure"}, env(vars))
+	if err != nil {
+		t.Fatalf("Load вернул ошибку: %v", err)
+	}
+	if cfg.Auth.Disabled || !cfg.Auth.CookieSecure || cfg.Auth.SessionTTL != time.Hour || len(cfg.Auth.SessionSecret) != 32 {
+		t.Errorf("неверные настройки аутентификации: %+v", cfg.Auth)
+	}
+
+	vars["AUTH_SESSION_SECRET_FILE"] = writeFile(t, "short_secret", "short")
+	if _, _, err := Load(nil, env(vars)); err == nil || !strings.Contains(err.Error(), "auth.session_secret") {
+		t.Errorf("короткий ключ подписи: ожидалась ошибка auth.session_secret, получено %v", err)
+	}
+
+	var out bytes.Buffer
+	cfg.PrintConfig(&out)
+	if strings.Contains(out.String(), strings.Repeat("s", 32)) {
+		t.Errorf("PrintConfig выводит ключ подписи сессий:\n%s", out.String())
+	}
+}
+
+func TestOIDCSettings(t *testing.T) {
+	vars := database()
+	vars["AUTH_OIDC_ISSUER"] = "https://idp.example.com/realms/main"
+	vars["AUTH_OIDC_AUDIENCE"] = "giperboreya"
+	vars["AUTH_OIDC_JWKS_URL"] = "https://idp.example.com/realms/main/certs"
+	vars["AUTH_OIDC_ROLES_CLAIM"] = "realm_access.roles"
+	vars["AUTH_OIDC_ROLE_SCOPES"] = "admin=users:read,users:write;auditor=audit:read"
+	cfg, _, err := Load(nil, env(vars))
+	if err != nil {
+		t.Fatalf("Load вернул ошибку: %v", err)
+	}
+	if cfg.Auth.OIDCRolesClaim != "realm_access.roles" || cfg.Auth.OIDCClockSkew != time.Minute {
+		t.Errorf("неверные настройки OIDC: %+v", cfg.Auth)
+	}
+
+	testCases := map[string]struct {
+		name, value, key string
+	}{
+		"Без аудитории":        {"AUTH_OIDC_AUDIENCE", "", "auth.oidc_audience"},
+		"URL JWKS без схемы":   {"AUTH_OIDC_JWKS_URL", "idp.example.com/certs", "auth.oidc_jwks_url"},
+		"Неизвестная область":  {"AUTH_OIDC_ROLE_SCOPES", "admin=users:delete", "auth.oidc_role_scopes"},
+		"Отрицательный допуск": {"AUTH_OIDC_CLOCK_SKEW", "-1s", "auth.oidc_clock_skew"},
+		"Нулевое обновление":   {"AUTH_OIDC_JWKS_REFRESH_INTERVAL", "0s", "auth.oidc_jwks_refresh_interval"},
+	}
+	for name, tc := range testCases {
+		broken := make(map[string]string)
+		for k, v := range vars {
+			broken[k] = v
+		}
+		broken[tc.name] = tc.value
+		if _, _, err := Load(nil, env(broken)); err == nil || !strings.Contains(err.Error(), tc.key) {
+			t.Errorf("%s: ожидалась ошибка %s, получено %v", name, tc.key, err)
+		}
+	}
+}
+
+func TestLoginSettings(t *testing.T) {
+	vars := database()
+	vars["AUTH_LOGIN_MAX_FAILURES"] = "0"
+	vars["AUTH_LOCKOUT_DURATION"] = "0s"
+	vars["AUTH_LOGIN_RATE_LIMIT"] = "20"
+	cfg, _, err := Load(nil, env(vars))
+	if err != nil {
+		t.Fatalf("Load вернул ошибку: %v", err)
+	}
+	if cfg.Auth.LoginMaxFailures != 0 || cfg.Auth.LoginRateLimit != 20 || cfg.Auth.UserSessionTTL != 12*time.Hour {
+		t.Errorf("неверные настройки входа: %+v", cfg.Auth)
+	}
+
+	testCases := map[string]struct {
+		name, value, key string
+	}{
+		"Нулевой срок сессии":     {"AUTH_USER_SESSION_TTL", "0s", "auth.user_session_ttl"},
+		"Нулевой простой":         {"AUTH_USER_SESSION_IDLE_TIMEOUT", "0s", "auth.user_session_idle_timeout"},
+		"Отрицательный порог":     {"AUTH_LOGIN_MAX_FAILURES", "-1", "auth.login_max_failures"},
+		"Блокировка без срока":    {"AUTH_LOGIN_MAX_FAILURES", "3", "auth.lockout_duration"},
+		"Отрицательный лимит":     {"AUTH_LOGIN_RATE_LIMIT", "-1", "auth.login_rate_limit"},
+		"Нулевое окно при лимите": {"AUTH_LOGIN_RATE_WINDOW", "0s", "auth.login_rate_window"},
+	}
+	for name, tc := range testCases {
+		broken := make(map[string]string)
+		for k, v := range vars {
+			broken[k] = v
+		}
+		broken[tc.name] = tc.value
+		if _, _, err := Load(nil, env(broken)); err == nil || !strings.Contains(err.Error(), tc.key) {
+			t.Errorf("%s: ожидалась ошибка %s, получено %v", name, tc.key, err)
+		}
+	}
+}
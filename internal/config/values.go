@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Реализации flag.Value для полей Config. Одни и те же значения разбирают и переменные
+// окружения, и флаги, поэтому формат везде одинаковый.
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("ожидается целое число, получено '%s'", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type int64Value int64
+
+func (v *int64Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("ожидается целое число, получено '%s'", s)
+	}
+	*v = int64Value(n)
+	return nil
+}
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("ожидается true или false, получено '%s'", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("ожидается длительность вида 5s, 1m30s или 720h, получено '%s'", s)
+	}
+	*v = durationValue(d)
+	return nil
+}
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+// recordedValue запоминает значение флага, чтобы применить его после файла и окружения
+type recordedValue struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (v *recordedValue) Set(s string) error { v.value, v.set = s, true; return nil }
+func (v *recordedValue) String() string     { return v.value }
+func (v *recordedValue) IsBoolFlag() bool   { return v.isBool }
+
+// isBoolValue сообщает, является ли значение логическим флагом (--log-show-pii без "=true")
+func isBoolValue(v interface{}) bool {
+	b, ok := v.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// isQuoted сообщает, нужно ли выводить значение в кавычках (строки)
+func isQuoted(v interface{}) bool {
+	_, ok := v.(*stringValue)
+	return ok
+}
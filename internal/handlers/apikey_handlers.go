@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+	"github.com/casanera/GiperboreyaTechnologies/internal/validation"
+)
+
+// APIKeyHandler обслуживает управление ключами API: выпуск, список, ротацию и отзыв
+type APIKeyHandler struct {
+	Storage storage.APIKeyStorage
+	// OperationTimeout ограничивает время одной операции с хранилищем; 0 — без ограничения
+	OperationTimeout time.Duration
+}
+
+func NewAPIKeyHandler(s storage.APIKeyStorage) *APIKeyHandler {
+	return &APIKeyHandler{Storage: s}
+}
+
+// createAPIKeyRequest тело запроса на выпуск ключа
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100,pattern=^[^\\p{Cc}]*$"`
+	Scopes []string `json:"scopes" validate:"required"`
+	// ExpiresAt — срок действия; без него ключ бессрочный
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// apiKeyResponse — ключ с его полным значением. Key заполняется только в ответах на выпуск
+// и ротацию: хранилище знает лишь хэш, и повторно получить ключ нельзя.
+type apiKeyResponse struct {
+	models.APIKey
+	Key string `json:"key,omitempty"`
+}
+
+// apiKeyListResponse тело ответа со списком ключей
+type apiKeyListResponse struct {
+	Keys []models.APIKey `json:"keys"`
+}
+
+// validateAPIKeyRequest проверяет запрос на выпуск ключа и при ошибке отправляет 422.
+// Возвращает false, если ответ уже отправлен.
+func validateAPIKeyRequest(w http.ResponseWriter, r *http.Request, req *createAPIKeyRequest, now time.Time) bool {
+	var errs validation.Errors
+	if err := validation.Struct(req); err != nil && !errors.As(err, &errs) {
+		slog.ErrorContext(r.Context(), "Ошибка проверки запроса ключа", "error", err)
+		sendProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal.create_api_key")
+		return false
+	}
+	for i, scope := range req.Scopes {
+		if !auth.KnownScope(scope) {
+			errs = append(errs, validation.FieldError{
+				Field: "scopes[" + strconv.Itoa(i) + "]", Rule: "scope", Param: scope,
+				Message: "неизвестная область доступа",
+			})
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		errs = append(errs, validation.FieldError{Field: "expires_at", Rule: "future", Message: "должно быть в будущем"})
+	}
+	if len(errs) > 0 {
+		sendValidationProblem(w, r, errs)
+		return false
+	}
+	return true
+}
+
+// pathKeyID разбирает параметр {id} ключа и при ошибке отправляет 400.
+// Возвращает false, если ответ уже отправлен.
+func pathKeyID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		slog.DebugContext(r.Context(), "Некорректный ID ключа", "id", idStr, "error", err)
+		sendProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "detail.invalid_key_id")
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateAPIKeyHandler обрабатывает POST /api/v1/keys: выпускает ключ и единственный раз
+// возвращает его полное значение
+func (h *APIKeyHandler) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "CreateAPIKeyHandler: начало обработки")
+	var req createAPIKeyRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка декодирования JSON при выпуске ключа", "error", err)
+		sendBodyError(w, r, err)
+		return
+	}
+	defer r.Body.Close()
+	if !validateAPIKeyRequest(w, r, &req, time.Now()) {
+		return
+	}
+
+	secret, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка генерации ключа", "error", err)
+		sendProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal.create_api_key")
+		return
+	}
+	key := models.APIKey{Name: req.Name, Prefix: prefix, Hash: hash, Scopes: req.Scopes, ExpiresAt: req.ExpiresAt}
+
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	if err := h.Storage.CreateAPIKey(ctx, &key); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.CreateAPIKey", "name", key.Name, "error", err)
+		sendStorageError(w, r, err, "notfound.api_key", "internal.create_api_key")
+		return
+	}
+	slog.InfoContext(r.Context(), "Выпущен ключ API", "key_id", key.ID, "key_name", key.Name,
+		"scopes", key.Scopes, "actor", auditInfoFromRequest(r).Actor)
+	sendJSONResponse(w, http.StatusCreated, apiKeyResponse{APIKey: key, Key: secret})
+}
+
+// ListAPIKeysHandler обрабатывает GET /api/v1/keys: все ключи, включая отозванные
+func (h *APIKeyHandler) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	keys, err := h.Storage.ListAPIKeys(ctx)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.ListAPIKeys", "error", err)
+		sendStorageError(w, r, err, "notfound.api_key", "internal.list_api_keys")
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	sendJSONResponse(w, http.StatusOK, apiKeyListResponse{Keys: keys})
+}
+
+// GetAPIKeyHandler обрабатывает GET /api/v1/keys/{id}
+func (h *APIKeyHandler) GetAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathKeyID(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	key, err := h.Storage.GetAPIKey(ctx, id)
+	if err != nil {
+		slog.DebugContext(r.Context(), "Ошибка h.Storage.GetAPIKey", "key_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.api_key", "internal.get_api_key")
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, key)
+}
+
+// RotateAPIKeyHandler обрабатывает POST /api/v1/keys/{id}/rotate: заменяет секрет ключа,
+// сохраняя его ID, имя, области доступа и срок действия. Прежнее значение и открытые
+// с ним сессии сразу перестают действовать.
+func (h *APIKeyHandler) RotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathKeyID(w, r)
+	if !ok {
+		return
+	}
+	secret, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка генерации ключа", "error", err)
+		sendProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal.rotate_api_key")
+		return
+	}
+
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	key, err := h.Storage.RotateAPIKey(ctx, id, prefix, hash)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.RotateAPIKey", "key_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.active_api_key", "internal.rotate_api_key")
+		return
+	}
+	slog.InfoContext(r.Context(), "Ключ API заменен", "key_id", key.ID, "actor", auditInfoFromRequest(r).Actor)
+	sendJSONResponse(w, http.StatusOK, apiKeyResponse{APIKey: *key, Key: secret})
+}
+
+// RevokeAPIKeyHandler обрабатывает DELETE /api/v1/keys/{id}: отзывает ключ. Запись о ключе
+// остается в списке с заполненным revoked_at.
+func (h *APIKeyHandler) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathKeyID(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	if _, err := h.Storage.RevokeAPIKey(ctx, id); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.RevokeAPIKey", "key_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.active_api_key", "internal.revoke_api_key")
+		return
+	}
+	slog.InfoContext(r.Context(), "Ключ API отозван", "key_id", id, "actor", auditInfoFromRequest(r).Actor)
+	w.WriteHeader(http.StatusNoContent)
+}
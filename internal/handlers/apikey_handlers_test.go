@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+func TestAPIKeyHandlers(t *testing.T) {
+	keys := storage.NewMockAPIKeyStorage()
+	h := NewAPIKeyHandler(keys)
+	a := auth.NewAuthenticator(keys, nil)
+
+	do := func(handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		routed(handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(h.CreateAPIKeyHandler, http.MethodPost, "/api/v1/keys", `{"name": "billing", "scopes": ["users:read"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Create: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var created apiKeyResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Не удалось декодировать ответ JSON: %v", err)
+	}
+	if bytes.Contains(rr.Body.Bytes(), []byte(keys.Keys[created.ID].Hash)) {
+		t.Error("ответ содержит хэш ключа")
+	}
+	if _, err := a.AuthenticateKey(t.Context(), created.Key); err != nil {
+		t.Fatalf("выпущенный ключ не принимается: %v", err)
+	}
+	keyPath := "/api/v1/keys/" + strconv.FormatInt(created.ID, 10)
+
+	t.Run("Ошибки проверки", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+		testCases := map[string]struct {
+			body, field, rule string
+		}{
+			"Без имени":               {`{"scopes": ["users:read"]}`, "name", "required"},
+			"Без областей доступа":    {`{"name": "x"}`, "scopes", "required"},
+			"Неизвестная область":     {`{"name": "x", "scopes": ["users:read", "root"]}`, "scopes[1]", "scope"},
+			"Срок действия в прошлом": {`{"name": "x", "scopes": ["users:read"], "expires_at": "` + past + `"}`, "expires_at", "future"},
+		}
+		for name, tc := range testCases {
+			rr := do(h.CreateAPIKeyHandler, http.MethodPost, "/api/v1/keys", tc.body)
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("%s: неверный статус-код: получено %v, ожидалось %v", name, rr.Code, http.StatusUnprocessableEntity)
+				continue
+			}
+			var resp problem
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+			if len(resp.Errors) != 1 || resp.Errors[0].Field != tc.field || resp.Errors[0].Rule != tc.rule {
+				t.Errorf("%s: ожидалась ошибка поля %s (%s), получено %+v", name, tc.field, tc.rule, resp.Errors)
+			}
+		}
+	})
+
+	t.Run("Список и получение", func(t *testing.T) {
+		rr := do(h.ListAPIKeysHandler, http.MethodGet, "/api/v1/keys", "")
+		var list apiKeyListResponse
+		json.Unmarshal(rr.Body.Bytes(), &list)
+		if rr.Code != http.StatusOK || len(list.Keys) != 1 || list.Keys[0].Prefix != created.Prefix {
+			t.Errorf("List: неверный ответ %v: %s", rr.Code, rr.Body.String())
+		}
+		if rr := do(h.GetAPIKeyHandler, http.MethodGet, keyPath, ""); rr.Code != http.StatusOK {
+			t.Errorf("Get: неверный статус-код: получено %v, ожидалось %v", rr.Code, http.StatusOK)
+		}
+		if rr := do(h.GetAPIKeyHandler, http.MethodGet, "/api/v1/keys/abc", ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("Get с некорректным ID: получено %v, ожидалось %v", rr.Code, http.StatusBadRequest)
+		}
+		if rr := do(h.GetAPIKeyHandler, http.MethodGet, "/api/v1/keys/999", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Get несуществующего ключа: получено %v, ожидалось %v", rr.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("Ротация", func(t *testing.T) {
+		rr := do(h.RotateAPIKeyHandler, http.MethodPost, keyPath+"/rotate", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Rotate: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var rotated apiKeyResponse
+		json.Unmarshal(rr.Body.Bytes(), &rotated)
+		if rotated.ID != created.ID || rotated.Key == created.Key || rotated.RotatedAt == nil {
+			t.Errorf("Rotate: неверный ответ %+v", rotated)
+		}
+		if _, err := a.AuthenticateKey(t.Context(), created.Key); err == nil {
+			t.Error("прежний ключ принимается после ротации")
+		}
+		if _, err := a.AuthenticateKey(t.Context(), rotated.Key); err != nil {
+			t.Errorf("новый ключ не принимается: %v", err)
+		}
+		created = rotated
+	})
+
+	t.Run("Отзыв", func(t *testing.T) {
+		if rr := do(h.RevokeAPIKeyHandler, http.MethodDelete, keyPath, ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("Revoke: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusNoContent, rr.Body.String())
+		}
+		if _, err := a.AuthenticateKey(t.Context(), created.Key); err == nil {
+			t.Error("отозванный ключ принимается")
+		}
+		if rr := do(h.RevokeAPIKeyHandler, http.MethodDelete, keyPath, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("повторный Revoke: получено %v, ожидалось %v", rr.Code, http.StatusNotFound)
+		}
+		if rr := do(h.RotateAPIKeyHandler, http.MethodPost, keyPath+"/rotate", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Rotate отозванного ключа: получено %v, ожидалось %v", rr.Code, http.StatusNotFound)
+		}
+	})
+}
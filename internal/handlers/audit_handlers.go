@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/middleware"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+const (
+	// actorHeader передает инициатора изменения, когда аутентификация отключена
+	actorHeader = "X-Actor"
+	// anonymousActor записывается в журнал, если инициатор не указан
+	anonymousActor = "anonymous"
+)
+
+// auditInfoFromRequest извлекает из запроса инициатора и ID запроса для журнала аудита.
+// Инициатор — ключ API, которым подписан запрос; X-Actor учитывается, только если
+// аутентификация отключена: иначе клиент мог бы выдать себя за другого. Слишком длинный
+// инициатор обрезается до размера колонки, чтобы запись журнала не срывала изменение.
+func auditInfoFromRequest(r *http.Request) storage.AuditInfo {
+	info := storage.AuditInfo{RequestID: requestID(r)}
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil {
+		info.Actor = principal.Actor()
+	} else {
+		info.Actor = strings.TrimSpace(r.Header.Get(actorHeader))
+	}
+	if info.Actor == "" {
+		info.Actor = anonymousActor
+	}
+	if actor := []rune(info.Actor); len(actor) > storage.MaxAuditActorLength {
+		info.Actor = string(actor[:storage.MaxAuditActorLength])
+	}
+	return info
+}
+
+// requestID возвращает ID запроса, проверенный или сгенерированный middleware.RequestID,
+// чтобы журнал аудита, журнал доступа и тело ошибки ссылались на один ID
+func requestID(r *http.Request) string {
+	return middleware.RequestIDFromContext(r.Context())
+}
+
+// auditListResponse тело ответа со страницей журнала аудита
+type auditListResponse struct {
+	Entries    []models.AuditEntry `json:"entries"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// parseAuditQuery разбирает параметры limit, cursor, actor, action, from и to
+func parseAuditQuery(r *http.Request) (storage.AuditQuery, error) {
+	params := r.URL.Query()
+	var q storage.AuditQuery
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return q, newQueryError("query.limit_positive")
+		}
+		if limit > storage.MaxAuditLimit {
+			return q, newQueryError("query.limit_max", "max", storage.MaxAuditLimit)
+		}
+		q.Limit = limit
+	}
+	if cursorStr := params.Get("cursor"); cursorStr != "" {
+		cursor, err := strconv.ParseInt(cursorStr, 10, 64)
+		if err != nil || cursor <= 0 {
+			return q, newQueryError("query.invalid_cursor")
+		}
+		q.BeforeID = cursor
+	}
+	for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if value := params.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return q, newQueryError("query.time_format", "param", name)
+			}
+			*dst = t
+		}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, newQueryError("query.from_before_to")
+	}
+	q.Actor = params.Get("actor")
+	q.Action = params.Get("action")
+	return q, nil
+}
+
+// sendAuditPage запрашивает страницу журнала и отправляет ее клиенту
+func (h *UserHandler) sendAuditPage(w http.ResponseWriter, r *http.Request, q storage.AuditQuery) {
+	if h.Audit == nil {
+		sendProblem(w, r, http.StatusNotImplemented, CodeNotImplemented, "detail.audit_not_configured")
+		return
+	}
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	page, err := h.Audit.ListAuditEntries(ctx, q)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Audit.ListAuditEntries", "error", err)
+		sendStorageError(w, r, err, "notfound.audit_entries", "internal.list_audit")
+		return
+	}
+
+	resp := auditListResponse{Entries: page.Entries}
+	if resp.Entries == nil {
+		resp.Entries = []models.AuditEntry{}
+	}
+	if page.NextCursor != 0 {
+		resp.NextCursor = strconv.FormatInt(page.NextCursor, 10)
+	}
+	setPaginationLinks(w, r, resp.NextCursor)
+	slog.DebugContext(r.Context(), "Журнал аудита: получена страница", "count", len(resp.Entries))
+	sendJSONResponse(w, http.StatusOK, resp)
+}
+
+// ListAuditHandler обрабатывает GET /api/v1/audit: журнал изменений всех пользователей
+// с фильтрами user_id, actor, action и интервалом времени from/to
+func (h *UserHandler) ListAuditHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "ListAuditHandler: начало обработки")
+	q, err := parseAuditQuery(r)
+	if err == nil {
+		if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+			q.UserID, err = strconv.ParseInt(userIDStr, 10, 64)
+			if err != nil {
+				err = newQueryError("query.user_id_integer")
+			}
+		}
+	}
+	if err != nil {
+		slog.DebugContext(r.Context(), "Некорректные параметры журнала аудита", "error", err)
+		sendQueryError(w, r, err)
+		return
+	}
+	h.sendAuditPage(w, r, q)
+}
+
+// UserHistoryHandler обрабатывает GET /api/v1/users/{id}/history: журнал изменений одного
+// пользователя, в том числе удаленного окончательно
+func (h *UserHandler) UserHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "UserHistoryHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	q, err := parseAuditQuery(r)
+	if err != nil {
+		slog.DebugContext(r.Context(), "Некорректные параметры истории", "error", err)
+		sendQueryError(w, r, err)
+		return
+	}
+	q.UserID = id
+	h.sendAuditPage(w, r, q)
+}
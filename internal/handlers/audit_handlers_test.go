@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+func TestAuditLog(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+	auditStorage := mockStorage.Audit.(*storage.MockAuditStorage)
+
+	do := func(handler http.HandlerFunc, method, path, body, actor string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		if actor != "" {
+			req.Header.Set("X-Actor", actor)
+		}
+		req.Header.Set("X-Request-ID", "req-"+method)
+		rr := httptest.NewRecorder()
+		routed(handler).ServeHTTP(rr, req)
+		return rr
+	}
+	listEntries := func(t *testing.T, handler http.HandlerFunc, path string) []models.AuditEntry {
+		t.Helper()
+		rr := do(handler, http.MethodGet, path, "", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: неверный статус-код: получено %v, ожидалось %v. Тело: %s", path, rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var resp auditListResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Не удалось декодировать ответ JSON: %v", err)
+		}
+		return resp.Entries
+	}
+
+	rr := do(userHandler.CreateUserHandler, http.MethodPost, "/api/v1/users", `{"name": "Audited", "email": "audited@example.com"}`, "alice")
+	var created models.User
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	userPath := "/api/v1/users/" + strconv.FormatInt(created.ID, 10)
+	do(userHandler.UpdateUserHandler, http.MethodPut, userPath, `{"name": "Audited", "email": "new@example.com"}`, "bob")
+	do(userHandler.DeleteUserHandler, http.MethodDelete, userPath, "", "")
+	other := mockStorage.SeedUser(models.User{Name: "Other", Email: "other@example.com"})
+	do(userHandler.DeleteUserHandler, http.MethodDelete, "/api/v1/users/"+strconv.FormatInt(other.ID, 10), "", "alice")
+
+	t.Run("История пользователя", func(t *testing.T) {
+		entries := listEntries(t, userHandler.UserHistoryHandler, userPath+"/history")
+		if len(entries) != 3 {
+			t.Fatalf("History: ожидалось 3 записи, получено %d: %+v", len(entries), entries)
+		}
+		expected := []struct{ action, actor, requestID string }{
+			{models.AuditActionDelete, "anonymous", "req-DELETE"},
+			{models.AuditActionUpdate, "bob", "req-PUT"},
+			{models.AuditActionCreate, "alice", "req-POST"},
+		}
+		for i, e := range expected {
+			if entries[i].Action != e.action || entries[i].Actor != e.actor || entries[i].RequestID != e.requestID {
+				t.Errorf("History[%d]: ожидалось %+v, получено %+v", i, e, entries[i])
+			}
+		}
+		emailChange := entries[1].Changes["email"]
+		if emailChange.Old != "audited@example.com" || emailChange.New != "new@example.com" || len(entries[1].Changes) != 1 {
+			t.Errorf("History: неверный diff обновления: %+v", entries[1].Changes)
+		}
+		if change, ok := entries[0].Changes["deleted_at"]; !ok || change.Old != nil || change.New == nil {
+			t.Errorf("History: удаление должно заполнять deleted_at, получено %+v", entries[0].Changes)
+		}
+	})
+
+	t.Run("Фильтры общего журнала", func(t *testing.T) {
+		entries := listEntries(t, userHandler.ListAuditHandler, "/api/v1/audit?actor=alice")
+		if len(entries) != 2 || entries[0].UserID != other.ID || entries[1].UserID != created.ID {
+			t.Errorf("Audit?actor=alice: ожидались записи пользователей %d и %d, получено %+v", other.ID, created.ID, entries)
+		}
+		future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		if entries := listEntries(t, userHandler.ListAuditHandler, "/api/v1/audit?from="+future); len(entries) != 0 {
+			t.Errorf("Audit?from=будущее: ожидался пустой журнал, получено %d записей", len(entries))
+		}
+		page := listEntries(t, userHandler.ListAuditHandler, "/api/v1/audit?limit=2")
+		rest := listEntries(t, userHandler.ListAuditHandler, "/api/v1/audit?cursor="+strconv.FormatInt(page[1].ID, 10))
+		if len(page) != 2 || len(rest) != 2 || rest[0].ID >= page[1].ID {
+			t.Errorf("Audit: некорректная пагинация: %+v, затем %+v", page, rest)
+		}
+		for _, query := range []string{"from=yesterday", "limit=0", "cursor=abc", "user_id=x"} {
+			if rr := do(userHandler.ListAuditHandler, http.MethodGet, "/api/v1/audit?"+query, "", ""); rr.Code != http.StatusBadRequest {
+				t.Errorf("Audit?%s: неверный статус-код: получено %v, ожидалось %v", query, rr.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("Длинные ID запроса и инициатор", func(t *testing.T) {
+		longID := strings.Repeat("r", 128)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBufferString(`{"name": "Long", "email": "long@example.com"}`))
+		req.Header.Set("X-Request-ID", longID)
+		req.Header.Set("X-Actor", strings.Repeat("а", 300))
+		rr := httptest.NewRecorder()
+		routed(userHandler.CreateUserHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Create: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusCreated, rr.Body.String())
+		}
+		entry := auditStorage.Entries[len(auditStorage.Entries)-1]
+		if entry.RequestID != longID {
+			t.Errorf("request_id: ожидался ID клиента длиной 128, получено '%s'", entry.RequestID)
+		}
+		if got := utf8.RuneCountInString(entry.Actor); got != storage.MaxAuditActorLength {
+			t.Errorf("actor: ожидалась длина %d, получено %d", storage.MaxAuditActorLength, got)
+		}
+	})
+
+	t.Run("ID запроса, отклоненный middleware", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBufferString(`{"name": "Bad", "email": "bad-id@example.com"}`))
+		req.Header.Set("X-Request-ID", strings.Repeat("r", 129))
+		rr := httptest.NewRecorder()
+		routed(userHandler.CreateUserHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Create: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusCreated, rr.Body.String())
+		}
+		entry := auditStorage.Entries[len(auditStorage.Entries)-1]
+		if got := rr.Header().Get("X-Request-ID"); entry.RequestID != got || len(got) > 128 {
+			t.Errorf("request_id: журнал ('%s') и ответ ('%s') должны ссылаться на ID, созданный middleware", entry.RequestID, got)
+		}
+	})
+
+	t.Run("Ошибка журнала отменяет изменение", func(t *testing.T) {
+		auditStorage.SimulateError = errors.New("журнал недоступен")
+		defer func() { auditStorage.SimulateError = nil }()
+
+		rr := do(userHandler.CreateUserHandler, http.MethodPost, "/api/v1/users", `{"name": "Lost", "email": "lost@example.com"}`, "")
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Create: неверный статус-код: получено %v, ожидалось %v", rr.Code, http.StatusInternalServerError)
+		}
+		for _, u := range mockStorage.Users {
+			if u.Email == "lost@example.com" {
+				t.Errorf("Create: пользователь сохранен, хотя запись в журнал не удалась")
+			}
+		}
+	})
+}
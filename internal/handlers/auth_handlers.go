@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/validation"
+)
+
+// AuthHandler проверяет учетные данные запросов и обслуживает сессии фронтенда
+type AuthHandler struct {
+	Authenticator *auth.Authenticator
+	// CookieSecure добавляет cookie сессии атрибут Secure и для запросов по HTTP — нужно,
+	// когда TLS завершается на прокси перед приложением
+	CookieSecure bool
+	// OperationTimeout ограничивает время проверки ключа в хранилище; 0 — без ограничения
+	OperationTimeout time.Duration
+}
+
+// NewAuthHandler создает AuthHandler, проверяющий запросы с помощью a
+func NewAuthHandler(a *auth.Authenticator) *AuthHandler {
+	return &AuthHandler{Authenticator: a}
+}
+
+// Require пропускает к next только запросы субъекта с областью доступа scope и сохраняет
+// субъекта в контексте запроса (auth.PrincipalFromContext). Без учетных данных — 401,
+// без нужной области доступа — 403. Для nil-обработчика (аутентификация отключена)
+// возвращает next без изменений.
+func (h *AuthHandler) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	if h == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := h.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if !principal.HasScope(scope) {
+			slog.InfoContext(r.Context(), "Недостаточно прав", "actor", principal.Actor(), "scope", scope)
+			sendProblem(w, r, http.StatusForbidden, CodeForbidden, "detail.forbidden", "scope", scope)
+			return
+		}
+		if principal.FromCookie() && !isSafeMethod(r.Method) && !sameOrigin(r) {
+			slog.WarnContext(r.Context(), "Запрос с сессией с чужого источника отклонен",
+				"origin", r.Header.Get("Origin"), "host", r.Host)
+			sendProblem(w, r, http.StatusForbidden, CodeForbidden, "detail.cross_origin")
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// RequireUser пропускает к next только запросы пользователя, вошедшего по паролю, и сохраняет
+// его в контексте запроса. Ключи API и JWT получают 403: у них нет своих сессий.
+func (h *AuthHandler) RequireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := h.authenticate(w, r)
+		if !ok {
+			return
+		}
+		if principal.Via != auth.ViaUserSession {
+			sendProblem(w, r, http.StatusForbidden, CodeForbidden, "detail.user_session_required")
+			return
+		}
+		if !isSafeMethod(r.Method) && !sameOrigin(r) {
+			slog.WarnContext(r.Context(), "Запрос с сессией с чужого источника отклонен",
+				"origin", r.Header.Get("Origin"), "host", r.Host)
+			sendProblem(w, r, http.StatusForbidden, CodeForbidden, "detail.cross_origin")
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// authenticate находит субъекта запроса и при ошибке отправляет ответ.
+// Возвращает false, если ответ уже отправлен.
+func (h *AuthHandler) authenticate(w http.ResponseWriter, r *http.Request) (*auth.Principal, bool) {
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	principal, err := h.Authenticator.Authenticate(r.WithContext(ctx))
+	if err == nil {
+		return principal, true
+	}
+	sendAuthError(w, r, err)
+	return nil, false
+}
+
+// sendAuthError отправляет 401 для отсутствующих или неверных учетных данных, 503 — если
+// недоступен провайдер OIDC, а для ошибок хранилища — ответ sendStorageError.
+// Причина отказа пишется в журнал, но не клиенту.
+func sendAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		sendProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "detail.unauthorized")
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		slog.InfoContext(r.Context(), "Учетные данные отклонены", "error", err)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		sendProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "detail.invalid_credentials")
+	case errors.Is(err, auth.ErrProviderUnavailable):
+		slog.ErrorContext(r.Context(), "Не удалось проверить JWT", "error", err)
+		sendProblem(w, r, http.StatusServiceUnavailable, CodeAuthUnavailable, "detail.auth_unavailable")
+	default:
+		slog.ErrorContext(r.Context(), "Ошибка проверки учетных данных", "error", err)
+		sendStorageError(w, r, err, "detail.invalid_credentials", "internal.authenticate")
+	}
+}
+
+// isSafeMethod сообщает, что метод не изменяет данные
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// sameOrigin проверяет, что запрос отправлен со страницы этого же сервера. Cookie сессии
+// и так имеет SameSite=Strict; проверка Origin защищает от CSRF в браузерах, которые
+// SameSite не поддерживают. Запрос без Origin (не из браузера) считается своим.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// sessionRequest тело запроса на открытие сессии
+type sessionRequest struct {
+	APIKey string `json:"api_key" validate:"required"`
+}
+
+// sessionResponse описывает субъекта текущей сессии или запроса
+type sessionResponse struct {
+	KeyID  int64    `json:"key_id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Via — как переданы учетные данные: api_key, session, jwt или user_session
+	Via string `json:"via"`
+	// Subject и Roles — claim sub и роли пользователя; только для jwt
+	Subject string   `json:"subject,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	// UserID и SessionID — пользователь и его сессия; только для user_session
+	UserID    int64 `json:"user_id,omitempty"`
+	SessionID int64 `json:"session_id,omitempty"`
+	// ExpiresAt — окончание сессии; только в ответе на ее открытие
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func newSessionResponse(p *auth.Principal) sessionResponse {
+	scopes := p.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return sessionResponse{
+		KeyID: p.KeyID, Name: p.Name, Scopes: scopes, Via: p.Via,
+		Subject: p.Subject, Roles: p.Roles, UserID: p.UserID, SessionID: p.SessionID,
+	}
+}
+
+// secureCookie сообщает, нужен ли cookie атрибут Secure
+func (h *AuthHandler) secureCookie(r *http.Request) bool {
+	return h.CookieSecure || r.TLS != nil
+}
+
+// CreateSessionHandler обрабатывает POST /api/v1/auth/session: обменивает ключ API из тела
+// запроса на cookie сессии, чтобы фронтенд не хранил ключ в браузере
+func (h *AuthHandler) CreateSessionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "CreateSessionHandler: начало обработки")
+	var req sessionRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка декодирования JSON при открытии сессии", "error", err)
+		sendBodyError(w, r, err)
+		return
+	}
+	defer r.Body.Close()
+	if err := validation.Struct(&req); err != nil {
+		var errs validation.Errors
+		if errors.As(err, &errs) {
+			sendValidationProblem(w, r, errs)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Ошибка проверки запроса сессии", "error", err)
+		sendProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal.authenticate")
+		return
+	}
+
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	key, err := h.Authenticator.AuthenticateKey(ctx, req.APIKey)
+	if err != nil {
+		sendAuthError(w, r, err)
+		return
+	}
+
+	cookie := h.Authenticator.NewSessionCookie(key, h.secureCookie(r))
+	http.SetCookie(w, cookie)
+	slog.InfoContext(r.Context(), "Открыта сессия", "key_id", key.ID, "key_name", key.Name)
+
+	resp := newSessionResponse(&auth.Principal{KeyID: key.ID, Name: key.Name, Scopes: key.Scopes, Via: auth.ViaSession})
+	resp.ExpiresAt = &cookie.Expires
+	sendJSONResponse(w, http.StatusCreated, resp)
+}
+
+// GetSessionHandler обрабатывает GET /api/v1/auth/session: субъект, от имени которого
+// выполняется запрос. Фронтенд по нему узнает, нужен ли вход.
+func (h *AuthHandler) GetSessionHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, newSessionResponse(principal))
+}
+
+// DeleteSessionHandler обрабатывает DELETE /api/v1/auth/session: удаляет cookie сессии.
+// Сессии не хранятся на сервере, поэтому похищенную cookie отменяет только ротация или
+// отзыв ключа.
+func (h *AuthHandler) DeleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		sendProblem(w, r, http.StatusForbidden, CodeForbidden, "detail.cross_origin")
+		return
+	}
+	http.SetCookie(w, auth.ExpiredSessionCookie(h.secureCookie(r)))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// userETag строит сильный ETag пользователя из его версии
+func userETag(user *models.User) string {
+	return fmt.Sprintf(`"%d"`, user.Version)
+}
+
+// setUserETag выставляет заголовок ETag для ответа с пользователем
+func setUserETag(w http.ResponseWriter, user *models.User) {
+	w.Header().Set("ETag", userETag(user))
+}
+
+// splitETags разбирает список ETag из If-Match / If-None-Match
+func splitETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ifMatchSatisfied проверяет If-Match (RFC 9110, 13.1.1): пустой заголовок и "*"
+// пропускают любую версию, иначе нужен совпадающий сильный ETag
+func ifMatchSatisfied(header string, user *models.User) bool {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return true
+	}
+	current := userETag(user)
+	for _, tag := range splitETags(header) {
+		// Слабые ETag при строгом сравнении никогда не совпадают
+		if tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ifNoneMatchSatisfied проверяет If-None-Match (RFC 9110, 13.1.2) слабым сравнением;
+// false означает, что у клиента актуальная версия и можно ответить 304
+func ifNoneMatchSatisfied(header string, user *models.User) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return true
+	}
+	if header == "*" {
+		return false
+	}
+	current := userETag(user)
+	for _, tag := range splitETags(header) {
+		if strings.TrimPrefix(tag, "W/") == current {
+			return false
+		}
+	}
+	return true
+}
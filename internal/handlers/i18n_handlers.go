@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/i18n"
+)
+
+// messagesResponse тело ответа с каталогом сообщений одного языка
+type messagesResponse struct {
+	// Lang — язык, сообщения которого возвращены; может отличаться от запрошенного
+	Lang string `json:"lang"`
+	// Languages — все поддерживаемые языки
+	Languages []string          `json:"languages"`
+	Messages  map[string]string `json:"messages"`
+}
+
+// MessagesHandler обрабатывает GET /api/v1/i18n/{lang} и отдает каталог сообщений для фронтенда.
+// lang разбирается как значение Accept-Language: для "en-US" вернется "en", а для
+// неподдерживаемого языка — язык по умолчанию. Без lang язык выбирается по Accept-Language.
+func MessagesHandler(w http.ResponseWriter, r *http.Request) {
+	requested := r.PathValue("lang")
+	if requested == "" {
+		requested = r.Header.Get("Accept-Language")
+	}
+	lang := i18n.Negotiate(requested)
+	slog.DebugContext(r.Context(), "MessagesHandler: выбран язык", "requested", requested, "lang", lang)
+
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Vary", "Accept-Language")
+	sendJSONResponse(w, http.StatusOK, messagesResponse{
+		Lang:      lang,
+		Languages: i18n.Default.Languages(),
+		Messages:  i18n.Default.Messages(lang),
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/i18n"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+func TestMessagesHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		path           string
+		acceptLanguage string
+		expectedLang   string
+	}{
+		{"Английский каталог", "/api/v1/i18n/en", "", "en"},
+		{"Региональный вариант языка", "/api/v1/i18n/en-US", "", "en"},
+		{"Неподдерживаемый язык", "/api/v1/i18n/fr", "", i18n.DefaultLanguage},
+		{"Язык из Accept-Language", "/api/v1/i18n/", "en-GB,en;q=0.9", "en"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+			rr := httptest.NewRecorder()
+			routed(MessagesHandler).ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("неверный статус-код: %d. Тело: %s", rr.Code, rr.Body.String())
+			}
+			var resp messagesResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("не удалось декодировать JSON: %v", err)
+			}
+			if resp.Lang != tc.expectedLang || rr.Header().Get("Content-Language") != tc.expectedLang {
+				t.Errorf("ожидался язык %s, получено %s (Content-Language %s)", tc.expectedLang, resp.Lang, rr.Header().Get("Content-Language"))
+			}
+			if resp.Messages["ui.save"] != i18n.Message(tc.expectedLang, "ui.save") {
+				t.Errorf("ui.save: получено %q", resp.Messages["ui.save"])
+			}
+			if len(resp.Languages) < 2 {
+				t.Errorf("ожидался список языков, получено %v", resp.Languages)
+			}
+		})
+	}
+
+}
+
+func TestLocalizedProblems(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+
+	t.Run("Заголовки всех кодов ошибок переведены", func(t *testing.T) {
+		codes := []ErrorCode{CodeMethodNotAllowed, CodeMalformedBody, CodeBodyTooLarge, CodeUnsupportedMediaType, CodeInvalidUserID,
+			CodeInvalidVersion, CodeInvalidParameter, CodeValidationFailed, CodeNotFound, CodeDuplicateValue,
+			CodePatchTestFailed, CodePatchNotApplicable, CodeVersionMismatch, CodeStorageTimeout,
+			CodeStorageUnavailable, CodeNotImplemented, CodeInternal}
+		for _, code := range codes {
+			if !i18n.Default.Has("problem." + string(code)) {
+				t.Errorf("нет заголовка для кода %s", code)
+			}
+		}
+	})
+
+	t.Run("Ошибки проверки на английском", func(t *testing.T) {
+		mockStorage.Reset()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/users/", bytes.NewBufferString(`{"name": "", "email": "not-an-email"}`))
+		req.Header.Set("Accept-Language", "en-US,en;q=0.9,ru;q=0.5")
+		rr := httptest.NewRecorder()
+		routed(userHandler.CreateUserHandler).ServeHTTP(rr, req)
+
+		resp := decodeProblem(t, rr, CodeValidationFailed)
+		if rr.Header().Get("Content-Language") != "en" {
+			t.Errorf("Content-Language: ожидалось en, получено %q", rr.Header().Get("Content-Language"))
+		}
+		if resp.Title != "Validation failed" || !strings.HasPrefix(resp.Detail, "Invalid data: ") {
+			t.Errorf("ожидались английские title и detail, получено %q / %q", resp.Title, resp.Detail)
+		}
+		if len(resp.Errors) != 2 || resp.Errors[0].Message != "value is required" || resp.Errors[1].Message != "invalid email address" {
+			t.Errorf("ожидались английские сообщения полей, получено %+v", resp.Errors)
+		}
+	})
+
+	t.Run("Параметры в сообщении", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/?limit=1000", nil)
+		req.Header.Set("Accept-Language", "en")
+		rr := httptest.NewRecorder()
+		routed(userHandler.ListUsersHandler).ServeHTTP(rr, req)
+		resp := decodeProblem(t, rr, CodeInvalidParameter)
+		if resp.Detail != "limit cannot exceed "+strconv.Itoa(storage.MaxUserLimit) {
+			t.Errorf("detail: получено %q", resp.Detail)
+		}
+	})
+
+	t.Run("Язык по умолчанию", func(t *testing.T) {
+		mockStorage.SeedUser(models.User{Name: "Owner", Email: "taken@example.com"})
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/users/", bytes.NewBufferString(`{"name": "Dup", "email": "taken@example.com"}`))
+		req.Header.Set("Accept-Language", "fr")
+		rr := httptest.NewRecorder()
+		routed(userHandler.CreateUserHandler).ServeHTTP(rr, req)
+		resp := decodeProblem(t, rr, CodeDuplicateValue)
+		if rr.Header().Get("Content-Language") != i18n.DefaultLanguage {
+			t.Errorf("Content-Language: ожидалось %s, получено %q", i18n.DefaultLanguage, rr.Header().Get("Content-Language"))
+		}
+		if resp.Detail != "Значение поля 'email' уже используется другим пользователем" || resp.Errors[0].Message != "значение уже используется" {
+			t.Errorf("ожидались сообщения на языке по умолчанию, получено %q / %+v", resp.Detail, resp.Errors)
+		}
+	})
+}
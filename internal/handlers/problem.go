@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/i18n"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+	"github.com/casanera/GiperboreyaTechnologies/internal/tracing"
+	"github.com/casanera/GiperboreyaTechnologies/internal/validation"
+)
+
+// problemContentType — тип тела ошибок по RFC 7807
+const problemContentType = "application/problem+json"
+
+// problemTypeBase — начало URI поля type; полный URI ведет к описанию кода в docs/problems.md
+const problemTypeBase = "https://github.com/casanera/GiperboreyaTechnologies/blob/main/docs/problems.md#"
+
+// ErrorCode — стабильный машиночитаемый код ошибки API. Клиенты должны ветвиться по нему,
+// а не по тексту detail: коды не меняются при изменении формулировок.
+type ErrorCode string
+
+const (
+	CodeMethodNotAllowed     ErrorCode = "method_not_allowed"
+	CodeMalformedBody        ErrorCode = "malformed_body"
+	CodeBodyTooLarge         ErrorCode = "body_too_large"
+	CodeUnsupportedMediaType ErrorCode = "unsupported_media_type"
+	CodeInvalidUserID        ErrorCode = "invalid_user_id"
+	CodeInvalidVersion       ErrorCode = "invalid_version"
+	CodeInvalidParameter     ErrorCode = "invalid_parameter"
+	CodeValidationFailed     ErrorCode = "validation_failed"
+	CodeUnauthorized         ErrorCode = "unauthorized"
+	CodeForbidden            ErrorCode = "forbidden"
+	CodeAuthUnavailable      ErrorCode = "auth_unavailable"
+	CodeAccountLocked        ErrorCode = "account_locked"
+	CodeTooManyAttempts      ErrorCode = "too_many_attempts"
+	CodeNotFound             ErrorCode = "not_found"
+	CodeDuplicateValue       ErrorCode = "duplicate_value"
+	CodePatchTestFailed      ErrorCode = "patch_test_failed"
+	CodePatchNotApplicable   ErrorCode = "patch_not_applicable"
+	CodeVersionMismatch      ErrorCode = "version_mismatch"
+	CodeStorageTimeout       ErrorCode = "storage_timeout"
+	CodeStorageUnavailable   ErrorCode = "storage_unavailable"
+	CodeNotImplemented       ErrorCode = "not_implemented"
+	CodeInternal             ErrorCode = "internal_error"
+)
+
+// problem — тело ошибки по RFC 7807. Code, RequestID, TraceID и Errors — расширения:
+// код для программной обработки, ID запроса и трассировки для поиска в журналах и ошибки по полям.
+type problem struct {
+	Type      string                  `json:"type"`
+	Title     string                  `json:"title"`
+	Status    int                     `json:"status"`
+	Detail    string                  `json:"detail,omitempty"`
+	Instance  string                  `json:"instance,omitempty"`
+	Code      ErrorCode               `json:"code"`
+	RequestID string                  `json:"request_id,omitempty"`
+	TraceID   string                  `json:"trace_id,omitempty"`
+	Errors    []validation.FieldError `json:"errors,omitempty"`
+}
+
+// sendProblem отправляет ошибку в формате application/problem+json. key — ключ сообщения detail
+// в каталоге i18n, args — пары имя-значение для подстановки. Заголовок и сообщения выводятся
+// на языке из Accept-Language, в журнал сервера сообщение пишется на языке по умолчанию.
+func sendProblem(w http.ResponseWriter, r *http.Request, statusCode int, code ErrorCode, key string, args ...interface{}) {
+	sendFieldProblem(w, r, statusCode, code, nil, key, args...)
+}
+
+// SendProblem — sendProblem для маршрутизации за пределами пакета, чтобы и ее ошибки
+// возвращались в формате problem+json
+func SendProblem(w http.ResponseWriter, r *http.Request, statusCode int, code ErrorCode, key string, args ...interface{}) {
+	sendProblem(w, r, statusCode, code, key, args...)
+}
+
+// sendFieldProblem отправляет ошибку со списком полей, к которым она относится
+func sendFieldProblem(w http.ResponseWriter, r *http.Request, statusCode int, code ErrorCode, fieldErrs []validation.FieldError, key string, args ...interface{}) {
+	lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+	p := problem{
+		Type:      problemTypeBase + string(code),
+		Title:     i18n.Message(lang, "problem."+string(code)),
+		Status:    statusCode,
+		Detail:    i18n.Message(lang, key, args...),
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestID(r),
+		TraceID:   tracing.TraceID(r.Context()),
+		Errors:    localizeFieldErrors(lang, fieldErrs),
+	}
+	level := slog.LevelDebug
+	if statusCode >= http.StatusInternalServerError {
+		level = slog.LevelWarn
+	}
+	slog.Log(r.Context(), level, "Отправка ошибки", "status", statusCode, "code", code,
+		"detail", i18n.Message(i18n.DefaultLanguage, key, args...))
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка кодирования JSON ошибки", "error", err)
+	}
+}
+
+// localizeFieldErrors переводит сообщения ошибок полей по имени правила (ключ validation.<правило>).
+// Сообщения правил, которых нет в каталоге, остаются как есть.
+func localizeFieldErrors(lang string, errs []validation.FieldError) []validation.FieldError {
+	if len(errs) == 0 {
+		return nil
+	}
+	localized := make([]validation.FieldError, len(errs))
+	for i, fe := range errs {
+		if key := "validation." + fe.Rule; i18n.Default.Has(key) {
+			fe.Message = i18n.Message(lang, key, "param", fe.Param)
+		}
+		localized[i] = fe
+	}
+	return localized
+}
+
+// sendValidationProblem отправляет 422 со списком ошибок по полям
+func sendValidationProblem(w http.ResponseWriter, r *http.Request, errs validation.Errors) {
+	lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+	sendFieldProblem(w, r, http.StatusUnprocessableEntity, CodeValidationFailed, errs,
+		"detail.validation_failed", "reason", validation.Errors(localizeFieldErrors(lang, errs)).Error())
+}
+
+// sendBodyError отправляет ошибку чтения или разбора тела запроса: 413, если тело больше
+// лимита MaxBytesReader, иначе 400 с причиной
+func sendBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	var unknown *unknownFieldError
+	switch {
+	case errors.As(err, &tooLarge):
+		sendProblem(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "detail.body_too_large", "limit", tooLarge.Limit)
+	case errors.As(err, &unknown):
+		sendFieldProblem(w, r, http.StatusBadRequest, CodeMalformedBody,
+			[]validation.FieldError{{Field: unknown.Field, Rule: "unknown"}},
+			"detail.unknown_field", "field", unknown.Field)
+	case errors.Is(err, errTrailingData):
+		sendProblem(w, r, http.StatusBadRequest, CodeMalformedBody, "detail.trailing_data")
+	default:
+		sendProblem(w, r, http.StatusBadRequest, CodeMalformedBody, "detail.malformed_body", "reason", err.Error())
+	}
+}
+
+// queryError — ошибка разбора параметров запроса с ключом сообщения из каталога i18n
+type queryError struct {
+	key  string
+	args []interface{}
+}
+
+func newQueryError(key string, args ...interface{}) *queryError {
+	return &queryError{key: key, args: args}
+}
+
+func (e *queryError) Error() string {
+	return i18n.Message(i18n.DefaultLanguage, e.key, e.args...)
+}
+
+// sendQueryError отправляет 400 для ошибки разбора параметров запроса
+func sendQueryError(w http.ResponseWriter, r *http.Request, err error) {
+	var qe *queryError
+	if errors.As(err, &qe) {
+		sendProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, qe.key, qe.args...)
+		return
+	}
+	sendProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "detail.invalid_query", "reason", err.Error())
+}
+
+// sendStorageError сопоставляет ошибку хранилища со статусом HTTP и кодом ошибки.
+// notFoundKey и internalKey — ключи сообщений для 404 и 500 соответственно.
+func sendStorageError(w http.ResponseWriter, r *http.Request, err error, notFoundKey, internalKey string) {
+	var conflictErr *storage.ConflictError
+	var validationErr *storage.ValidationError
+	var fieldErrs validation.Errors
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		sendProblem(w, r, http.StatusGatewayTimeout, CodeStorageTimeout, "detail.storage_timeout")
+	case errors.Is(err, context.Canceled):
+		// Клиент уже отключился, отправлять ответ некому
+		slog.InfoContext(r.Context(), "Запрос отменен клиентом", "error", err)
+	case errors.Is(err, storage.ErrNotFound):
+		sendProblem(w, r, http.StatusNotFound, CodeNotFound, notFoundKey)
+	case errors.As(err, &conflictErr):
+		sendFieldProblem(w, r, http.StatusConflict, CodeDuplicateValue,
+			[]validation.FieldError{{Field: conflictErr.Field, Rule: "unique"}},
+			"detail.duplicate_value", "field", conflictErr.Field)
+	case errors.As(err, &fieldErrs):
+		sendValidationProblem(w, r, fieldErrs)
+	case errors.As(err, &validationErr):
+		var errs []validation.FieldError
+		if validationErr.Field != "" {
+			errs = []validation.FieldError{{Field: validationErr.Field, Rule: "storage", Message: validationErr.Message}}
+		}
+		sendFieldProblem(w, r, http.StatusUnprocessableEntity, CodeValidationFailed, errs,
+			"detail.invalid_data", "reason", validationErr.Error())
+	case errors.Is(err, storage.ErrVersionMismatch):
+		sendProblem(w, r, http.StatusPreconditionFailed, CodeVersionMismatch, "detail.version_mismatch")
+	case errors.Is(err, storage.ErrUnavailable):
+		sendProblem(w, r, http.StatusServiceUnavailable, CodeStorageUnavailable, "detail.storage_unavailable")
+	default:
+		sendProblem(w, r, http.StatusInternalServerError, CodeInternal, internalKey)
+	}
+}
@@ -1,223 +1,580 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
 	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/patch"
 	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+	"github.com/casanera/GiperboreyaTechnologies/internal/validation"
 )
 
 type UserHandler struct {
 	Storage storage.UserStorage
+	// Audit — журнал аудита для чтения истории; записи в него делает Storage
+	Audit storage.AuditStorage
+	// OperationTimeout ограничивает время одной операции с хранилищем; 0 — без ограничения
+	OperationTimeout time.Duration
 }
 
 func NewUserHandler(s storage.UserStorage) *UserHandler {
 	return &UserHandler{Storage: s}
 }
 
+// operationContext возвращает контекст запроса, ограниченный OperationTimeout.
+// Отключение клиента отменяет контекст, и запрос к хранилищу прерывается.
+// Контекст несет инициатора и ID запроса для журнала аудита.
+func (h *UserHandler) operationContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx := storage.WithAuditInfo(r.Context(), auditInfoFromRequest(r))
+	return withTimeout(ctx, h.OperationTimeout)
+}
+
+// withTimeout ограничивает контекст временем timeout; 0 — без ограничения
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 // sendJSONResponse вспомогательная функция для отправки JSON ответа
 func sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	if data != nil { // Отправляем тело, только если data не nil
-		if err := json.NewEncoder(w).Encode(data); err != nil {
-			log.Printf("!!! ОШИБКА кодирования JSON ответа: %v. Данные: %+v", err, data)
-		} else {
-			log.Printf("DEBUG: JSON ответ успешно отправлен. Статус: %d. Данные: %+v", statusCode, data)
+	if data == nil { // Например, 204 No Content: тело не нужно
+		return
+	}
+	// Тело ответа в журнал не пишется: в нем персональные данные, статус фиксирует журнал доступа
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Ошибка кодирования JSON ответа", "status", statusCode, "error", err)
+	}
+}
+
+// errTrailingData — после JSON-документа в теле запроса есть что-то кроме пробелов
+var errTrailingData = errors.New("после JSON-документа есть лишние данные")
+
+// unknownFieldError — в теле запроса есть поле, которого нет в модели
+type unknownFieldError struct {
+	Field string
+}
+
+func (e *unknownFieldError) Error() string {
+	return fmt.Sprintf("неизвестное поле '%s'", e.Field)
+}
+
+// decodeJSONBody строго декодирует тело запроса в v: неизвестные поля и данные после
+// JSON-документа считаются ошибкой, чтобы опечатки в именах полей не терялись молча
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		// encoding/json не экспортирует тип ошибки неизвестного поля, только текст
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return &unknownFieldError{Field: strings.Trim(field, `"`)}
 		}
-	} else {
-		// Если data is nil, но статус не 204, это может быть намеренно (например, ошибка обработана ранее)
-		// или это может быть 204 No Content, где тело и не нужно.
-		// Для 204 WriteHeader(http.StatusNoContent) достаточно.
-		log.Printf("DEBUG: JSON ответ отправлен. Статус: %d. Тело ответа: nil (или не предполагалось)", statusCode)
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return errTrailingData
 	}
+	return nil
 }
 
-// sendErrorResponse вспомогательная функция для отправки JSON ошибки
-func sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	log.Printf("Отправка ошибки: Статус %d, Сообщение: %s", statusCode, message)
-	w.Header().Set("Content-Type", "application/json") // Убедимся, что даже ошибки в JSON
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+// validateUser проверяет пользователя по тегам validate и при ошибке отправляет 422.
+// Возвращает false, если ответ уже отправлен.
+func validateUser(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	err := validation.Struct(user)
+	if err == nil {
+		return true
+	}
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		sendValidationProblem(w, r, errs)
+	} else {
+		slog.ErrorContext(r.Context(), "Ошибка проверки пользователя", "error", err)
+		sendProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal.validate_user")
+	}
+	return false
 }
 
-func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("DEBUG: CreateUserHandler - Начало обработки")
-	if r.Method != http.MethodPost {
-		sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
-		return
+// pathUserID разбирает параметр {id} из шаблона маршрута и при ошибке отправляет 400.
+// Возвращает false, если ответ уже отправлен.
+func pathUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		slog.DebugContext(r.Context(), "Некорректный ID пользователя", "id", idStr, "error", err)
+		sendProblem(w, r, http.StatusBadRequest, CodeInvalidUserID, "detail.invalid_user_id")
+		return 0, false
 	}
+	return id, true
+}
 
+// CreateUserHandler обрабатывает POST /api/v1/users
+func (h *UserHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "CreateUserHandler: начало обработки")
 	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		log.Printf("Ошибка декодирования JSON при создании: %v. Тело запроса: %v", err, r.Body)
-		sendErrorResponse(w, http.StatusBadRequest, "Некорректное тело запроса: "+err.Error())
+	if err := decodeJSONBody(r, &user); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка декодирования JSON при создании", "error", err)
+		sendBodyError(w, r, err)
 		return
 	}
 	defer r.Body.Close() // Важно закрывать тело запроса
 
-	log.Printf("DEBUG: CreateUserHandler - Декодированные данные пользователя: %+v", user)
+	slog.DebugContext(r.Context(), "CreateUserHandler: декодированы данные пользователя", "user", user)
 
-	if user.Name == "" || user.Email == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "Имя и email обязательны")
+	if !validateUser(w, r, &user) {
 		return
 	}
 
-	id, err := h.Storage.CreateUser(&user)
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	id, err := h.Storage.CreateUser(ctx, &user)
 	if err != nil {
-		log.Printf("Ошибка h.Storage.CreateUser: %v. Пользователь: %+v", err, user)
-		sendErrorResponse(w, http.StatusInternalServerError, "Внутренняя ошибка сервера при создании пользователя")
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.CreateUser", "user", user, "error", err)
+		sendStorageError(w, r, err, "notfound.user", "internal.create_user")
 		return
 	}
 	user.ID = id // Присваиваем ID, полученный от хранилища
-	log.Printf("DEBUG: CreateUserHandler - Пользователь создан с ID: %d. Данные: %+v", id, user)
+	slog.DebugContext(r.Context(), "CreateUserHandler: пользователь создан", "user", user)
 
+	setUserETag(w, &user)
 	sendJSONResponse(w, http.StatusCreated, user)
 }
 
+// ListUsersHandler обрабатывает GET /api/v1/users: страница списка пользователей
+// с пагинацией по курсору, сортировкой и фильтрами
+func (h *UserHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "ListUsersHandler: запрос списка пользователей")
+	query, err := parseUserQuery(r)
+	if err != nil {
+		slog.DebugContext(r.Context(), "Некорректные параметры списка пользователей", "error", err)
+		sendQueryError(w, r, err)
+		return
+	}
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	page, err := h.Storage.ListUsers(ctx, query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.ListUsers", "error", err)
+		sendStorageError(w, r, err, "notfound.users", "internal.list_users")
+		return
+	}
+
+	resp := userListResponse{Users: page.Users}
+	if resp.Users == nil { // На всякий случай, хотя storage должен возвращать пустой слайс
+		resp.Users = []models.User{}
+	}
+	if page.NextCursor != nil {
+		resp.NextCursor = page.NextCursor.Encode()
+	}
+	setPaginationLinks(w, r, resp.NextCursor)
+	slog.DebugContext(r.Context(), "ListUsersHandler: получена страница пользователей", "count", len(resp.Users))
+	sendJSONResponse(w, http.StatusOK, resp)
+}
+
+// GetUserHandler обрабатывает GET /api/v1/users/{id}; с параметром as_of возвращает
+// состояние пользователя на указанный момент
 func (h *UserHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("DEBUG: GetUserHandler - Начало обработки")
-	if r.Method != http.MethodGet {
-		sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+	slog.DebugContext(r.Context(), "GetUserHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
 		return
 	}
 
-	// r.URL.Path здесь будет, например, "/api/v1/users/" или "/api/v1/users/123"
-	// `mux.HandleFunc("/api/v1/users/", ...)` уже обеспечил этот префикс
-	idStrWithSlashes := strings.TrimPrefix(r.URL.Path, "/api/v1/users") // результат: "/" или "/123" или "/123/"
-	idStr := strings.Trim(idStrWithSlashes, "/")                        // результат: "" или "123"
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		h.getUserAsOf(w, r, id, asOf)
+		return
+	}
 
-	if idStr != "" { // Запрос на конкретного пользователя
-		log.Printf("DEBUG: GetUserHandler - Запрос на пользователя по ID: '%s'", idStr)
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			log.Printf("Некорректный ID пользователя '%s': %v", idStr, err)
-			sendErrorResponse(w, http.StatusBadRequest, "Некорректный ID пользователя")
-			return
-		}
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	user, err := h.Storage.GetUserByID(ctx, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.GetUserByID", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.user", "internal.get_user")
+		return
+	}
+	slog.DebugContext(r.Context(), "GetUserHandler: найден пользователь", "user", user)
+	setUserETag(w, user)
+	if !ifNoneMatchSatisfied(r.Header.Get("If-None-Match"), user) {
+		slog.DebugContext(r.Context(), "GetUserHandler: у клиента актуальная версия пользователя", "user_id", id)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, user)
+}
 
-		user, err := h.Storage.GetUserByID(id)
-		if err != nil {
-			if strings.Contains(err.Error(), "не найден") { // Предполагаем, что storage возвращает такую строку
-				log.Printf("Пользователь с ID %d не найден в хранилище.", id)
-				sendErrorResponse(w, http.StatusNotFound, "Пользователь не найден")
-			} else {
-				log.Printf("Ошибка h.Storage.GetUserByID для ID %d: %v", id, err)
-				sendErrorResponse(w, http.StatusInternalServerError, "Внутренняя ошибка сервера при получении пользователя")
-			}
-			return
+// userListResponse тело ответа со страницей списка пользователей
+type userListResponse struct {
+	Users      []models.User `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// parseUserQuery разбирает параметры limit, cursor, sort, email_domain и name_prefix
+func parseUserQuery(r *http.Request) (storage.UserQuery, error) {
+	params := r.URL.Query()
+	var q storage.UserQuery
+
+	if limitStr := params.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return q, newQueryError("query.limit_positive")
 		}
-		log.Printf("DEBUG: GetUserHandler - Найден пользователь по ID %d: %+v", id, user)
-		sendJSONResponse(w, http.StatusOK, user)
+		if limit > storage.MaxUserLimit {
+			return q, newQueryError("query.limit_max", "max", storage.MaxUserLimit)
+		}
+		q.Limit = limit
+	}
 
-	} else { // Запрос на всех пользователей
-		log.Println("DEBUG: GetUserHandler - Запрос на ВСЕХ пользователей")
-		users, err := h.Storage.GetAllUsers()
+	field, desc, err := storage.ParseUserSort(params.Get("sort"))
+	if err != nil {
+		return q, newQueryError("query.invalid_sort", "value", params.Get("sort"))
+	}
+	q.SortField, q.SortDesc = field, desc
+
+	if cursorStr := params.Get("cursor"); cursorStr != "" {
+		cursor, err := storage.DecodeUserCursor(cursorStr)
 		if err != nil {
-			log.Printf("Ошибка h.Storage.GetAllUsers: %v", err)
-			sendErrorResponse(w, http.StatusInternalServerError, "Внутренняя ошибка сервера при получении списка пользователей")
-			return
+			return q, newQueryError("query.invalid_cursor")
 		}
+		if cursor.SortField != q.SortField || cursor.SortDesc != q.SortDesc {
+			return q, newQueryError("query.cursor_sort_mismatch")
+		}
+		q.After = cursor
+	}
 
-		if users == nil { // На всякий случай, хотя storage должен возвращать пустой слайс
-			log.Println("DEBUG: GetUserHandler - h.Storage.GetAllUsers() вернул nil, инициализируем пустым слайсом")
-			users = []models.User{}
+	q.EmailDomain = strings.TrimPrefix(params.Get("email_domain"), "@")
+	q.NamePrefix = params.Get("name_prefix")
+	return q, nil
+}
+
+// setPaginationLinks выставляет заголовок Link (RFC 8288) со ссылками на первую и следующую страницы
+func setPaginationLinks(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	pageURL := func(cursor string) string {
+		u := *r.URL
+		params := u.Query()
+		params.Del("cursor")
+		if cursor != "" {
+			params.Set("cursor", cursor)
 		}
-		log.Printf("DEBUG: GetUserHandler - Получено %d пользователей: %+v", len(users), users)
-		sendJSONResponse(w, http.StatusOK, users)
+		u.RawQuery = params.Encode()
+		return u.RequestURI()
 	}
+
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(""))}
+	if nextCursor != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(nextCursor)))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
 }
 
-func (h *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("DEBUG: UpdateUserHandler - Начало обработки")
-	if r.Method != http.MethodPut {
-		sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+// userSearchResponse тело ответа на поисковый запрос
+type userSearchResponse struct {
+	Results []models.UserSearchResult `json:"results"`
+}
+
+// SearchUsersHandler обрабатывает GET /api/v1/users/search?q=...&limit=...
+func (h *UserHandler) SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "SearchUsersHandler: начало обработки")
+	params := r.URL.Query()
+	q := strings.TrimSpace(params.Get("q"))
+	if q == "" {
+		sendProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "query.q_required")
 		return
 	}
+	limit := storage.DefaultSearchLimit
+	if limitStr := params.Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > storage.MaxSearchLimit {
+			sendProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "query.search_limit", "max", storage.MaxSearchLimit)
+			return
+		}
+	}
+	slog.DebugContext(r.Context(), "SearchUsersHandler: поиск", "q", q, "limit", limit)
 
-	idStrWithSlashes := strings.TrimPrefix(r.URL.Path, "/api/v1/users")
-	idStr := strings.Trim(idStrWithSlashes, "/")
-	if idStr == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "ID пользователя должен быть указан в пути для обновления")
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	results, err := h.Storage.SearchUsers(ctx, q, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.SearchUsers", "q", q, "error", err)
+		sendStorageError(w, r, err, "notfound.users", "internal.search_users")
 		return
 	}
-	log.Printf("DEBUG: UpdateUserHandler - Запрос на обновление пользователя по ID: '%s'", idStr)
+	slog.DebugContext(r.Context(), "SearchUsersHandler: поиск завершен", "count", len(results))
+	sendJSONResponse(w, http.StatusOK, userSearchResponse{Results: results})
+}
 
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		log.Printf("Некорректный ID пользователя для обновления '%s': %v", idStr, err)
-		sendErrorResponse(w, http.StatusBadRequest, "Некорректный ID пользователя")
+func (h *UserHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "UpdateUserHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
 		return
 	}
+	slog.DebugContext(r.Context(), "UpdateUserHandler: запрос на обновление пользователя", "user_id", id)
 
 	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		log.Printf("Ошибка декодирования JSON при обновлении: %v. Тело запроса: %v", err, r.Body)
-		sendErrorResponse(w, http.StatusBadRequest, "Некорректное тело запроса: "+err.Error())
+	if err := decodeJSONBody(r, &user); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка декодирования JSON при обновлении", "error", err)
+		sendBodyError(w, r, err)
 		return
 	}
 	defer r.Body.Close()
 	user.ID = id // Устанавливаем ID из пути, чтобы он был в объекте user
-	log.Printf("DEBUG: UpdateUserHandler - Декодированные данные для обновления пользователя ID %d: %+v", id, user)
+	slog.DebugContext(r.Context(), "UpdateUserHandler: декодированы данные для обновления", "user", user)
 
-	if user.Name == "" || user.Email == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "Имя и email обязательны при обновлении")
+	if !validateUser(w, r, &user) {
 		return
 	}
 
-	err = h.Storage.UpdateUser(&user)
-	if err != nil {
-		if strings.Contains(err.Error(), "не найден для обновления") {
-			log.Printf("Пользователь с ID %d не найден для обновления в хранилище.", id)
-			sendErrorResponse(w, http.StatusNotFound, "Пользователь не найден для обновления")
-		} else {
-			log.Printf("Ошибка h.Storage.UpdateUser для ID %d: %v. Данные: %+v", id, err, user)
-			sendErrorResponse(w, http.StatusInternalServerError, "Внутренняя ошибка сервера при обновлении пользователя")
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	var err error
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != "*" {
+		// Условное обновление: сверяем ETag с текущей версией и меняем запись,
+		// только если она не изменилась между проверкой и записью
+		var current *models.User
+		current, err = h.Storage.GetUserByID(ctx, id)
+		if err == nil {
+			if !ifMatchSatisfied(ifMatch, current) {
+				slog.DebugContext(r.Context(), "If-Match не совпадает с версией пользователя", "if_match", ifMatch, "version", current.Version, "user_id", id)
+				err = fmt.Errorf("If-Match '%s': %w", ifMatch, storage.ErrVersionMismatch)
+			} else {
+				err = h.Storage.CompareAndSwapUser(ctx, &user, current.Version)
+			}
 		}
+	} else {
+		err = h.Storage.UpdateUser(ctx, &user)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.UpdateUser", "user", user, "error", err)
+		sendStorageError(w, r, err, "notfound.user", "internal.update_user")
 		return
 	}
-	log.Printf("DEBUG: UpdateUserHandler - Пользователь ID %d успешно обновлен. Новые данные: %+v", id, user)
+	slog.DebugContext(r.Context(), "UpdateUserHandler: пользователь обновлен", "user", user)
+	setUserETag(w, &user)
 	sendJSONResponse(w, http.StatusOK, user) // Возвращаем обновленного пользователя
 }
 
-func (h *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("DEBUG: DeleteUserHandler - Начало обработки")
-	if r.Method != http.MethodDelete {
-		sendErrorResponse(w, http.StatusMethodNotAllowed, "Метод не разрешен")
+// acceptPatchTypes перечисляет форматы, принимаемые PATCH (заголовок Accept-Patch, RFC 5789)
+var acceptPatchTypes = patch.MergePatchContentType + ", " + patch.JSONPatchContentType
+
+// PatchUserHandler обрабатывает PATCH /api/v1/users/{id} с телом
+// application/merge-patch+json (RFC 7396) или application/json-patch+json (RFC 6902).
+// Патч применяется к текущему состоянию пользователя атомарно в хранилище.
+func (h *UserHandler) PatchUserHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "PatchUserHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
 		return
 	}
 
-	idStrWithSlashes := strings.TrimPrefix(r.URL.Path, "/api/v1/users")
-	idStr := strings.Trim(idStrWithSlashes, "/")
-	if idStr == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "ID пользователя должен быть указан в пути для удаления")
+	var apply func(doc, patchDoc []byte) ([]byte, error)
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case patch.MergePatchContentType:
+		apply = patch.MergePatch
+	case patch.JSONPatchContentType:
+		apply = patch.ApplyJSONPatch
+	default:
+		w.Header().Set("Accept-Patch", acceptPatchTypes)
+		sendProblem(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "detail.unsupported_patch_type", "types", acceptPatchTypes)
 		return
 	}
-	log.Printf("DEBUG: DeleteUserHandler - Запрос на удаление пользователя по ID: '%s'", idStr)
 
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	patchDoc, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
 	if err != nil {
-		log.Printf("Некорректный ID пользователя для удаления '%s': %v", idStr, err)
-		sendErrorResponse(w, http.StatusBadRequest, "Некорректный ID пользователя")
+		slog.DebugContext(r.Context(), "Ошибка чтения патча", "user_id", id, "error", err)
+		sendBodyError(w, r, err)
 		return
 	}
+	// Синтаксис патча проверяем до обращения к хранилищу
+	if contentType == patch.JSONPatchContentType {
+		_, err = patch.ParseJSONPatch(patchDoc)
+	} else if !json.Valid(patchDoc) {
+		err = fmt.Errorf("%w: тело не является JSON", patch.ErrInvalidPatch)
+	}
+	if err != nil {
+		slog.DebugContext(r.Context(), "Некорректный патч", "user_id", id, "error", err)
+		sendProblem(w, r, http.StatusBadRequest, CodeMalformedBody, "detail.malformed_body", "reason", err.Error())
+		return
+	}
+	slog.DebugContext(r.Context(), "PatchUserHandler: получен патч", "user_id", id, "content_type", contentType, "size", len(patchDoc))
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	ifMatch := r.Header.Get("If-Match")
+	user, err := h.Storage.PatchUser(ctx, id, func(user *models.User) error {
+		// Проверка внутри PatchUser: строка уже заблокирована, версия не изменится до записи
+		if !ifMatchSatisfied(ifMatch, user) {
+			return fmt.Errorf("If-Match '%s', текущая версия %d: %w", ifMatch, user.Version, storage.ErrVersionMismatch)
+		}
+		return applyUserPatch(user, patchDoc, apply)
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.PatchUser", "user_id", id, "error", err)
+		switch {
+		case errors.Is(err, patch.ErrTestFailed):
+			sendProblem(w, r, http.StatusConflict, CodePatchTestFailed, "detail.patch_test_failed", "reason", err.Error())
+		case errors.Is(err, patch.ErrCannotApply):
+			sendProblem(w, r, http.StatusUnprocessableEntity, CodePatchNotApplicable, "detail.patch_not_applicable", "reason", err.Error())
+		default:
+			sendStorageError(w, r, err, "notfound.user", "internal.patch_user")
+		}
+		return
+	}
+	slog.DebugContext(r.Context(), "PatchUserHandler: пользователь изменен", "user", user)
+	setUserETag(w, user)
+	sendJSONResponse(w, http.StatusOK, user)
+}
 
-	err = h.Storage.DeleteUser(id)
+// applyUserPatch применяет патч к JSON-представлению пользователя и проверяет результат
+func applyUserPatch(user *models.User, patchDoc []byte, apply func(doc, patchDoc []byte) ([]byte, error)) error {
+	doc, err := json.Marshal(user)
 	if err != nil {
-		if strings.Contains(err.Error(), "не найден для удаления") {
-			log.Printf("Пользователь с ID %d не найден для удаления в хранилище.", id)
-			sendErrorResponse(w, http.StatusNotFound, "Пользователь не найден для удаления")
-		} else {
-			log.Printf("Ошибка h.Storage.DeleteUser для ID %d: %v", id, err)
-			sendErrorResponse(w, http.StatusInternalServerError, "Внутренняя ошибка сервера при удалении пользователя")
+		return err
+	}
+	patched, err := apply(doc, patchDoc)
+	if err != nil {
+		return err
+	}
+
+	var result models.User
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&result); err != nil {
+		return &storage.ValidationError{Message: "результат патча не является пользователем: " + err.Error(), Err: err}
+	}
+	readOnly := func(field string) error {
+		return validation.Errors{{Field: field, Rule: "readonly", Message: "поле доступно только для чтения"}}
+	}
+	switch {
+	case result.ID != user.ID:
+		return readOnly("id")
+	case !result.CreatedAt.Equal(user.CreatedAt):
+		return readOnly("created_at")
+	case result.Version != user.Version:
+		return readOnly("version")
+	}
+	if err := validation.Struct(&result); err != nil {
+		return err
+	}
+	*user = result
+	return nil
+}
+
+func (h *UserHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "DeleteUserHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	slog.DebugContext(r.Context(), "DeleteUserHandler: запрос на удаление пользователя", "user_id", id)
+
+	// По умолчанию пользователь переносится в корзину; ?hard=true удаляет его окончательно
+	hard := false
+	if hardStr := r.URL.Query().Get("hard"); hardStr != "" {
+		var err error
+		hard, err = strconv.ParseBool(hardStr)
+		if err != nil {
+			sendProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "query.hard_bool")
+			return
 		}
+	}
+	// Окончательное удаление необратимо, поэтому кроме users:write требует users:admin
+	if principal := auth.PrincipalFromContext(r.Context()); hard && principal != nil && !principal.HasScope(auth.ScopeUsersAdmin) {
+		slog.InfoContext(r.Context(), "Недостаточно прав", "actor", principal.Actor(), "scope", auth.ScopeUsersAdmin)
+		sendProblem(w, r, http.StatusForbidden, CodeForbidden, "detail.forbidden", "scope", auth.ScopeUsersAdmin)
 		return
 	}
-	log.Printf("DEBUG: DeleteUserHandler - Пользователь ID %d успешно удален.", id)
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	var err error
+	if hard {
+		err = h.Storage.PurgeUser(ctx, id)
+	} else {
+		err = h.Storage.DeleteUser(ctx, id)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка удаления пользователя", "user_id", id, "hard", hard, "error", err)
+		sendStorageError(w, r, err, "notfound.user", "internal.delete_user")
+		return
+	}
+	slog.DebugContext(r.Context(), "DeleteUserHandler: пользователь удален", "user_id", id, "hard", hard)
 	w.WriteHeader(http.StatusNoContent)
 
 }
+
+// ListTrashHandler обрабатывает GET /api/v1/users/trash: список пользователей в корзине
+// с теми же параметрами пагинации и фильтрации, что и основной список
+func (h *UserHandler) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "ListTrashHandler: начало обработки")
+	query, err := parseUserQuery(r)
+	if err != nil {
+		slog.DebugContext(r.Context(), "Некорректные параметры списка корзины", "error", err)
+		sendQueryError(w, r, err)
+		return
+	}
+	query.Trashed = true
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	page, err := h.Storage.ListUsers(ctx, query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.ListUsers для корзины", "error", err)
+		sendStorageError(w, r, err, "notfound.users", "internal.list_trash")
+		return
+	}
+
+	resp := userListResponse{Users: page.Users}
+	if resp.Users == nil {
+		resp.Users = []models.User{}
+	}
+	if page.NextCursor != nil {
+		resp.NextCursor = page.NextCursor.Encode()
+	}
+	setPaginationLinks(w, r, resp.NextCursor)
+	slog.DebugContext(r.Context(), "ListTrashHandler: получена страница корзины", "count", len(resp.Users))
+	sendJSONResponse(w, http.StatusOK, resp)
+}
+
+// RestoreUserHandler обрабатывает POST /api/v1/users/{id}/restore: возвращает пользователя из корзины
+func (h *UserHandler) RestoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "RestoreUserHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	user, err := h.Storage.RestoreUser(ctx, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.RestoreUser", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.trashed_user", "internal.restore_user")
+		return
+	}
+	slog.DebugContext(r.Context(), "RestoreUserHandler: пользователь восстановлен из корзины", "user_id", id)
+	setUserETag(w, user)
+	sendJSONResponse(w, http.StatusOK, user)
+}
@@ -2,22 +2,52 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/casanera/GiperboreyaTechnologies/internal/middleware"
 	"github.com/casanera/GiperboreyaTechnologies/internal/models"
 	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+	"github.com/casanera/GiperboreyaTechnologies/internal/validation"
 )
 
+// routed оборачивает обработчик в ServeMux с шаблонами путей API, чтобы r.PathValue
+// возвращал {id}, {version}, {session} и {lang} так же, как при маршрутизации internal/server,
+// и в middleware.RequestID, как в цепочке сервера
+func routed(handler http.HandlerFunc) http.Handler {
+	mux := http.NewServeMux()
+	for _, pattern := range []string{
+		"/",
+		"/api/v1/users/{id}",
+		"/api/v1/users/{id}/{action}",
+		"/api/v1/users/{id}/versions/{version}",
+		"/api/v1/users/{id}/versions/{version}/revert",
+		"/api/v1/users/{id}/sessions/{session}",
+		"/api/v1/users/{id}/password/unlock",
+		"/api/v1/auth/sessions/{session}",
+		"/api/v1/i18n/{lang}",
+		"/api/v1/keys/{id}",
+		"/api/v1/keys/{id}/{action}",
+	} {
+		mux.Handle(pattern, handler)
+	}
+	return middleware.RequestID(mux)
+}
+
 // setupTest инициализирует UserHandler с MockUserStorage
 func setupTest() (*UserHandler, *storage.MockUserStorage) {
 	mockStorage := storage.NewMockUserStorage()
 	userHandler := NewUserHandler(mockStorage)
+	userHandler.Audit = mockStorage.Audit
 	return userHandler, mockStorage
 }
 
@@ -54,16 +84,57 @@ func TestCreateUserHandler(t *testing.T) {
 			name:               "Некорректный JSON",
 			inputPayload:       `{"name": "Bad JSON", "email": "bad@example.com"`,
 			expectedStatusCode: http.StatusBadRequest,
+			checkResponse:      expectProblem(CodeMalformedBody),
+		},
+		{
+			name:               "Неизвестное поле",
+			inputPayload:       `{"name": "Typo", "emial": "typo@example.com"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, rr *httptest.ResponseRecorder, _ string) {
+				resp := decodeProblem(t, rr, CodeMalformedBody)
+				if len(resp.Errors) != 1 || resp.Errors[0].Field != "emial" || resp.Errors[0].Rule != "unknown" {
+					t.Errorf("Ожидалась ошибка emial/unknown, получено %+v", resp.Errors)
+				}
+			},
+		},
+		{
+			name:               "Данные после JSON",
+			inputPayload:       `{"name": "Twice", "email": "twice@example.com"} {"name": "Again"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			checkResponse:      expectProblem(CodeMalformedBody),
+		},
+		{
+			name:               "Пробелы после JSON допустимы",
+			inputPayload:       "{\"name\": \"Spaces\", \"email\": \"spaces@example.com\"}\n\t ",
+			expectedStatusCode: http.StatusCreated,
+			expectedName:       "Spaces",
 		},
 		{
 			name:               "Пустое имя",
 			inputPayload:       `{"name": "", "email": "no-name@example.com"}`,
-			expectedStatusCode: http.StatusBadRequest,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			checkResponse:      expectFieldErrors(validation.FieldError{Field: "name", Rule: "required"}),
 		},
 		{
 			name:               "Пустой email",
 			inputPayload:       `{"name": "No Email User", "email": ""}`,
-			expectedStatusCode: http.StatusBadRequest,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			checkResponse:      expectFieldErrors(validation.FieldError{Field: "email", Rule: "required"}),
+		},
+		{
+			name:               "Некорректный email и слишком длинное имя",
+			inputPayload:       `{"name": "` + strings.Repeat("я", 101) + `", "email": "not-an-email"}`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			checkResponse: expectFieldErrors(
+				validation.FieldError{Field: "name", Rule: "max", Param: "100"},
+				validation.FieldError{Field: "email", Rule: "email"},
+			),
+		},
+		{
+			name:               "Управляющие символы в имени",
+			inputPayload:       `{"name": "Bad\u0000Name", "email": "control@example.com"}`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			checkResponse:      expectFieldErrors(validation.FieldError{Field: "name", Rule: "pattern"}),
 		},
 		{
 			name:         "Ошибка хранилища при создании",
@@ -72,6 +143,39 @@ func TestCreateUserHandler(t *testing.T) {
 				ms.SimulateError = fmt.Errorf("симулированная ошибка БД")
 			},
 			expectedStatusCode: http.StatusInternalServerError,
+			checkResponse:      expectProblem(CodeInternal),
+		},
+		{
+			name:         "Дублирующийся email",
+			inputPayload: `{"name": "Duplicate", "email": "taken@example.com"}`,
+			setupMock: func(ms *storage.MockUserStorage) {
+				ms.SeedUser(models.User{Name: "Owner", Email: "taken@example.com"})
+			},
+			expectedStatusCode: http.StatusConflict,
+			checkResponse: func(t *testing.T, rr *httptest.ResponseRecorder, _ string) {
+				resp := decodeProblem(t, rr, CodeDuplicateValue)
+				if len(resp.Errors) != 1 || resp.Errors[0].Field != "email" || resp.Errors[0].Rule != "unique" {
+					t.Errorf("Поле конфликта: ожидалось email/unique, получено %+v", resp.Errors)
+				}
+			},
+		},
+		{
+			name:         "Хранилище отвергло данные",
+			inputPayload: `{"name": "Too Long", "email": "long@example.com"}`,
+			setupMock: func(ms *storage.MockUserStorage) {
+				ms.SimulateError = &storage.ValidationError{Field: "name", Message: "значение слишком длинное"}
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			checkResponse:      expectFieldErrors(validation.FieldError{Field: "name", Rule: "storage"}),
+		},
+		{
+			name:         "Хранилище недоступно",
+			inputPayload: `{"name": "Unavailable", "email": "unavailable@example.com"}`,
+			setupMock: func(ms *storage.MockUserStorage) {
+				ms.SimulateError = fmt.Errorf("симулированный обрыв соединения: %w", storage.ErrUnavailable)
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			checkResponse:      expectProblem(CodeStorageUnavailable),
 		},
 	}
 
@@ -89,7 +193,7 @@ func TestCreateUserHandler(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 
 			rr := httptest.NewRecorder()
-			http.HandlerFunc(userHandler.CreateUserHandler).ServeHTTP(rr, req)
+			routed(userHandler.CreateUserHandler).ServeHTTP(rr, req)
 
 			if status := rr.Code; status != tc.expectedStatusCode {
 				t.Errorf("Обработчик вернул неверный статус-код: получено %v, ожидалось %v. Тело ответа: %s",
@@ -103,6 +207,62 @@ func TestCreateUserHandler(t *testing.T) {
 	}
 }
 
+// decodeProblem проверяет, что ответ — problem+json (RFC 7807) с указанным кодом,
+// статусом из ответа, адресом запроса в instance и ID запроса
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder, code ErrorCode) problem {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Content-Type ошибки: ожидалось '%s', получено '%s'", problemContentType, ct)
+	}
+	var resp problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Не удалось декодировать ответ JSON: %v", err)
+	}
+	if resp.Code != code {
+		t.Errorf("Код ошибки: ожидалось '%s', получено '%s'", code, resp.Code)
+	}
+	if resp.Status != rr.Code {
+		t.Errorf("Поле status: ожидалось %d, получено %d", rr.Code, resp.Status)
+	}
+	if resp.Type != problemTypeBase+string(code) || resp.Title == "" || resp.Detail == "" {
+		t.Errorf("Неполное описание ошибки: %+v", resp)
+	}
+	if !strings.HasPrefix(resp.Instance, "/api/v1/") {
+		t.Errorf("Поле instance должно содержать путь запроса, получено '%s'", resp.Instance)
+	}
+	if resp.RequestID == "" {
+		t.Error("Поле request_id не должно быть пустым")
+	}
+	return resp
+}
+
+// expectProblem возвращает проверку ответа problem+json с указанным кодом
+func expectProblem(code ErrorCode) func(*testing.T, *httptest.ResponseRecorder, string) {
+	return func(t *testing.T, rr *httptest.ResponseRecorder, _ string) {
+		t.Helper()
+		decodeProblem(t, rr, code)
+	}
+}
+
+// expectFieldErrors проверяет, что ответ 422 содержит ровно указанные ошибки полей.
+// Текст сообщений не сравнивается, Param — только если он задан в ожидаемой ошибке.
+func expectFieldErrors(expected ...validation.FieldError) func(*testing.T, *httptest.ResponseRecorder, string) {
+	return func(t *testing.T, rr *httptest.ResponseRecorder, _ string) {
+		t.Helper()
+		resp := decodeProblem(t, rr, CodeValidationFailed)
+		if len(resp.Errors) != len(expected) {
+			t.Fatalf("Ошибки полей: ожидалось %d, получено %+v", len(expected), resp.Errors)
+		}
+		for i, e := range expected {
+			got := resp.Errors[i]
+			if got.Field != e.Field || got.Rule != e.Rule || (e.Param != "" && got.Param != e.Param) || got.Message == "" {
+				t.Errorf("Ошибка поля %d: ожидалось %s %s=%s, получено %s %s=%s (%s)",
+					i, e.Field, e.Rule, e.Param, got.Field, got.Rule, got.Param, got.Message)
+			}
+		}
+	}
+}
+
 func TestGetUserHandler(t *testing.T) {
 	userHandler, mockStorage := setupTest()
 
@@ -113,24 +273,27 @@ func TestGetUserHandler(t *testing.T) {
 	t.Run("Получение всех пользователей", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/", nil)
 		rr := httptest.NewRecorder()
-		http.HandlerFunc(userHandler.GetUserHandler).ServeHTTP(rr, req)
+		routed(userHandler.ListUsersHandler).ServeHTTP(rr, req)
 
 		if status := rr.Code; status != http.StatusOK {
 			t.Fatalf("GetAll: неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusOK, rr.Body.String())
 		}
-		var users []models.User
-		if err := json.Unmarshal(rr.Body.Bytes(), &users); err != nil {
+		var resp userListResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
 			t.Fatalf("GetAll: не удалось декодировать JSON: %v", err)
 		}
-		if len(users) != 2 {
-			t.Errorf("GetAll: ожидалось 2 пользователя, получено %d", len(users))
+		if len(resp.Users) != 2 {
+			t.Errorf("GetAll: ожидалось 2 пользователя, получено %d", len(resp.Users))
+		}
+		if resp.NextCursor != "" {
+			t.Errorf("GetAll: для единственной страницы не ожидался next_cursor, получено '%s'", resp.NextCursor)
 		}
 	})
 
 	t.Run("Получение пользователя по ID", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/"+strconv.FormatInt(seededUser1.ID, 10), nil)
 		rr := httptest.NewRecorder()
-		http.HandlerFunc(userHandler.GetUserHandler).ServeHTTP(rr, req)
+		routed(userHandler.GetUserHandler).ServeHTTP(rr, req)
 
 		if status := rr.Code; status != http.StatusOK {
 			t.Fatalf("GetByID: неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusOK, rr.Body.String())
@@ -147,20 +310,125 @@ func TestGetUserHandler(t *testing.T) {
 	t.Run("Получение пользователя по несуществующему ID", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/999", nil)
 		rr := httptest.NewRecorder()
-		http.HandlerFunc(userHandler.GetUserHandler).ServeHTTP(rr, req)
+		routed(userHandler.GetUserHandler).ServeHTTP(rr, req)
 		if status := rr.Code; status != http.StatusNotFound {
 			t.Errorf("GetByID (not found): неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusNotFound, rr.Body.String())
 		}
+		decodeProblem(t, rr, CodeNotFound)
 	})
 
 	t.Run("Получение пользователя с некорректным ID (не число)", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/abc", nil)
 		rr := httptest.NewRecorder()
-		http.HandlerFunc(userHandler.GetUserHandler).ServeHTTP(rr, req)
+		routed(userHandler.GetUserHandler).ServeHTTP(rr, req)
 		if status := rr.Code; status != http.StatusBadRequest {
 			t.Errorf("GetByID (invalid id format): неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusBadRequest, rr.Body.String())
 		}
+		decodeProblem(t, rr, CodeInvalidUserID)
+	})
+
+	t.Run("ID запроса клиента в теле ошибки", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/999", nil)
+		req.Header.Set("X-Request-ID", "req-from-client")
+		rr := httptest.NewRecorder()
+		routed(userHandler.GetUserHandler).ServeHTTP(rr, req)
+		resp := decodeProblem(t, rr, CodeNotFound)
+		if resp.RequestID != "req-from-client" {
+			t.Errorf("request_id: ожидалось 'req-from-client', получено '%s'", resp.RequestID)
+		}
+		if resp.Instance != "/api/v1/users/999" {
+			t.Errorf("instance: ожидалось '/api/v1/users/999', получено '%s'", resp.Instance)
+		}
+	})
+
+}
+
+func TestListUsersPagination(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+	for _, u := range []models.User{
+		{Name: "Вера", Email: "vera@giperboreya.ru"},
+		{Name: "Anna", Email: "anna@example.com"},
+		{Name: "Борис", Email: "boris@giperboreya.ru"},
+		{Name: "Alex", Email: "alex@example.com"},
+		{Name: "Виктор", Email: "victor@GIPERBOREYA.ru"},
+	} {
+		mockStorage.SeedUser(u)
+	}
+
+	list := func(t *testing.T, target string) (userListResponse, *httptest.ResponseRecorder) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		routed(userHandler.ListUsersHandler).ServeHTTP(rr, req)
+		var resp userListResponse
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("не удалось декодировать JSON: %v", err)
+			}
+		}
+		return resp, rr
+	}
+
+	t.Run("Обход всех страниц по next_cursor", func(t *testing.T) {
+		var ids []int64
+		target := "/api/v1/users/?limit=2"
+		for pages := 0; target != ""; pages++ {
+			if pages > 5 {
+				t.Fatal("пагинация не завершилась")
+			}
+			resp, rr := list(t, target)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("неверный статус-код: %d. Тело: %s", rr.Code, rr.Body.String())
+			}
+			for _, u := range resp.Users {
+				ids = append(ids, u.ID)
+			}
+			target = ""
+			if resp.NextCursor != "" {
+				if link := rr.Header().Get("Link"); !strings.Contains(link, `rel="next"`) {
+					t.Errorf("заголовок Link без rel=\"next\": %s", link)
+				}
+				target = "/api/v1/users/?limit=2&cursor=" + resp.NextCursor
+			}
+		}
+		if fmt.Sprint(ids) != "[1 2 3 4 5]" {
+			t.Errorf("ожидались ID [1 2 3 4 5] по порядку, получено %v", ids)
+		}
+	})
+
+	t.Run("Сортировка по убыванию email", func(t *testing.T) {
+		resp, rr := list(t, "/api/v1/users/?sort=-email")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("неверный статус-код: %d. Тело: %s", rr.Code, rr.Body.String())
+		}
+		if len(resp.Users) != 5 || resp.Users[0].Email != "victor@GIPERBOREYA.ru" || resp.Users[4].Email != "alex@example.com" {
+			t.Errorf("неверный порядок: %+v", resp.Users)
+		}
+	})
+
+	t.Run("Фильтры по домену email и префиксу имени", func(t *testing.T) {
+		resp, _ := list(t, "/api/v1/users/?email_domain=giperboreya.ru&name_prefix=в")
+		if len(resp.Users) != 2 {
+			t.Errorf("ожидалось 2 пользователя (Вера, Виктор), получено %+v", resp.Users)
+		}
+	})
+
+	t.Run("Курсор от другой сортировки отклоняется", func(t *testing.T) {
+		first, _ := list(t, "/api/v1/users/?limit=1&sort=name")
+		_, rr := list(t, "/api/v1/users/?limit=1&sort=-name&cursor="+first.NextCursor)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("ожидался статус 400, получено %d", rr.Code)
+		}
 	})
+
+	for _, query := range []string{"limit=0", "limit=abc", "limit=1000", "sort=password", "cursor=not-a-cursor"} {
+		t.Run("Некорректный параметр "+query, func(t *testing.T) {
+			_, rr := list(t, "/api/v1/users/?"+query)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("ожидался статус 400, получено %d. Тело: %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
 }
 
 func TestUpdateUserHandler(t *testing.T) {
@@ -173,7 +441,7 @@ func TestUpdateUserHandler(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/"+strconv.FormatInt(seededUser.ID, 10), bytes.NewBufferString(updatePayload))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		http.HandlerFunc(userHandler.UpdateUserHandler).ServeHTTP(rr, req)
+		routed(userHandler.UpdateUserHandler).ServeHTTP(rr, req)
 
 		if status := rr.Code; status != http.StatusOK {
 			t.Fatalf("Update: неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusOK, rr.Body.String())
@@ -191,11 +459,24 @@ func TestUpdateUserHandler(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/999", bytes.NewBufferString(updatePayload))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		http.HandlerFunc(userHandler.UpdateUserHandler).ServeHTTP(rr, req)
+		routed(userHandler.UpdateUserHandler).ServeHTTP(rr, req)
 		if status := rr.Code; status != http.StatusNotFound {
 			t.Errorf("Update (not found): неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusNotFound, rr.Body.String())
 		}
 	})
+
+	t.Run("Обновление с email другого пользователя", func(t *testing.T) {
+		other := mockStorage.SeedUser(models.User{Name: "Other", Email: "other@example.com"})
+		payload := `{"name": "Other", "email": "new@example.com"}`
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/"+strconv.FormatInt(other.ID, 10), bytes.NewBufferString(payload))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		routed(userHandler.UpdateUserHandler).ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("Update (conflict): неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusConflict, rr.Body.String())
+		}
+		decodeProblem(t, rr, CodeDuplicateValue)
+	})
 }
 
 func TestDeleteUserHandler(t *testing.T) {
@@ -205,14 +486,14 @@ func TestDeleteUserHandler(t *testing.T) {
 	t.Run("Успешное удаление", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users/"+strconv.FormatInt(seededUser.ID, 10), nil)
 		rr := httptest.NewRecorder()
-		http.HandlerFunc(userHandler.DeleteUserHandler).ServeHTTP(rr, req)
+		routed(userHandler.DeleteUserHandler).ServeHTTP(rr, req)
 
 		if status := rr.Code; status != http.StatusNoContent {
 			t.Errorf("Delete: неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusNoContent, rr.Body.String())
 		}
 		// Проверим, что пользователь действительно удален из мока
-		_, err := mockStorage.GetUserByID(seededUser.ID)
-		if err == nil || !strings.Contains(err.Error(), "не найден") {
+		_, err := mockStorage.GetUserByID(context.Background(), seededUser.ID)
+		if !errors.Is(err, storage.ErrNotFound) {
 			t.Errorf("Delete: пользователь не был удален из хранилища")
 		}
 	})
@@ -220,9 +501,422 @@ func TestDeleteUserHandler(t *testing.T) {
 	t.Run("Удаление несуществующего пользователя", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users/999", nil)
 		rr := httptest.NewRecorder()
-		http.HandlerFunc(userHandler.DeleteUserHandler).ServeHTTP(rr, req)
+		routed(userHandler.DeleteUserHandler).ServeHTTP(rr, req)
 		if status := rr.Code; status != http.StatusNotFound { // или StatusInternalServerError, если мок возвращает общую ошибку
 			t.Errorf("Delete (not found): неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusNotFound, rr.Body.String())
 		}
 	})
 }
+
+func TestTrashHandlers(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+	trashed := mockStorage.SeedUser(models.User{Name: "Trashed", Email: "trashed@example.com"})
+	mockStorage.SeedUser(models.User{Name: "Active", Email: "active@example.com"})
+	userPath := "/api/v1/users/" + strconv.FormatInt(trashed.ID, 10)
+
+	listTrash := func(t *testing.T) []models.User {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/trash", nil)
+		rr := httptest.NewRecorder()
+		routed(userHandler.ListTrashHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Trash: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var resp userListResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Не удалось декодировать ответ JSON: %v", err)
+		}
+		return resp.Users
+	}
+	restore := func(id int64) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/users/"+strconv.FormatInt(id, 10)+"/restore", nil)
+		rr := httptest.NewRecorder()
+		routed(userHandler.RestoreUserHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, userPath, nil)
+	rr := httptest.NewRecorder()
+	routed(userHandler.DeleteUserHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Delete: неверный статус-код: получено %v, ожидалось %v", rr.Code, http.StatusNoContent)
+	}
+
+	t.Run("Удаленный пользователь в корзине, а не в списке", func(t *testing.T) {
+		users := listTrash(t)
+		if len(users) != 1 || users[0].ID != trashed.ID || users[0].DeletedAt == nil {
+			t.Fatalf("Trash: ожидался один пользователь ID %d с deleted_at, получено %+v", trashed.ID, users)
+		}
+		page, _ := mockStorage.ListUsers(context.Background(), storage.UserQuery{})
+		if len(page.Users) != 1 || page.Users[0].Email != "active@example.com" {
+			t.Errorf("List: в основном списке ожидался только активный пользователь, получено %+v", page.Users)
+		}
+	})
+
+	t.Run("Email из корзины можно занять, восстановление дает 409", func(t *testing.T) {
+		taken, err := mockStorage.CreateUser(context.Background(), &models.User{Name: "New Owner", Email: "trashed@example.com"})
+		if err != nil {
+			t.Fatalf("CreateUser: email пользователя из корзины должен быть свободен: %v", err)
+		}
+		rr := restore(trashed.ID)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Restore: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusConflict, rr.Body.String())
+		}
+		mockStorage.PurgeUser(context.Background(), taken)
+	})
+
+	t.Run("Восстановление из корзины", func(t *testing.T) {
+		rr := restore(trashed.ID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Restore: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var restored models.User
+		json.Unmarshal(rr.Body.Bytes(), &restored)
+		if restored.DeletedAt != nil || restored.Version != trashed.Version+2 {
+			t.Errorf("Restore: ожидался активный пользователь версии %d, получено %+v", trashed.Version+2, restored)
+		}
+		if etag := rr.Header().Get("ETag"); etag != userETag(&restored) {
+			t.Errorf("Restore: ETag %q не соответствует версии %d", etag, restored.Version)
+		}
+		if users := listTrash(t); len(users) != 0 {
+			t.Errorf("Trash: после восстановления корзина должна быть пуста, получено %+v", users)
+		}
+		if rr := restore(trashed.ID); rr.Code != http.StatusNotFound {
+			t.Errorf("Restore: повторное восстановление: получено %v, ожидалось %v", rr.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("Окончательное удаление", func(t *testing.T) {
+		for _, tc := range []struct {
+			query    string
+			expected int
+		}{
+			{"?hard=maybe", http.StatusBadRequest},
+			{"?hard=true", http.StatusNoContent},
+			{"?hard=true", http.StatusNotFound},
+		} {
+			req, _ := http.NewRequest(http.MethodDelete, userPath+tc.query, nil)
+			rr := httptest.NewRecorder()
+			routed(userHandler.DeleteUserHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.expected {
+				t.Errorf("Delete%s: неверный статус-код: получено %v, ожидалось %v", tc.query, rr.Code, tc.expected)
+			}
+		}
+		if rr := restore(trashed.ID); rr.Code != http.StatusNotFound {
+			t.Errorf("Restore: после окончательного удаления: получено %v, ожидалось %v", rr.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestStorageDeadlines(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+	userHandler.OperationTimeout = 20 * time.Millisecond
+	seededUser := mockStorage.SeedUser(models.User{Name: "Slow", Email: "slow@example.com"})
+
+	t.Run("Медленное хранилище возвращает 504", func(t *testing.T) {
+		mockStorage.SimulateDelay = time.Second
+		defer func() { mockStorage.SimulateDelay = 0 }()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/"+strconv.FormatInt(seededUser.ID, 10), nil)
+		rr := httptest.NewRecorder()
+		started := time.Now()
+		routed(userHandler.GetUserHandler).ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusGatewayTimeout {
+			t.Errorf("Timeout: неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, http.StatusGatewayTimeout, rr.Body.String())
+		}
+		decodeProblem(t, rr, CodeStorageTimeout)
+		if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+			t.Errorf("Timeout: обработчик ждал хранилище %s вместо того, чтобы прерваться по дедлайну", elapsed)
+		}
+	})
+
+	t.Run("Отмена запроса клиентом прерывает операцию", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		payload := `{"name": "Canceled", "email": "canceled@example.com"}`
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/users/", bytes.NewBufferString(payload))
+		rr := httptest.NewRecorder()
+		routed(userHandler.CreateUserHandler).ServeHTTP(rr, req)
+
+		page, err := mockStorage.ListUsers(context.Background(), storage.UserQuery{})
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		if len(page.Users) != 1 {
+			t.Errorf("Отмена: пользователь не должен был создаться, в хранилище %d пользователей", len(page.Users))
+		}
+	})
+
+	t.Run("Мок возвращает ошибку отмененного контекста", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := mockStorage.GetUserByID(ctx, seededUser.ID); !errors.Is(err, context.Canceled) {
+			t.Errorf("ожидалась context.Canceled, получено %v", err)
+		}
+	})
+}
+
+func TestSearchUsersHandler(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+	dzet := mockStorage.SeedUser(models.User{Name: "Кирилл Дзетовецкий", Email: "kirill@giperboreya.ru"})
+	mockStorage.SeedUser(models.User{Name: "Даниил Коноплянников", Email: "daniil@giperboreya.ru"})
+	mockStorage.SeedUser(models.User{Name: "John Smith", Email: "john.smith@example.com"})
+
+	search := func(t *testing.T, target string) (userSearchResponse, int) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		routed(userHandler.SearchUsersHandler).ServeHTTP(rr, req)
+		var resp userSearchResponse
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("не удалось декодировать JSON: %v", err)
+			}
+		}
+		return resp, rr.Code
+	}
+
+	testCases := []struct {
+		name  string
+		query string
+	}{
+		{"Префикс кириллического имени", "дзет"},
+		{"Опечатка в фамилии", "Дзетовецк"},
+		{"Латинская транслитерация", "dzetovetskiy"},
+		{"Латинский запрос с опечаткой", "Dzetovecky"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, status := search(t, "/api/v1/users/search?q="+url.QueryEscape(tc.query))
+			if status != http.StatusOK {
+				t.Fatalf("неверный статус-код: %d", status)
+			}
+			if len(resp.Results) == 0 || resp.Results[0].User.ID != dzet.ID {
+				t.Errorf("первым результатом ожидался пользователь %d, получено %+v", dzet.ID, resp.Results)
+			}
+		})
+	}
+
+	t.Run("Подсветка совпадения", func(t *testing.T) {
+		resp, _ := search(t, "/api/v1/users/search?q=john")
+		if len(resp.Results) == 0 {
+			t.Fatal("ничего не найдено")
+		}
+		if got := resp.Results[0].Highlights["name"]; got != "<mark>John</mark> Smith" {
+			t.Errorf("неверная подсветка имени: %q", got)
+		}
+	})
+
+	t.Run("Пустой запрос", func(t *testing.T) {
+		if _, status := search(t, "/api/v1/users/search?q=%20"); status != http.StatusBadRequest {
+			t.Errorf("ожидался статус 400, получено %d", status)
+		}
+	})
+
+	t.Run("Запрос без букв и цифр", func(t *testing.T) {
+		if _, status := search(t, "/api/v1/users/search?q=%2A%26"); status != http.StatusUnprocessableEntity {
+			t.Errorf("ожидался статус 422, получено %d", status)
+		}
+	})
+}
+
+func TestPatchUserHandler(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+
+	testCases := []struct {
+		name               string
+		contentType        string
+		payload            string
+		expectedStatusCode int
+		expectedCode       ErrorCode
+		expectedName       string
+		expectedEmail      string
+	}{
+		{
+			name:               "Merge Patch меняет только имя",
+			contentType:        "application/merge-patch+json",
+			payload:            `{"name": "Patched"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedName:       "Patched",
+			expectedEmail:      "patch@example.com",
+		},
+		{
+			name:               "JSON Patch с успешным test",
+			contentType:        "application/json-patch+json; charset=utf-8",
+			payload:            `[{"op": "test", "path": "/email", "value": "patch@example.com"}, {"op": "replace", "path": "/email", "value": "patched@example.com"}]`,
+			expectedStatusCode: http.StatusOK,
+			expectedName:       "Original",
+			expectedEmail:      "patched@example.com",
+		},
+		{
+			name:               "JSON Patch с неудачным test",
+			contentType:        "application/json-patch+json",
+			payload:            `[{"op": "test", "path": "/email", "value": "stale@example.com"}, {"op": "replace", "path": "/name", "value": "Lost"}]`,
+			expectedStatusCode: http.StatusConflict,
+			expectedCode:       CodePatchTestFailed,
+		},
+		{
+			name:               "Удаление обязательного поля",
+			contentType:        "application/merge-patch+json",
+			payload:            `{"name": null}`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedCode:       CodeValidationFailed,
+		},
+		{
+			name:               "Изменение ID запрещено",
+			contentType:        "application/json-patch+json",
+			payload:            `[{"op": "replace", "path": "/id", "value": 42}]`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedCode:       CodeValidationFailed,
+		},
+		{
+			name:               "Неизвестное поле",
+			contentType:        "application/merge-patch+json",
+			payload:            `{"role": "admin"}`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedCode:       CodeValidationFailed,
+		},
+		{
+			name:               "Путь не существует",
+			contentType:        "application/json-patch+json",
+			payload:            `[{"op": "remove", "path": "/phone"}]`,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedCode:       CodePatchNotApplicable,
+		},
+		{
+			name:               "Некорректный JSON Patch",
+			contentType:        "application/json-patch+json",
+			payload:            `{"op": "replace"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedCode:       CodeMalformedBody,
+		},
+		{
+			name:               "Неподдерживаемый Content-Type",
+			contentType:        "application/json",
+			payload:            `{"name": "Plain"}`,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+			expectedCode:       CodeUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStorage.Reset()
+			seeded := mockStorage.SeedUser(models.User{Name: "Original", Email: "patch@example.com"})
+
+			req, _ := http.NewRequest(http.MethodPatch, "/api/v1/users/"+strconv.FormatInt(seeded.ID, 10), bytes.NewBufferString(tc.payload))
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+			routed(userHandler.PatchUserHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tc.expectedStatusCode {
+				t.Fatalf("Patch: неверный статус-код: получено %v, ожидалось %v. Тело: %s", status, tc.expectedStatusCode, rr.Body.String())
+			}
+			stored, err := mockStorage.GetUserByID(context.Background(), seeded.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID: %v", err)
+			}
+			if tc.expectedStatusCode != http.StatusOK {
+				decodeProblem(t, rr, tc.expectedCode)
+				if stored.Name != seeded.Name || stored.Email != seeded.Email {
+					t.Errorf("Patch: пользователь изменился несмотря на ошибку: %+v", stored)
+				}
+				return
+			}
+			if stored.Name != tc.expectedName || stored.Email != tc.expectedEmail {
+				t.Errorf("Patch: ожидалось имя '%s' и email '%s', получено %+v", tc.expectedName, tc.expectedEmail, stored)
+			}
+		})
+	}
+
+	t.Run("Несуществующий пользователь", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPatch, "/api/v1/users/999", bytes.NewBufferString(`{"name": "Ghost"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		rr := httptest.NewRecorder()
+		routed(userHandler.PatchUserHandler).ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("Patch (not found): неверный статус-код: получено %v, ожидалось %v", status, http.StatusNotFound)
+		}
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+	seeded := mockStorage.SeedUser(models.User{Name: "Versioned", Email: "versioned@example.com"})
+	userURL := "/api/v1/users/" + strconv.FormatInt(seeded.ID, 10)
+
+	do := func(method, target, payload string, headers map[string]string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, target, bytes.NewBufferString(payload))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rr := httptest.NewRecorder()
+		routed(handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodGet, userURL, "", nil, userHandler.GetUserHandler)
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET: ожидался статус 200 с ETag, получено %d, ETag '%s'", rr.Code, etag)
+	}
+
+	t.Run("If-None-Match с актуальным ETag возвращает 304", func(t *testing.T) {
+		rr := do(http.MethodGet, userURL, "", map[string]string{"If-None-Match": `"999", W/` + etag}, userHandler.GetUserHandler)
+		if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+			t.Errorf("ожидался статус 304 без тела, получено %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("PUT с устаревшим If-Match возвращает 412", func(t *testing.T) {
+		rr := do(http.MethodPut, userURL, `{"name": "Lost Update", "email": "versioned@example.com"}`,
+			map[string]string{"If-Match": `"999"`}, userHandler.UpdateUserHandler)
+		if rr.Code != http.StatusPreconditionFailed {
+			t.Fatalf("ожидался статус 412, получено %d: %s", rr.Code, rr.Body.String())
+		}
+		decodeProblem(t, rr, CodeVersionMismatch)
+		stored, _ := mockStorage.GetUserByID(context.Background(), seeded.ID)
+		if stored.Name != "Versioned" {
+			t.Errorf("пользователь изменился несмотря на 412: %+v", stored)
+		}
+	})
+
+	var newETag string
+	t.Run("PUT с актуальным If-Match меняет ETag", func(t *testing.T) {
+		rr := do(http.MethodPut, userURL, `{"name": "Updated", "email": "versioned@example.com"}`,
+			map[string]string{"If-Match": etag}, userHandler.UpdateUserHandler)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("ожидался статус 200, получено %d: %s", rr.Code, rr.Body.String())
+		}
+		newETag = rr.Header().Get("ETag")
+		if newETag == "" || newETag == etag {
+			t.Errorf("ETag должен измениться после обновления: был '%s', стал '%s'", etag, newETag)
+		}
+	})
+
+	t.Run("PATCH со старым ETag возвращает 412", func(t *testing.T) {
+		rr := do(http.MethodPatch, userURL, `{"name": "Stale Patch"}`,
+			map[string]string{"If-Match": etag, "Content-Type": "application/merge-patch+json"}, userHandler.PatchUserHandler)
+		if rr.Code != http.StatusPreconditionFailed {
+			t.Errorf("ожидался статус 412, получено %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("PATCH с актуальным ETag", func(t *testing.T) {
+		rr := do(http.MethodPatch, userURL, `{"name": "Fresh Patch"}`,
+			map[string]string{"If-Match": newETag, "Content-Type": "application/merge-patch+json"}, userHandler.PatchUserHandler)
+		if rr.Code != http.StatusOK {
+			t.Errorf("ожидался статус 200, получено %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("CompareAndSwapUser в моке моделирует конфликт", func(t *testing.T) {
+		user := models.User{ID: seeded.ID, Name: "CAS", Email: "versioned@example.com"}
+		err := mockStorage.CompareAndSwapUser(context.Background(), &user, 1)
+		if !errors.Is(err, storage.ErrVersionMismatch) {
+			t.Errorf("ожидалась ErrVersionMismatch, получено %v", err)
+		}
+	})
+}
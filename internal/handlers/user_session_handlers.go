@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+	"github.com/casanera/GiperboreyaTechnologies/internal/validation"
+)
+
+// loginActor — инициатор записей аудита о блокировке, сделанных при входе: субъекта
+// у запроса входа еще нет
+const loginActor = "login"
+
+// UserSessionHandler обслуживает вход пользователей по паролю, их сессии и управление паролями
+type UserSessionHandler struct {
+	Auth *auth.PasswordAuth
+	// CookieSecure добавляет cookie сессии атрибут Secure и для запросов по HTTP
+	CookieSecure bool
+	// OperationTimeout ограничивает время одной операции с хранилищем; 0 — без ограничения
+	OperationTimeout time.Duration
+}
+
+// NewUserSessionHandler создает UserSessionHandler, проверяющий пароли с помощью a
+func NewUserSessionHandler(a *auth.PasswordAuth) *UserSessionHandler {
+	return &UserSessionHandler{Auth: a}
+}
+
+// loginRequest тело запроса на вход
+type loginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=128"`
+}
+
+// loginResponse — пользователь и открытая для него сессия
+type loginResponse struct {
+	User    *models.User   `json:"user"`
+	Session models.Session `json:"session"`
+}
+
+// passwordRequest тело запроса на установку пароля
+type passwordRequest struct {
+	Password string `json:"password" validate:"required,min=12,max=128"`
+}
+
+// userSessionResponse — сессия пользователя. Current отмечает сессию, с которой выполнен запрос.
+type userSessionResponse struct {
+	models.Session
+	Current bool `json:"current,omitempty"`
+}
+
+// userSessionListResponse тело ответа со списком сессий
+type userSessionListResponse struct {
+	Sessions []userSessionResponse `json:"sessions"`
+}
+
+// revokeSessionsResponse тело ответа на отзыв всех сессий
+type revokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// secureCookie сообщает, нужен ли cookie атрибут Secure
+func (h *UserSessionHandler) secureCookie(r *http.Request) bool {
+	return h.CookieSecure || r.TLS != nil
+}
+
+// validateRequest проверяет тело запроса по тегам validate и при ошибке отправляет 422.
+// Возвращает false, если ответ уже отправлен.
+func validateRequest(w http.ResponseWriter, r *http.Request, req interface{}, internalKey string) bool {
+	err := validation.Struct(req)
+	if err == nil {
+		return true
+	}
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		sendValidationProblem(w, r, errs)
+	} else {
+		slog.ErrorContext(r.Context(), "Ошибка проверки запроса", "error", err)
+		sendProblem(w, r, http.StatusInternalServerError, CodeInternal, internalKey)
+	}
+	return false
+}
+
+// pathSessionID разбирает параметр {session} и при ошибке отправляет 400.
+// Возвращает false, если ответ уже отправлен.
+func pathSessionID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	idStr := r.PathValue(name)
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		slog.DebugContext(r.Context(), "Некорректный ID сессии", "id", idStr, "error", err)
+		sendProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "detail.invalid_session_id")
+		return 0, false
+	}
+	return id, true
+}
+
+// clientInfo возвращает адрес и User-Agent клиента для новой сессии и ограничения попыток входа
+func clientInfo(r *http.Request) auth.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return auth.ClientInfo{IP: ip, UserAgent: r.UserAgent()}
+}
+
+// setRetryAfter задает Retry-After в секундах, округляя вверх
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// sendLoginError отправляет ответ на неудачный вход. Неизвестный email, отсутствие
+// пароля и неверный пароль дают одинаковый 401, чтобы по ответу нельзя было
+// перебирать адреса пользователей.
+func sendLoginError(w http.ResponseWriter, r *http.Request, err error, now time.Time) {
+	var locked *auth.LockedError
+	var throttled *auth.ThrottledError
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		slog.InfoContext(r.Context(), "Вход отклонен", "error", err)
+		sendProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "detail.invalid_login")
+	case errors.As(err, &locked):
+		slog.InfoContext(r.Context(), "Вход заблокирован", "locked_until", locked.Until)
+		setRetryAfter(w, locked.Until.Sub(now))
+		sendProblem(w, r, http.StatusLocked, CodeAccountLocked, "detail.account_locked")
+	case errors.As(err, &throttled):
+		slog.WarnContext(r.Context(), "Слишком много попыток входа", "remote", r.RemoteAddr)
+		setRetryAfter(w, throttled.RetryAfter)
+		sendProblem(w, r, http.StatusTooManyRequests, CodeTooManyAttempts, "detail.too_many_attempts")
+	default:
+		slog.ErrorContext(r.Context(), "Ошибка входа", "error", err)
+		sendStorageError(w, r, err, "detail.invalid_login", "internal.login")
+	}
+}
+
+// LoginHandler обрабатывает POST /api/v1/auth/login: проверяет email и пароль, открывает
+// сессию и передает ее токен в cookie
+func (h *UserSessionHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "LoginHandler: начало обработки")
+	var req loginRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка декодирования JSON при входе", "error", err)
+		sendBodyError(w, r, err)
+		return
+	}
+	defer r.Body.Close()
+	if !validateRequest(w, r, &req, "internal.login") {
+		return
+	}
+
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	ctx = storage.WithAuditInfo(ctx, storage.AuditInfo{Actor: loginActor, RequestID: requestID(r)})
+	result, err := h.Auth.Login(ctx, req.Email, req.Password, clientInfo(r))
+	if err != nil {
+		sendLoginError(w, r, err, h.Auth.Now())
+		return
+	}
+
+	http.SetCookie(w, auth.NewUserSessionCookie(result.Token, result.Session.ExpiresAt, h.secureCookie(r)))
+	slog.InfoContext(r.Context(), "Пользователь вошел", "user_id", result.User.ID, "session_id", result.Session.ID)
+	sendJSONResponse(w, http.StatusCreated, loginResponse{User: result.User, Session: *result.Session})
+}
+
+// LogoutHandler обрабатывает POST /api/v1/auth/logout: отзывает сессию из cookie и удаляет
+// cookie. Без cookie или с уже завершенной сессией тоже отвечает 204.
+func (h *UserSessionHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		sendProblem(w, r, http.StatusForbidden, CodeForbidden, "detail.cross_origin")
+		return
+	}
+	if cookie, err := r.Cookie(auth.UserSessionCookieName); err == nil {
+		ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+		defer cancel()
+		if err := h.Auth.Logout(ctx, cookie.Value); err != nil {
+			slog.ErrorContext(r.Context(), "Ошибка h.Auth.Logout", "error", err)
+			sendStorageError(w, r, err, "notfound.session", "internal.revoke_session")
+			return
+		}
+	}
+	http.SetCookie(w, auth.ExpiredUserSessionCookie(h.secureCookie(r)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listSessions отправляет действующие сессии пользователя userID; current — ID сессии запроса
+func (h *UserSessionHandler) listSessions(w http.ResponseWriter, r *http.Request, userID, current int64) {
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	sessions, err := h.Auth.Storage.ListSessions(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Auth.Storage.ListSessions", "user_id", userID, "error", err)
+		sendStorageError(w, r, err, "notfound.user", "internal.list_sessions")
+		return
+	}
+	now := h.Auth.Now()
+	resp := userSessionListResponse{Sessions: []userSessionResponse{}}
+	for _, s := range sessions {
+		// Хранилище отбрасывает истекшие сессии, а простой проверяется здесь
+		if s.Active(now, h.Auth.IdleTimeout) {
+			resp.Sessions = append(resp.Sessions, userSessionResponse{Session: s, Current: s.ID == current})
+		}
+	}
+	sendJSONResponse(w, http.StatusOK, resp)
+}
+
+// revokeSession отзывает сессию id пользователя userID
+func (h *UserSessionHandler) revokeSession(w http.ResponseWriter, r *http.Request, userID, id int64) bool {
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	if err := h.Auth.Storage.RevokeSession(ctx, userID, id); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Auth.Storage.RevokeSession", "user_id", userID, "session_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.session", "internal.revoke_session")
+		return false
+	}
+	slog.InfoContext(r.Context(), "Сессия пользователя отозвана", "user_id", userID, "session_id", id,
+		"actor", auditInfoFromRequest(r).Actor)
+	return true
+}
+
+// ListOwnSessionsHandler обрабатывает GET /api/v1/auth/sessions: сессии вошедшего пользователя
+func (h *UserSessionHandler) ListOwnSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	principal := auth.PrincipalFromContext(r.Context())
+	h.listSessions(w, r, principal.UserID, principal.SessionID)
+}
+
+// RevokeOwnSessionHandler обрабатывает DELETE /api/v1/auth/sessions/{session}: пользователь
+// завершает одну из своих сессий, например на потерянном устройстве. Отзыв текущей сессии
+// удаляет и ее cookie.
+func (h *UserSessionHandler) RevokeOwnSessionHandler(w http.ResponseWriter, r *http.Request) {
+	principal := auth.PrincipalFromContext(r.Context())
+	id, ok := pathSessionID(w, r, "session")
+	if !ok || !h.revokeSession(w, r, principal.UserID, id) {
+		return
+	}
+	if id == principal.SessionID {
+		http.SetCookie(w, auth.ExpiredUserSessionCookie(h.secureCookie(r)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListUserSessionsHandler обрабатывает GET /api/v1/users/{id}/sessions
+func (h *UserSessionHandler) ListUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	h.listSessions(w, r, id, 0)
+}
+
+// RevokeUserSessionHandler обрабатывает DELETE /api/v1/users/{id}/sessions/{session}
+func (h *UserSessionHandler) RevokeUserSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := pathSessionID(w, r, "session")
+	if !ok || !h.revokeSession(w, r, userID, id) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeUserSessionsHandler обрабатывает DELETE /api/v1/users/{id}/sessions: завершает все
+// сессии пользователя и возвращает их число
+func (h *UserSessionHandler) RevokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	revoked, err := h.Auth.Storage.RevokeSessions(ctx, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Auth.Storage.RevokeSessions", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.user", "internal.revoke_session")
+		return
+	}
+	slog.InfoContext(r.Context(), "Сессии пользователя отозваны", "user_id", id, "revoked", revoked,
+		"actor", auditInfoFromRequest(r).Actor)
+	sendJSONResponse(w, http.StatusOK, revokeSessionsResponse{Revoked: revoked})
+}
+
+// GetPasswordHandler обрабатывает GET /api/v1/users/{id}/password: задан ли пароль, сколько
+// было неудачных попыток входа и заблокирован ли вход. Хэш не возвращается.
+func (h *UserSessionHandler) GetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	cred, err := h.Auth.Storage.GetPassword(ctx, id)
+	if err != nil {
+		slog.DebugContext(r.Context(), "Ошибка h.Auth.Storage.GetPassword", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.password", "internal.get_password")
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, cred)
+}
+
+// SetPasswordHandler обрабатывает PUT /api/v1/users/{id}/password: задает или заменяет
+// пароль. Замена снимает блокировку и завершает все сессии пользователя.
+func (h *UserSessionHandler) SetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	var req passwordRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		slog.DebugContext(r.Context(), "Ошибка декодирования JSON при установке пароля", "error", err)
+		sendBodyError(w, r, err)
+		return
+	}
+	defer r.Body.Close()
+	if !validateRequest(w, r, &req, "internal.set_password") {
+		return
+	}
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка хэширования пароля", "error", err)
+		sendProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal.set_password")
+		return
+	}
+
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	ctx = storage.WithAuditInfo(ctx, auditInfoFromRequest(r))
+	if err := h.Auth.Storage.SetPassword(ctx, id, hash); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Auth.Storage.SetPassword", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.user", "internal.set_password")
+		return
+	}
+	slog.InfoContext(r.Context(), "Пароль пользователя установлен", "user_id", id, "actor", auditInfoFromRequest(r).Actor)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemovePasswordHandler обрабатывает DELETE /api/v1/users/{id}/password: пользователь больше
+// не может войти, его сессии завершаются
+func (h *UserSessionHandler) RemovePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	ctx = storage.WithAuditInfo(ctx, auditInfoFromRequest(r))
+	if err := h.Auth.Storage.RemovePassword(ctx, id); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Auth.Storage.RemovePassword", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.password", "internal.remove_password")
+		return
+	}
+	slog.InfoContext(r.Context(), "Пароль пользователя удален", "user_id", id, "actor", auditInfoFromRequest(r).Actor)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockPasswordHandler обрабатывает POST /api/v1/users/{id}/password/unlock: снимает
+// блокировку входа и сбрасывает счетчик неудачных попыток
+func (h *UserSessionHandler) UnlockPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := withTimeout(r.Context(), h.OperationTimeout)
+	defer cancel()
+	ctx = storage.WithAuditInfo(ctx, auditInfoFromRequest(r))
+	if err := h.Auth.Storage.ResetLoginFailures(ctx, id); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Auth.Storage.ResetLoginFailures", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.password", "internal.set_password")
+		return
+	}
+	slog.InfoContext(r.Context(), "Вход пользователя разблокирован", "user_id", id, "actor", auditInfoFromRequest(r).Actor)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+	"github.com/casanera/GiperboreyaTechnologies/internal/validation"
+)
+
+// setupUserSessionTest создает AuthHandler и UserSessionHandler поверх моков с пользователем
+// alice@example.com без пароля и ключом API с правами на чтение и запись пользователей
+func setupUserSessionTest(t *testing.T) (*AuthHandler, *UserSessionHandler, *storage.MockCredentialStorage, string) {
+	t.Helper()
+	a, _, secret := setupAuthTest(t)
+	users := storage.NewMockUserStorage()
+	if _, err := users.CreateUser(t.Context(), &models.User{Name: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Fatalf("CreateUser вернул ошибку: %v", err)
+	}
+	credentials := storage.NewMockCredentialStorage(users)
+	passwords := auth.NewPasswordAuth(credentials)
+	passwords.MaxFailures = 2
+	a.Authenticator.Passwords = passwords
+	return a, NewUserSessionHandler(passwords), credentials, secret
+}
+
+func TestUserSessionHandlers(t *testing.T) {
+	a, h, credentials, secret := setupUserSessionTest(t)
+
+	// do выполняет запрос с ключом API, если key не пуст, и с cookie, если cookie не nil
+	do := func(handler http.HandlerFunc, method, path, body, key string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://localhost:8080"+path, bytes.NewBufferString(body))
+		req.RemoteAddr = "10.0.0.1:51234"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		routed(handler).ServeHTTP(rr, req)
+		return rr
+	}
+	login := func(password string) *httptest.ResponseRecorder {
+		return do(h.LoginHandler, http.MethodPost, "/api/v1/auth/login",
+			`{"email": "alice@example.com", "password": "`+password+`"}`, "", nil)
+	}
+	write := func(next http.HandlerFunc) http.HandlerFunc { return a.Require(auth.ScopeUsersWrite, next) }
+	read := func(next http.HandlerFunc) http.HandlerFunc { return a.Require(auth.ScopeUsersRead, next) }
+
+	rr := login("correct horse battery")
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("вход без пароля: неверный статус-код: получено %v, ожидалось %v", rr.Code, http.StatusUnauthorized)
+	}
+	decodeProblem(t, rr, CodeUnauthorized)
+
+	rr = do(write(h.SetPasswordHandler), http.MethodPut, "/api/v1/users/1/password", `{"password": "short"}`, secret, nil)
+	expectFieldErrors(validation.FieldError{Field: "password", Rule: "min"})(t, rr, "")
+	rr = do(write(h.SetPasswordHandler), http.MethodPut, "/api/v1/users/99/password", `{"password": "correct horse battery"}`, secret, nil)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("пароль несуществующего пользователя: получено %v, ожидалось %v", rr.Code, http.StatusNotFound)
+	}
+	rr = do(write(h.SetPasswordHandler), http.MethodPut, "/api/v1/users/1/password", `{"password": "correct horse battery"}`, secret, nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("SetPassword: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusNoContent, rr.Body.String())
+	}
+	entries := credentials.Audit.(*storage.MockAuditStorage).Entries
+	if last := entries[len(entries)-1]; last.Action != models.AuditActionSetPassword || last.Actor != "api_key:1:reader" {
+		t.Errorf("установка пароля не записана в журнал аудита: %+v", last)
+	}
+
+	rr = login("correct horse battery")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("вход: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var loggedIn loginResponse
+	json.Unmarshal(rr.Body.Bytes(), &loggedIn)
+	if loggedIn.User == nil || loggedIn.User.Email != "alice@example.com" || loggedIn.Session.IP != "10.0.0.1" {
+		t.Errorf("неверный ответ на вход: %s", rr.Body.String())
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != auth.UserSessionCookieName || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("ожидалась cookie сессии HttpOnly SameSite=Strict, получено %+v", cookies)
+	}
+	session := cookies[0]
+	if bytes.Contains(rr.Body.Bytes(), []byte(session.Value)) {
+		t.Error("ответ на вход содержит токен сессии")
+	}
+
+	t.Run("Текущая сессия", func(t *testing.T) {
+		rr := do(a.GetSessionHandler, http.MethodGet, "/api/v1/auth/session", "", "", session)
+		var resp sessionResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if rr.Code != http.StatusOK || resp.Via != auth.ViaUserSession || resp.UserID != 1 || resp.SessionID != loggedIn.Session.ID {
+			t.Errorf("неверный ответ %v: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("Пользователю недоступны маршруты ключей", func(t *testing.T) {
+		rr := do(read(func(w http.ResponseWriter, r *http.Request) {}), http.MethodGet, "/api/v1/users", "", "", session)
+		decodeProblem(t, rr, CodeForbidden)
+	})
+
+	t.Run("Свои сессии", func(t *testing.T) {
+		rr := do(a.RequireUser(h.ListOwnSessionsHandler), http.MethodGet, "/api/v1/auth/sessions", "", "", session)
+		var resp userSessionListResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if rr.Code != http.StatusOK || len(resp.Sessions) != 1 || !resp.Sessions[0].Current {
+			t.Errorf("неверный список сессий %v: %s", rr.Code, rr.Body.String())
+		}
+		rr = do(a.RequireUser(h.ListOwnSessionsHandler), http.MethodGet, "/api/v1/auth/sessions", "", secret, nil)
+		decodeProblem(t, rr, CodeForbidden)
+	})
+
+	t.Run("Сессии пользователя", func(t *testing.T) {
+		other := login("correct horse battery")
+		var resp loginResponse
+		json.Unmarshal(other.Body.Bytes(), &resp)
+
+		rr := do(read(h.ListUserSessionsHandler), http.MethodGet, "/api/v1/users/1/sessions", "", secret, nil)
+		var list userSessionListResponse
+		json.Unmarshal(rr.Body.Bytes(), &list)
+		if rr.Code != http.StatusOK || len(list.Sessions) != 2 || list.Sessions[0].ID != resp.Session.ID {
+			t.Fatalf("неверный список сессий %v: %s", rr.Code, rr.Body.String())
+		}
+
+		path := "/api/v1/users/1/sessions/" + strconv.FormatInt(resp.Session.ID, 10)
+		if rr := do(write(h.RevokeUserSessionHandler), http.MethodDelete, path, "", secret, nil); rr.Code != http.StatusNoContent {
+			t.Errorf("отзыв сессии: получено %v, ожидалось %v", rr.Code, http.StatusNoContent)
+		}
+		rr = do(write(h.RevokeUserSessionHandler), http.MethodDelete, path, "", secret, nil)
+		decodeProblem(t, rr, CodeNotFound)
+		rr = do(write(h.RevokeUserSessionHandler), http.MethodDelete, "/api/v1/users/1/sessions/abc", "", secret, nil)
+		decodeProblem(t, rr, CodeInvalidParameter)
+		if rr := do(a.GetSessionHandler, http.MethodGet, "/api/v1/auth/session", "", "", other.Result().Cookies()[0]); rr.Code != http.StatusUnauthorized {
+			t.Errorf("отозванная сессия: получено %v, ожидалось %v", rr.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Блокировка", func(t *testing.T) {
+		login("wrong password")
+		rr := login("wrong password")
+		if rr.Code != http.StatusLocked || rr.Header().Get("Retry-After") != "900" {
+			t.Fatalf("ожидался ответ %v с Retry-After 900, получено %v, '%s'", http.StatusLocked, rr.Code, rr.Header().Get("Retry-After"))
+		}
+		decodeProblem(t, rr, CodeAccountLocked)
+
+		rr = do(read(h.GetPasswordHandler), http.MethodGet, "/api/v1/users/1/password", "", secret, nil)
+		var cred models.PasswordCredential
+		json.Unmarshal(rr.Body.Bytes(), &cred)
+		if rr.Code != http.StatusOK || cred.LockedUntil == nil || bytes.Contains(rr.Body.Bytes(), []byte("argon2id")) {
+			t.Errorf("неверное состояние пароля %v: %s", rr.Code, rr.Body.String())
+		}
+
+		if rr := do(write(h.UnlockPasswordHandler), http.MethodPost, "/api/v1/users/1/password/unlock", "", secret, nil); rr.Code != http.StatusNoContent {
+			t.Fatalf("разблокировка: получено %v, ожидалось %v", rr.Code, http.StatusNoContent)
+		}
+		if rr := login("correct horse battery"); rr.Code != http.StatusCreated {
+			t.Errorf("вход после разблокировки: получено %v, ожидалось %v", rr.Code, http.StatusCreated)
+		}
+	})
+
+	t.Run("Ограничение попыток", func(t *testing.T) {
+		h.Auth.Throttle = auth.NewThrottle(1, time.Minute)
+		defer func() { h.Auth.Throttle = nil }()
+		do(h.LoginHandler, http.MethodPost, "/api/v1/auth/login", `{"email": "bob@example.com", "password": "x"}`, "", nil)
+		rr := login("correct horse battery")
+		if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+			t.Fatalf("ожидался ответ %v с Retry-After 60, получено %v, '%s'", http.StatusTooManyRequests, rr.Code, rr.Header().Get("Retry-After"))
+		}
+		decodeProblem(t, rr, CodeTooManyAttempts)
+	})
+
+	t.Run("Выход", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/auth/logout", nil)
+		req.Header.Set("Origin", "https://evil.example")
+		req.AddCookie(session)
+		rr := httptest.NewRecorder()
+		h.LogoutHandler(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("выход с чужого сайта: получено %v, ожидалось %v", rr.Code, http.StatusForbidden)
+		}
+
+		rr = do(h.LogoutHandler, http.MethodPost, "/api/v1/auth/logout", "", "", session)
+		cookies := rr.Result().Cookies()
+		if rr.Code != http.StatusNoContent || len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+			t.Errorf("ожидалось удаление cookie, получено %v, %+v", rr.Code, cookies)
+		}
+		if rr := do(a.GetSessionHandler, http.MethodGet, "/api/v1/auth/session", "", "", session); rr.Code != http.StatusUnauthorized {
+			t.Errorf("сессия после выхода: получено %v, ожидалось %v", rr.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Удаление пароля", func(t *testing.T) {
+		rr := login("correct horse battery")
+		session := rr.Result().Cookies()[0]
+		rr = do(write(h.RevokeUserSessionsHandler), http.MethodDelete, "/api/v1/users/1/sessions", "", secret, nil)
+		var revoked revokeSessionsResponse
+		json.Unmarshal(rr.Body.Bytes(), &revoked)
+		if rr.Code != http.StatusOK || revoked.Revoked == 0 {
+			t.Errorf("отзыв всех сессий: получено %v: %s", rr.Code, rr.Body.String())
+		}
+		if rr := do(a.GetSessionHandler, http.MethodGet, "/api/v1/auth/session", "", "", session); rr.Code != http.StatusUnauthorized {
+			t.Errorf("сессия после отзыва всех: получено %v, ожидалось %v", rr.Code, http.StatusUnauthorized)
+		}
+
+		if rr := do(write(h.RemovePasswordHandler), http.MethodDelete, "/api/v1/users/1/password", "", secret, nil); rr.Code != http.StatusNoContent {
+			t.Fatalf("удаление пароля: получено %v, ожидалось %v", rr.Code, http.StatusNoContent)
+		}
+		rr = do(read(h.GetPasswordHandler), http.MethodGet, "/api/v1/users/1/password", "", secret, nil)
+		decodeProblem(t, rr, CodeNotFound)
+		if rr := login("correct horse battery"); rr.Code != http.StatusUnauthorized {
+			t.Errorf("вход после удаления пароля: получено %v, ожидалось %v", rr.Code, http.StatusUnauthorized)
+		}
+	})
+}
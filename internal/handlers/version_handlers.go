@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// pathVersion разбирает параметр {version} из шаблона маршрута и при ошибке отправляет 400.
+// Возвращает false, если ответ уже отправлен.
+func pathVersion(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	version, err := strconv.ParseInt(r.PathValue("version"), 10, 64)
+	if err != nil || version <= 0 {
+		sendProblem(w, r, http.StatusBadRequest, CodeInvalidVersion, "detail.invalid_version")
+		return 0, false
+	}
+	return version, true
+}
+
+// getUserAsOf отвечает на GET /api/v1/users/{id}?as_of=<RFC3339> состоянием пользователя на указанный момент.
+// ETag не выставляется: ответ описывает не текущую версию пользователя.
+func (h *UserHandler) getUserAsOf(w http.ResponseWriter, r *http.Request, id int64, asOf string) {
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		sendProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "query.as_of_format")
+		return
+	}
+	slog.DebugContext(r.Context(), "GetUserHandler: запрос состояния пользователя на момент", "user_id", id, "as_of", at)
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	user, err := h.Storage.GetUserAsOf(ctx, id, at)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.GetUserAsOf", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.user_as_of", "internal.get_user")
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, user)
+}
+
+// UserVersionsHandler обрабатывает GET /api/v1/users/{id}/versions: все версии пользователя
+func (h *UserHandler) UserVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "UserVersionsHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	versions, err := h.Storage.ListUserVersions(ctx, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.ListUserVersions", "user_id", id, "error", err)
+		sendStorageError(w, r, err, "notfound.user", "internal.list_versions")
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, versions)
+}
+
+// UserVersionHandler обрабатывает GET /api/v1/users/{id}/versions/{version}: одна версия пользователя
+func (h *UserHandler) UserVersionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "UserVersionHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	version, ok := pathVersion(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	v, err := h.Storage.GetUserVersion(ctx, id, version)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.GetUserVersion", "user_id", id, "version", version, "error", err)
+		sendStorageError(w, r, err, "notfound.version", "internal.get_version")
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, v)
+}
+
+// RevertUserHandler обрабатывает POST /api/v1/users/{id}/versions/{version}/revert: имя и email
+// из указанной версии записываются как новая версия пользователя
+func (h *UserHandler) RevertUserHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "RevertUserHandler: начало обработки")
+	id, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	version, ok := pathVersion(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.operationContext(r)
+	defer cancel()
+	user, err := h.Storage.RevertUser(ctx, id, version)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка h.Storage.RevertUser", "user_id", id, "version", version, "error", err)
+		sendStorageError(w, r, err, "notfound.user_or_version", "internal.revert_user")
+		return
+	}
+	slog.DebugContext(r.Context(), "RevertUserHandler: пользователь откачен к версии", "user_id", id, "reverted_to", version, "version", user.Version)
+	setUserETag(w, user)
+	sendJSONResponse(w, http.StatusOK, user)
+}
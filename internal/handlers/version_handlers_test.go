@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+func TestUserVersions(t *testing.T) {
+	userHandler, mockStorage := setupTest()
+
+	do := func(handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		routed(handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(userHandler.CreateUserHandler, http.MethodPost, "/api/v1/users", `{"name": "Versioned", "email": "v1@example.com"}`)
+	var created models.User
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	userPath := "/api/v1/users/" + strconv.FormatInt(created.ID, 10)
+
+	time.Sleep(time.Millisecond)
+	afterCreate := time.Now()
+	time.Sleep(time.Millisecond)
+	do(userHandler.UpdateUserHandler, http.MethodPut, userPath, `{"name": "Versioned", "email": "v2@example.com"}`)
+	time.Sleep(time.Millisecond)
+	afterUpdate := time.Now()
+
+	t.Run("Состояние на момент времени", func(t *testing.T) {
+		testCases := []struct {
+			name           string
+			asOf           string
+			expectedStatus int
+			expectedEmail  string
+		}{
+			{"После создания", afterCreate.Format(time.RFC3339Nano), http.StatusOK, "v1@example.com"},
+			{"После обновления", afterUpdate.Format(time.RFC3339Nano), http.StatusOK, "v2@example.com"},
+			{"До создания", created.CreatedAt.Add(-time.Hour).Format(time.RFC3339), http.StatusNotFound, ""},
+			{"Некорректное время", "вчера", http.StatusBadRequest, ""},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				rr := do(userHandler.GetUserHandler, http.MethodGet, userPath+"?as_of="+tc.asOf, "")
+				if rr.Code != tc.expectedStatus {
+					t.Fatalf("as_of: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, tc.expectedStatus, rr.Body.String())
+				}
+				if tc.expectedEmail == "" {
+					return
+				}
+				var user models.User
+				json.Unmarshal(rr.Body.Bytes(), &user)
+				if user.Email != tc.expectedEmail {
+					t.Errorf("as_of: email: получено '%s', ожидалось '%s'", user.Email, tc.expectedEmail)
+				}
+				if rr.Header().Get("ETag") != "" {
+					t.Errorf("as_of: для исторического состояния не должен выставляться ETag")
+				}
+			})
+		}
+	})
+
+	t.Run("Список и отдельная версия", func(t *testing.T) {
+		rr := do(userHandler.UserVersionsHandler, http.MethodGet, userPath+"/versions", "")
+		var versions []models.UserVersion
+		json.Unmarshal(rr.Body.Bytes(), &versions)
+		if rr.Code != http.StatusOK || len(versions) != 2 || versions[0].Version != 1 || versions[1].Email != "v2@example.com" {
+			t.Fatalf("Versions: статус %v, получено %+v", rr.Code, versions)
+		}
+
+		rr = do(userHandler.UserVersionHandler, http.MethodGet, userPath+"/versions/1", "")
+		var v models.UserVersion
+		json.Unmarshal(rr.Body.Bytes(), &v)
+		if rr.Code != http.StatusOK || v.Email != "v1@example.com" || v.ValidFrom.IsZero() {
+			t.Errorf("Versions/1: статус %v, получено %+v", rr.Code, v)
+		}
+		if rr := do(userHandler.UserVersionHandler, http.MethodGet, userPath+"/versions/9", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Versions/9: неверный статус-код: получено %v, ожидалось %v", rr.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("Откат к версии", func(t *testing.T) {
+		rr := do(userHandler.RevertUserHandler, http.MethodPost, userPath+"/versions/1/revert", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Revert: неверный статус-код: получено %v, ожидалось %v. Тело: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var reverted models.User
+		json.Unmarshal(rr.Body.Bytes(), &reverted)
+		if reverted.Email != "v1@example.com" || reverted.Version != 3 {
+			t.Errorf("Revert: ожидался email 'v1@example.com' в версии 3, получено %+v", reverted)
+		}
+		if len(mockStorage.Versions[created.ID]) != 3 {
+			t.Errorf("Revert: откат должен добавлять новую версию, а не переписывать историю")
+		}
+		page, _ := mockStorage.Audit.ListAuditEntries(t.Context(), storage.AuditQuery{UserID: created.ID})
+		if len(page.Entries) == 0 || page.Entries[0].Action != models.AuditActionRevert {
+			t.Errorf("Revert: в журнале аудита ожидалась запись revert, получено %+v", page.Entries)
+		}
+
+		mockStorage.SeedUser(models.User{Name: "Holder", Email: "v2@example.com"})
+		if rr := do(userHandler.RevertUserHandler, http.MethodPost, userPath+"/versions/2/revert", ""); rr.Code != http.StatusConflict {
+			t.Errorf("Revert к занятому email: получено %v, ожидалось %v", rr.Code, http.StatusConflict)
+		}
+		if rr := do(userHandler.RevertUserHandler, http.MethodPost, userPath+"/versions/0/revert", ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("Revert к версии 0: получено %v, ожидалось %v", rr.Code, http.StatusBadRequest)
+		}
+	})
+}
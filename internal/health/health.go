@@ -0,0 +1,173 @@
+// Package health отвечает на проверки состояния сервиса:
+//   - /healthz (liveness) — процесс жив и обрабатывает запросы; зависимости не проверяются;
+//   - /readyz (readiness) — сервис готов принимать трафик: все проверки зависимостей прошли
+//     и сервис не завершает работу;
+//   - /health — подробный отчет в JSON: результат и длительность каждой проверки
+//     (причины сбоев пишутся только в журнал).
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Статусы сервиса и отдельных проверок
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+)
+
+// DefaultTimeout ограничивает одну проверку, если Checker.Timeout не задан
+const DefaultTimeout = 2 * time.Second
+
+// CheckFunc проверяет одну зависимость; nil означает, что зависимость доступна
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult — результат одной проверки
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	// Error пишется только в журнал: /health доступен без аутентификации, а текст ошибки
+	// драйвера может содержать адрес, имя базы и пользователя
+	Error string `json:"-"`
+}
+
+// Report — общий результат проверок. Status — StatusOK, StatusFail или StatusDraining.
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+type namedCheck struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker хранит проверки зависимостей и признак завершения работы
+type Checker struct {
+	// Timeout ограничивает каждую проверку; 0 — DefaultTimeout
+	Timeout time.Duration
+
+	mu       sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// NewChecker создает Checker без проверок
+func NewChecker() *Checker {
+	return &Checker{Timeout: DefaultTimeout}
+}
+
+// Add регистрирует проверку зависимости name
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, fn: fn})
+}
+
+// StartDraining помечает сервис как завершающий работу: с этого момента /readyz отвечает 503,
+// и балансировщик перестает направлять на него новые запросы
+func (c *Checker) StartDraining() {
+	c.draining.Store(true)
+}
+
+// Draining сообщает, вызван ли StartDraining
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Check выполняет все проверки параллельно, каждую с ограничением Timeout
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			started := time.Now()
+			err := check.fn(checkCtx)
+			results[i] = CheckResult{
+				Name:      check.name,
+				Status:    StatusOK,
+				LatencyMS: float64(time.Since(started).Microseconds()) / 1000,
+			}
+			if err != nil {
+				results[i].Status = StatusFail
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+			slog.WarnContext(ctx, "Проверка зависимости не прошла", "check", result.Name, "error", result.Error)
+		}
+	}
+	if c.Draining() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+// LivenessHandler обслуживает /healthz: отвечает 200, пока процесс обрабатывает запросы
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	sendStatus(w, r, http.StatusOK, Report{Status: StatusOK})
+}
+
+// ReadinessHandler обслуживает /readyz: 200, если все проверки прошли, иначе 503.
+// Во время завершения работы отвечает 503 без выполнения проверок.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if c.Draining() {
+		sendStatus(w, r, http.StatusServiceUnavailable, Report{Status: StatusDraining})
+		return
+	}
+	report := c.Check(r.Context())
+	// Подробности проверок отдает только /health
+	report.Checks = nil
+	sendStatus(w, r, statusCode(report), report)
+}
+
+// HealthHandler обслуживает /health: подробный отчет о каждой зависимости
+func (c *Checker) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	sendStatus(w, r, statusCode(report), report)
+}
+
+// statusCode — 200 для исправного сервиса, 503 в остальных случаях
+func statusCode(report Report) int {
+	if report.Status == StatusOK {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+// sendStatus отправляет отчет в JSON. Ответы проверок не кэшируются.
+func sendStatus(w http.ResponseWriter, r *http.Request, statusCode int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка кодирования JSON отчета о состоянии", "error", err)
+	}
+}
@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// get выполняет GET к обработчику и разбирает отчет из ответа
+func get(t *testing.T, handler http.HandlerFunc) (int, Report) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type: ожидалось application/json, получено '%s'", ct)
+	}
+	var report Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Не удалось декодировать ответ JSON: %v. Тело: %s", err, rr.Body.String())
+	}
+	return rr.Code, report
+}
+
+func TestChecker(t *testing.T) {
+	var dbErr error
+	checker := NewChecker()
+	checker.Timeout = 50 * time.Millisecond
+	checker.Add("database", func(ctx context.Context) error { return dbErr })
+	checker.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	t.Run("Все проверки прошли", func(t *testing.T) {
+		status, report := get(t, checker.HealthHandler)
+		if status != http.StatusOK || report.Status != StatusOK || len(report.Checks) != 2 {
+			t.Fatalf("ожидался 200 и два успешных результата, получено %d %+v", status, report)
+		}
+		if report.Checks[0].Name != "database" || report.Checks[1].LatencyMS < 50 {
+			t.Errorf("результаты должны идти в порядке регистрации и содержать длительность: %+v", report.Checks)
+		}
+		if status, report := get(t, checker.ReadinessHandler); status != http.StatusOK || report.Checks != nil {
+			t.Errorf("/readyz: ожидался 200 без подробностей, получено %d %+v", status, report)
+		}
+	})
+
+	t.Run("Недоступная зависимость", func(t *testing.T) {
+		dbErr = errors.New("connection refused")
+		defer func() { dbErr = nil }()
+
+		status, report := get(t, checker.HealthHandler)
+		if status != http.StatusServiceUnavailable || report.Status != StatusFail {
+			t.Fatalf("ожидался 503 со статусом fail, получено %d %+v", status, report)
+		}
+		if report.Checks[0].Status != StatusFail {
+			t.Errorf("результат проверки database: %+v", report.Checks[0])
+		}
+		rr := httptest.NewRecorder()
+		checker.HealthHandler(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if strings.Contains(rr.Body.String(), "connection refused") {
+			t.Errorf("текст ошибки зависимости не должен попадать в ответ: %s", rr.Body.String())
+		}
+		if result := checker.Check(t.Context()).Checks[0]; result.Error != "connection refused" {
+			t.Errorf("Check: ошибка должна сохраняться для журнала, получено %+v", result)
+		}
+		if status, _ := get(t, checker.ReadinessHandler); status != http.StatusServiceUnavailable {
+			t.Errorf("/readyz: ожидался 503, получено %d", status)
+		}
+		if status, _ := get(t, checker.LivenessHandler); status != http.StatusOK {
+			t.Errorf("/healthz не должен зависеть от проверок, получено %d", status)
+		}
+	})
+
+	t.Run("Завершение работы", func(t *testing.T) {
+		checker.StartDraining()
+		if status, report := get(t, checker.ReadinessHandler); status != http.StatusServiceUnavailable || report.Status != StatusDraining {
+			t.Errorf("/readyz: ожидался 503 со статусом draining, получено %d %+v", status, report)
+		}
+		if status, _ := get(t, checker.LivenessHandler); status != http.StatusOK {
+			t.Errorf("/healthz при завершении работы: ожидался 200, получено %d", status)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+// Package i18n хранит каталог сообщений API на нескольких языках и выбирает язык
+// по заголовку Accept-Language.
+//
+// Сообщения лежат в locales/<язык>.json и встраиваются в бинарник. Заголовки ошибок
+// хранятся под ключами problem.<код ошибки>, поэтому клиент может показать сообщение
+// по коду из ответа. Подстановки в сообщениях записываются как {name} и передаются
+// парами имя-значение: Message("en", "query.limit_max", "max", 100).
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage — язык, на котором отдаются сообщения, если клиент не запросил поддерживаемый
+const DefaultLanguage = "ru"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Catalog — сообщения на всех поддерживаемых языках. После загрузки только читается,
+// поэтому безопасен для параллельного использования.
+type Catalog struct {
+	fallback string
+	messages map[string]map[string]string
+}
+
+// Load загружает каталог из файлов <язык>.json каталога dir файловой системы fsys.
+// fallback — язык, сообщения которого используются, если в выбранном языке нет ключа.
+func Load(fsys fs.FS, dir, fallback string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("i18n.Load: %w", err)
+	}
+	c := &Catalog{fallback: fallback, messages: make(map[string]map[string]string, len(files))}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("i18n.Load: %w", err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("i18n.Load: %s: %w", file, err)
+		}
+		lang := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+		c.messages[lang] = messages
+	}
+	if _, ok := c.messages[fallback]; !ok {
+		return nil, fmt.Errorf("i18n.Load: нет сообщений для языка по умолчанию '%s'", fallback)
+	}
+	return c, nil
+}
+
+// Default — каталог из встроенных файлов locales
+var Default = mustLoadDefault()
+
+func mustLoadDefault() *Catalog {
+	c, err := Load(locales, "locales", DefaultLanguage)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Languages возвращает поддерживаемые языки в алфавитном порядке
+func (c *Catalog) Languages() []string {
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Fallback возвращает язык по умолчанию
+func (c *Catalog) Fallback() string {
+	return c.fallback
+}
+
+// Negotiate выбирает язык по значению Accept-Language (RFC 9110, 12.5.4): учитываются веса q,
+// а для "en-US" подходит и "en". Если ни один язык не поддерживается, возвращается язык по умолчанию.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		ranges = append(ranges, weighted{tag: tag, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return c.fallback
+		}
+		if _, ok := c.messages[r.tag]; ok {
+			return r.tag
+		}
+		if base, _, ok := strings.Cut(r.tag, "-"); ok {
+			if _, ok := c.messages[base]; ok {
+				return base
+			}
+		}
+	}
+	return c.fallback
+}
+
+// Message возвращает сообщение key на языке lang с подстановкой args (пары имя-значение).
+// Если в lang нет такого ключа, используется язык по умолчанию, а если нет и там — сам ключ.
+func (c *Catalog) Message(lang, key string, args ...interface{}) string {
+	template, ok := c.messages[lang][key]
+	if !ok {
+		template, ok = c.messages[c.fallback][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return template
+	}
+	replacements := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		replacements = append(replacements, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(replacements...).Replace(template)
+}
+
+// Has сообщает, есть ли ключ в языке по умолчанию, а значит, найдется сообщение на любом языке
+func (c *Catalog) Has(key string) bool {
+	_, ok := c.messages[c.fallback][key]
+	return ok
+}
+
+// Messages возвращает все сообщения языка lang; отсутствующие ключи заполняются из языка
+// по умолчанию. Возвращается копия, ее можно изменять.
+func (c *Catalog) Messages(lang string) map[string]string {
+	result := make(map[string]string, len(c.messages[c.fallback]))
+	for key, message := range c.messages[c.fallback] {
+		result[key] = message
+	}
+	for key, message := range c.messages[lang] {
+		result[key] = message
+	}
+	return result
+}
+
+// Negotiate выбирает язык каталогом Default
+func Negotiate(acceptLanguage string) string {
+	return Default.Negotiate(acceptLanguage)
+}
+
+// Message возвращает сообщение из каталога Default
+func Message(lang, key string, args ...interface{}) string {
+	return Default.Message(lang, key, args...)
+}
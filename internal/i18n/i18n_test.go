@@ -0,0 +1,100 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func testCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := Load(fstest.MapFS{
+		"l/ru.json": {Data: []byte(`{"greeting": "Привет, {name}!", "only_ru": "только по-русски"}`)},
+		"l/en.json": {Data: []byte(`{"greeting": "Hello, {name}!"}`)},
+		"l/de.json": {Data: []byte(`{"greeting": "Hallo, {name}!"}`)},
+	}, "l", "ru")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return c
+}
+
+func TestNegotiate(t *testing.T) {
+	c := testCatalog(t)
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{"", "ru"},
+		{"en", "en"},
+		{"en-US,en;q=0.9", "en"},
+		{"EN-gb", "en"},
+		{"fr-FR, de;q=0.8, en;q=0.5", "de"},
+		{"en;q=0.5, de;q=0.9", "de"},
+		{"en;q=0, de;q=0.1", "de"},
+		{"fr, *;q=0.5", "ru"},
+		{"fr, ja", "ru"},
+		{"en;q=abc, de", "de"},
+	}
+	for _, tc := range testCases {
+		if got := c.Negotiate(tc.header); got != tc.expected {
+			t.Errorf("Negotiate(%q): ожидалось %s, получено %s", tc.header, tc.expected, got)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	c := testCatalog(t)
+	if got := c.Message("en", "greeting", "name", "Anna"); got != "Hello, Anna!" {
+		t.Errorf("подстановка: получено %q", got)
+	}
+	if got := c.Message("en", "only_ru"); got != "только по-русски" {
+		t.Errorf("нет ключа в языке — ожидался язык по умолчанию, получено %q", got)
+	}
+	if got := c.Message("fr", "greeting", "name", 42); got != "Привет, 42!" {
+		t.Errorf("неизвестный язык — ожидался язык по умолчанию, получено %q", got)
+	}
+	if got := c.Message("en", "missing.key"); got != "missing.key" {
+		t.Errorf("неизвестный ключ должен возвращаться как есть, получено %q", got)
+	}
+	if messages := c.Messages("en"); messages["greeting"] != "Hello, {name}!" || messages["only_ru"] != "только по-русски" {
+		t.Errorf("Messages должен дополнять язык сообщениями по умолчанию: %v", messages)
+	}
+}
+
+func TestLoadRequiresFallback(t *testing.T) {
+	_, err := Load(fstest.MapFS{"l/en.json": {Data: []byte(`{}`)}}, "l", "ru")
+	if err == nil {
+		t.Error("ожидалась ошибка: нет файла языка по умолчанию")
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// TestEmbeddedCatalogsComplete проверяет, что встроенные языки содержат одни и те же ключи
+// с одинаковыми подстановками
+func TestEmbeddedCatalogsComplete(t *testing.T) {
+	base := Default.messages[DefaultLanguage]
+	for _, lang := range Default.Languages() {
+		messages := Default.messages[lang]
+		for key, message := range base {
+			translated, ok := messages[key]
+			if !ok {
+				t.Errorf("%s: нет ключа %s", lang, key)
+				continue
+			}
+			want, got := placeholder.FindAllString(message, -1), placeholder.FindAllString(translated, -1)
+			slices.Sort(want)
+			slices.Sort(got)
+			if !slices.Equal(want, got) {
+				t.Errorf("%s: %s: подстановки %v, ожидались %v", lang, key, got, want)
+			}
+		}
+		for key := range messages {
+			if _, ok := base[key]; !ok {
+				t.Errorf("%s: лишний ключ %s, его нет в языке по умолчанию", lang, key)
+			}
+		}
+	}
+}
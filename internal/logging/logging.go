@@ -0,0 +1,177 @@
+// Package logging настраивает журнал приложения на log/slog: вывод в JSON или текстом,
+// уровень записей, атрибуты запроса из контекста и маскирование персональных данных.
+//
+// Персональные данные маскируются по умолчанию: значения атрибутов с ключами из PIIKeys
+// заменяются на "***", а в остальных строках и ошибках у адресов email скрывается имя
+// ящика. Для локальной отладки маскирование отключается полем Config.ShowPII.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Форматы вывода журнала
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Mask заменяет значение атрибута с персональными данными
+const Mask = "***"
+
+// PIIKeys — ключи атрибутов, значения которых считаются персональными данными
+// на любом уровне вложенности групп
+var PIIKeys = map[string]bool{
+	"name":        true,
+	"email":       true,
+	"q":           true,
+	"name_prefix": true,
+}
+
+// emailPattern находит адреса email в произвольном тексте
+var emailPattern = regexp.MustCompile(`[\p{L}\p{N}._%+\-]+@([\p{L}\p{N}\-]+(?:\.[\p{L}\p{N}\-]+)+)`)
+
+// Config — настройки журнала
+type Config struct {
+	// Level — минимальный уровень записей
+	Level slog.Level
+	// Format — FormatJSON (по умолчанию) или FormatText
+	Format string
+	// ShowPII отключает маскирование персональных данных; только для локальной отладки
+	ShowPII bool
+}
+
+// ParseLevel разбирает уровень журнала: debug, info, warn или error (без учета регистра)
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("logging.ParseLevel: неизвестный уровень '%s', ожидается debug, info, warn или error", s)
+	}
+	return level, nil
+}
+
+// New создает журнал, пишущий в w
+func New(w io.Writer, cfg Config) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	var h slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("logging.New: неизвестный формат '%s', ожидается %s или %s", cfg.Format, FormatJSON, FormatText)
+	}
+	if !cfg.ShowPII {
+		h = &redactHandler{next: h}
+	}
+	return slog.New(&contextHandler{next: h}), nil
+}
+
+type attrsKey struct{}
+
+// WithAttrs возвращает контекст, записи журнала в котором (slog.InfoContext и т.п.)
+// получают атрибуты attrs в дополнение к уже сохраненным в ctx
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// contextHandler добавляет к записи атрибуты, сохраненные в контексте функцией WithAttrs
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok && len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
+
+// redactHandler маскирует персональные данные в сообщении и атрибутах записи
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, RedactEmails(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr маскирует значение атрибута с ключом из PIIKeys, а в строках и ошибках скрывает email
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+	if PIIKeys[a.Key] {
+		return slog.String(a.Key, Mask)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactEmails(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, RedactEmails(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, RedactEmails(v.String()))
+		}
+	}
+	return a
+}
+
+// RedactEmails скрывает имя ящика во всех адресах email в s, оставляя домен: "***@example.com"
+func RedactEmails(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	return emailPattern.ReplaceAllString(s, Mask+"@$1")
+}
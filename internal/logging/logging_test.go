@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// newTestLogger возвращает журнал в JSON и функцию, разбирающую последнюю запись
+func newTestLogger(t *testing.T, cfg Config) (*slog.Logger, func() map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := New(&buf, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	last := func() map[string]interface{} {
+		t.Helper()
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+			t.Fatalf("запись журнала не является JSON: %v. Журнал: %s", err, buf.String())
+		}
+		return record
+	}
+	return logger, last
+}
+
+func TestRedaction(t *testing.T) {
+	user := models.User{ID: 7, Name: "Иван Петров", Email: "ivan.petrov@example.com", Version: 3}
+
+	t.Run("Персональные данные маскируются", func(t *testing.T) {
+		logger, last := newTestLogger(t, Config{})
+		logger.Info("Создан пользователь ivan.petrov@example.com", "user", user,
+			"error", errors.New(`дубликат (email)=(ivan.petrov@example.com)`), "q", "Иван")
+
+		record := last()
+		if msg := record["msg"]; msg != "Создан пользователь ***@example.com" {
+			t.Errorf("msg: получено %q", msg)
+		}
+		logged := record["user"].(map[string]interface{})
+		if logged["name"] != Mask || logged["email"] != Mask || logged["id"] != float64(7) || logged["version"] != float64(3) {
+			t.Errorf("user: получено %v", logged)
+		}
+		if record["error"] != "дубликат (email)=(***@example.com)" {
+			t.Errorf("error: получено %q", record["error"])
+		}
+		if record["q"] != Mask {
+			t.Errorf("q: получено %q", record["q"])
+		}
+	})
+
+	t.Run("Атрибуты журнала тоже маскируются", func(t *testing.T) {
+		logger, last := newTestLogger(t, Config{})
+		logger.With("email", "a@b.ru").WithGroup("req").Info("запрос", "actor", "admin@giperboreya.ru")
+		record := last()
+		if record["email"] != Mask {
+			t.Errorf("email: получено %q", record["email"])
+		}
+		if actor := record["req"].(map[string]interface{})["actor"]; actor != "***@giperboreya.ru" {
+			t.Errorf("req.actor: получено %q", actor)
+		}
+	})
+
+	t.Run("ShowPII отключает маскирование", func(t *testing.T) {
+		logger, last := newTestLogger(t, Config{ShowPII: true})
+		logger.Info("пользователь", "user", user)
+		logged := last()["user"].(map[string]interface{})
+		if logged["name"] != user.Name || logged["email"] != user.Email {
+			t.Errorf("user: получено %v", logged)
+		}
+	})
+}
+
+func TestContextAttrs(t *testing.T) {
+	logger, last := newTestLogger(t, Config{})
+	ctx := WithAttrs(context.Background(), slog.String("request_id", "req-1"))
+	ctx = WithAttrs(ctx, slog.String("path", "/api/v1/users"))
+	logger.InfoContext(ctx, "запись")
+
+	record := last()
+	if record["request_id"] != "req-1" || record["path"] != "/api/v1/users" {
+		t.Errorf("ожидались атрибуты из контекста, получено %v", record)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: slog.LevelInfo})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Debug("отладка")
+	if buf.Len() != 0 {
+		t.Errorf("запись уровня debug не должна попасть в журнал уровня info: %s", buf.String())
+	}
+
+	for input, expected := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		level, err := ParseLevel(input)
+		if err != nil || level != expected {
+			t.Errorf("ParseLevel(%q): получено %v, %v", input, level, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel: ожидалась ошибка для неизвестного уровня")
+	}
+	if _, err := New(&buf, Config{Format: "xml"}); err == nil {
+		t.Error("New: ожидалась ошибка для неизвестного формата")
+	}
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import "database/sql"
+
+// RegisterDBStats регистрирует метрики пула соединений database/sql.
+// stats обычно db.Stats; значения читаются при каждом сборе.
+func RegisterDBStats(reg *Registry, stats func() sql.DBStats) {
+	metrics := []struct {
+		name, help string
+		counter    bool
+		value      func(s sql.DBStats) float64
+	}{
+		{"db_max_open_connections", "Максимальное число открытых соединений с БД", false,
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_open_connections", "Число открытых соединений с БД", false,
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_in_use_connections", "Число занятых соединений с БД", false,
+			func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_idle_connections", "Число свободных соединений с БД", false,
+			func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"db_wait_count_total", "Число ожиданий свободного соединения с БД", true,
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_wait_duration_seconds_total", "Суммарное время ожидания свободного соединения с БД в секундах", true,
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_max_idle_closed_total", "Число соединений, закрытых из-за лимита свободных соединений", true,
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_max_idle_time_closed_total", "Число соединений, закрытых из-за времени простоя", true,
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"db_max_lifetime_closed_total", "Число соединений, закрытых из-за времени жизни", true,
+			func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, m := range metrics {
+		value := m.value
+		fn := func() float64 { return value(stats()) }
+		if m.counter {
+			reg.NewCounterFunc(m.name, m.help, fn)
+		} else {
+			reg.NewGaugeFunc(m.name, m.help, fn)
+		}
+	}
+}
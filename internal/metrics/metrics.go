@@ -0,0 +1,342 @@
+// Package metrics — минимальный реестр метрик в текстовом формате Prometheus
+// (https://prometheus.io/docs/instrumenting/exposition_formats/): счетчики, гистограммы
+// и значения, вычисляемые в момент сбора.
+//
+// Метрики создаются через Registry и могут иметь метки; значения меток передаются
+// в том же порядке, в котором метки объявлены:
+//
+//	requests := reg.NewCounterVec("http_requests_total", "Число HTTP-запросов", "method", "status")
+//	requests.WithLabelValues("GET", "200").Inc()
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType — тип ответа /metrics (текстовый формат 0.0.4)
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets — границы гистограммы длительности в секундах, от 5 мс до 10 с
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector — метрика, которую реестр выводит при сборе
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry хранит метрики и выводит их в текстовом формате. Безопасен для параллельного использования.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry создает пустой реестр
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// register добавляет метрику; повторная регистрация имени — ошибка программы
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[c.name()]; ok {
+		panic(fmt.Sprintf("metrics: метрика '%s' уже зарегистрирована", c.name()))
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteText выводит все метрики в текстовом формате Prometheus, упорядочив их по имени
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]collector, len(names))
+	for i, name := range names {
+		collectors[i] = r.collectors[name]
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler возвращает обработчик для GET /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		// Ошибка записи означает разрыв соединения: ответ уже начат, сообщить о ней клиенту нельзя
+		_ = r.WriteText(w)
+	})
+}
+
+// desc — общее описание метрики с метками
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (d *desc) name() string { return d.metricName }
+
+// writeHeader выводит строки HELP и TYPE
+func (d *desc) writeHeader(w *bufio.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, metricType)
+}
+
+// labelKey объединяет значения меток в ключ карты серий
+func (d *desc) labelKey(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: метрика '%s' ожидает %d меток, передано %d", d.metricName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels выводит метки серии в виде {a="1",b="2"}; extra добавляется последней (например, le)
+func (d *desc) formatLabels(values []string, extraName, extraValue string) string {
+	if len(d.labels) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, label, escapeLabelValue(values[i]))
+	}
+	if extraName != "" {
+		if len(d.labels) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// CounterVec — набор счетчиков с метками
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*Counter
+}
+
+// Counter — монотонно растущий счетчик
+type Counter struct {
+	labelValues []string
+	mu          sync.Mutex
+	value       float64
+}
+
+// NewCounterVec регистрирует счетчик с метками labels
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{metricName: name, help: help, labels: labels}, series: make(map[string]*Counter)}
+	r.register(c)
+	return c
+}
+
+// WithLabelValues возвращает счетчик для значений меток, создавая его при первом обращении
+func (c *CounterVec) WithLabelValues(values ...string) *Counter {
+	key := c.labelKey(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter, ok := c.series[key]
+	if !ok {
+		counter = &Counter{labelValues: append([]string(nil), values...)}
+		c.series[key] = counter
+	}
+	return counter
+}
+
+// Inc увеличивает счетчик на 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add увеличивает счетчик на v; отрицательные значения игнорируются
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+	c.mu.Lock()
+	series := sortedSeries(c.series)
+	c.mu.Unlock()
+	for _, counter := range series {
+		counter.mu.Lock()
+		value := counter.value
+		counter.mu.Unlock()
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.formatLabels(counter.labelValues, "", ""), formatFloat(value))
+	}
+}
+
+// HistogramVec — набор гистограмм с метками
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*Histogram
+}
+
+// Histogram считает наблюдения по корзинам с верхними границами buckets
+type Histogram struct {
+	labelValues []string
+	buckets     []float64
+	mu          sync.Mutex
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec регистрирует гистограмму; buckets — возрастающие верхние границы корзин,
+// nil означает DefaultBuckets
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: границы гистограммы '%s' должны возрастать", name))
+	}
+	h := &HistogramVec{
+		desc:    desc{metricName: name, help: help, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*Histogram),
+	}
+	r.register(h)
+	return h
+}
+
+// WithLabelValues возвращает гистограмму для значений меток, создавая ее при первом обращении
+func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	key := h.labelKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	histogram, ok := h.series[key]
+	if !ok {
+		histogram = &Histogram{
+			labelValues: append([]string(nil), values...),
+			buckets:     h.buckets,
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = histogram
+	}
+	return histogram
+}
+
+// Observe добавляет наблюдение v
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+	h.mu.Lock()
+	series := sortedSeries(h.series)
+	h.mu.Unlock()
+	for _, histogram := range series {
+		histogram.mu.Lock()
+		counts := append([]uint64(nil), histogram.counts...)
+		count, sum := histogram.count, histogram.sum
+		histogram.mu.Unlock()
+
+		// Корзины в формате Prometheus накопительные: le="0.1" включает все наблюдения <= 0.1
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.formatLabels(histogram.labelValues, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.formatLabels(histogram.labelValues, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.formatLabels(histogram.labelValues, "", ""), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.formatLabels(histogram.labelValues, "", ""), count)
+	}
+}
+
+// valueFunc — метрика без меток, значение которой вычисляется при сборе
+type valueFunc struct {
+	desc
+	metricType string
+	fn         func() float64
+}
+
+// NewGaugeFunc регистрирует показатель, значение которого возвращает fn в момент сбора
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{desc: desc{metricName: name, help: help}, metricType: "gauge", fn: fn})
+}
+
+// NewCounterFunc регистрирует счетчик, значение которого ведется вне реестра (например, в sql.DBStats)
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&valueFunc{desc: desc{metricName: name, help: help}, metricType: "counter", fn: fn})
+}
+
+func (v *valueFunc) write(w *bufio.Writer) {
+	v.writeHeader(w, v.metricType)
+	fmt.Fprintf(w, "%s %s\n", v.metricName, formatFloat(v.fn()))
+}
+
+// labeled — серия метрики с метками
+type labeled interface {
+	*Counter | *Histogram
+}
+
+// sortedSeries возвращает серии, упорядоченные по значениям меток, чтобы вывод был стабильным
+func sortedSeries[T labeled](series map[string]T) []T {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]T, len(keys))
+	for i, key := range keys {
+		result[i] = series[key]
+	}
+	return result
+}
+
+// formatFloat выводит число так, как его понимает Prometheus
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("requests_total", "Число запросов", "method", "path")
+	requests.WithLabelValues("GET", `/a"b\c`).Inc()
+	requests.WithLabelValues("GET", `/a"b\c`).Add(2)
+	requests.WithLabelValues("DELETE", "/").Inc()
+	requests.WithLabelValues("DELETE", "/").Add(-5) // счетчик не уменьшается
+
+	duration := reg.NewHistogramVec("duration_seconds", "Длительность\nзапроса", []float64{0.1, 1}, "method")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		duration.WithLabelValues("GET").Observe(v)
+	}
+	reg.NewGaugeFunc("temperature", "Температура", func() float64 { return -1.5 })
+
+	var b strings.Builder
+	if err := reg.WriteText(&b); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	expected := `# HELP duration_seconds Длительность\nзапроса
+# TYPE duration_seconds histogram
+duration_seconds_bucket{method="GET",le="0.1"} 2
+duration_seconds_bucket{method="GET",le="1"} 3
+duration_seconds_bucket{method="GET",le="+Inf"} 4
+duration_seconds_sum{method="GET"} 3.65
+duration_seconds_count{method="GET"} 4
+# HELP requests_total Число запросов
+# TYPE requests_total counter
+requests_total{method="DELETE",path="/"} 1
+requests_total{method="GET",path="/a\"b\\c"} 3
+# HELP temperature Температура
+# TYPE temperature gauge
+temperature -1.5
+`
+	if got := b.String(); got != expected {
+		t.Errorf("вывод метрик:\nожидалось:\n%s\nполучено:\n%s", expected, got)
+	}
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: ожидалась паника", name)
+			}
+		}()
+		f()
+	}
+
+	reg := NewRegistry()
+	counter := reg.NewCounterVec("requests_total", "Число запросов", "method")
+	expectPanic("повторная регистрация", func() { reg.NewCounterVec("requests_total", "Число запросов") })
+	expectPanic("неверное число меток", func() { counter.WithLabelValues("GET", "200") })
+	expectPanic("неупорядоченные корзины", func() { reg.NewHistogramVec("h", "h", []float64{1, 0.5}) })
+}
+
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	RegisterDBStats(reg, func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 2, Idle: 1, WaitCount: 4, WaitDuration: 1500 * time.Millisecond}
+	})
+
+	rr := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type: ожидалось '%s', получено '%s'", ContentType, ct)
+	}
+	for _, want := range []string{
+		"# TYPE db_open_connections gauge\ndb_open_connections 3\n",
+		"db_in_use_connections 2\n",
+		"db_max_open_connections 10\n",
+		"# TYPE db_wait_count_total counter\ndb_wait_count_total 4\n",
+		"db_wait_duration_seconds_total 1.5\n",
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("в ответе нет %q:\n%s", want, rr.Body.String())
+		}
+	}
+}
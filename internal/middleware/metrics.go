@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/metrics"
+)
+
+// unmatchedRoute — метка маршрута для запросов, не совпавших ни с одним шаблоном
+const unmatchedRoute = "unmatched"
+
+// knownMethods — методы, попадающие в метки как есть; остальные считаются как OTHER,
+// чтобы произвольные методы клиентов не плодили серии
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// Metrics регистрирует в reg метрики HTTP и считает запросы по методу, маршруту и статусу,
+// а также их длительность.
+//
+// Маршрут — шаблон http.ServeMux без метода ("/api/v1/users/{id}"), а не путь запроса, поэтому
+// число серий не зависит от ID в URL. ServeMux записывает шаблон в r.Pattern того же *http.Request,
+// который получил, поэтому между Metrics и ServeMux не должно быть оберток, заменяющих запрос
+// (r.WithContext); RequestID нужно ставить раньше Metrics.
+func Metrics(reg *metrics.Registry) Middleware {
+	requests := reg.NewCounterVec("http_requests_total",
+		"Число HTTP-запросов по методу, маршруту и статусу", "method", "route", "status")
+	duration := reg.NewHistogramVec("http_request_duration_seconds",
+		"Длительность обработки HTTP-запросов в секундах", nil, "method", "route")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			rec := wrapResponseWriter(w)
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			method := r.Method
+			if !knownMethods[method] {
+				method = "OTHER"
+			}
+			route := routeLabel(r.Pattern)
+			requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+			duration.WithLabelValues(method, route).Observe(time.Since(started).Seconds())
+		})
+	}
+}
+
+// routeLabel убирает метод из шаблона ServeMux: "GET /api/v1/users/{id}" -> "/api/v1/users/{id}"
+func routeLabel(pattern string) string {
+	if pattern == "" {
+		return unmatchedRoute
+	}
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i+1:], " \t")
+	}
+	return pattern
+}
@@ -0,0 +1,193 @@
+// Package middleware содержит обертки http.Handler, общие для всех маршрутов:
+// ID запроса, трассировка, восстановление после паники, журнал доступа, метрики и ограничение
+// размера тела.
+//
+// Обертки собираются функцией Chain; первая в списке выполняется первой:
+//
+//	handler := middleware.Chain(mux, middleware.RequestID, middleware.AccessLog, ...)
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/logging"
+)
+
+// RequestIDHeader — заголовок с ID запроса; клиент может передать свой ID, сервер возвращает его в ответе
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину ID, принимаемого от клиента
+const maxRequestIDLength = 128
+
+// Middleware оборачивает обработчик дополнительной логикой
+type Middleware func(http.Handler) http.Handler
+
+// Chain оборачивает h в middlewares так, что первая обертка получает запрос первой
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+type requestIDKey struct{}
+
+// RequestIDFromContext возвращает ID запроса, сохраненный RequestID, или пустую строку
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID берет ID запроса из X-Request-ID или генерирует новый, если клиент его не передал
+// или передал некорректный. ID сохраняется в контексте и в заголовке запроса для обработчиков,
+// добавляется к записям журнала с контекстом запроса и возвращается клиенту в заголовке ответа.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get(RequestIDHeader))
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		r.Header.Set(RequestIDHeader, id)
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		// Все записи журнала, сделанные с контекстом запроса, получают его ID, метод и путь
+		ctx = logging.WithAttrs(ctx,
+			slog.String("request_id", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+		)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID допускает непустые ID разумной длины из букв, цифр и символов "-_.:",
+// чтобы значение от клиента можно было безопасно писать в журналы и заголовки
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID возвращает случайный идентификатор запроса
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder запоминает статус и размер ответа для журнала доступа и Recover
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// wrapResponseWriter возвращает statusRecorder, не оборачивая его повторно
+func wrapResponseWriter(w http.ResponseWriter) *statusRecorder {
+	if rec, ok := w.(*statusRecorder); ok {
+		return rec
+	}
+	return &statusRecorder{ResponseWriter: w}
+}
+
+// AccessLog пишет в журнал запись на каждый запрос: статус, размер ответа, длительность
+// и адрес клиента; ID запроса, метод и путь добавляет RequestID через контекст
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		rec := wrapResponseWriter(w)
+		next.ServeHTTP(rec, r)
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []slog.Attr{
+			slog.Int("status", status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(started)),
+			slog.String("remote", r.RemoteAddr),
+		}
+		// Без RequestID в контексте нет метода и пути, добавляем их явно
+		if RequestIDFromContext(r.Context()) == "" {
+			attrs = append(attrs, slog.String("method", r.Method), slog.String("path", r.URL.Path))
+		}
+		slog.LogAttrs(r.Context(), slog.LevelInfo, "access", attrs...)
+	})
+}
+
+// Recover перехватывает панику в обработчике, пишет ее в журнал со стеком и, если ответ
+// еще не начат, отвечает через onPanic (обычно 500 в формате problem+json).
+// http.ErrAbortHandler пробрасывается дальше: им обработчик сознательно обрывает ответ.
+func Recover(onPanic http.HandlerFunc) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := wrapResponseWriter(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				slog.ErrorContext(r.Context(), "Паника при обработке запроса",
+					"panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+				if rec.status == 0 {
+					onPanic(rec, r)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// MaxBodySize ограничивает тело запроса limit байтами: чтение сверх лимита возвращает
+// *http.MaxBytesError, и обработчик отвечает 413
+func MaxBodySize(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/logging"
+)
+
+// captureLog перенаправляет журнал slog по умолчанию в буфер на время теста
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Config{Format: logging.FormatText})
+	if err != nil {
+		t.Fatalf("logging.New: %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mark("first"), mark("second"))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(order, ","); got != "first,second,handler" {
+		t.Errorf("порядок вызова: ожидалось first,second,handler, получено %s", got)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seenInContext, seenInHeader string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenInContext = RequestIDFromContext(r.Context())
+		seenInHeader = r.Header.Get(RequestIDHeader)
+	}))
+
+	testCases := []struct {
+		name     string
+		clientID string
+		keep     bool
+	}{
+		{"ID клиента сохраняется", "req-42.a:b_c", true},
+		{"ID генерируется, если не передан", "", false},
+		{"Небезопасный ID заменяется", "bad id\r\nX-Injected: 1", false},
+		{"Слишком длинный ID заменяется", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.clientID != "" {
+				req.Header.Set(RequestIDHeader, tc.clientID)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(RequestIDHeader)
+			if got == "" || got != seenInContext || got != seenInHeader {
+				t.Fatalf("ID в ответе '%s', в контексте '%s', в заголовке запроса '%s'", got, seenInContext, seenInHeader)
+			}
+			if tc.keep && got != tc.clientID {
+				t.Errorf("ожидался ID клиента '%s', получено '%s'", tc.clientID, got)
+			}
+			if !tc.keep && (got == tc.clientID || len(got) != 32) {
+				t.Errorf("ожидался сгенерированный ID, получено '%s'", got)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	buf := captureLog(t)
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "hello")
+	}), RequestID, AccessLog)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := buf.String()
+	for _, want := range []string{"method=POST", "path=/api/v1/users", "status=418", "bytes=5", "duration=", "request_id=req-1"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("в журнале доступа нет '%s': %s", want, line)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	onPanic := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "recovered")
+	}
+
+	t.Run("Паника превращается в 500 и попадает в журнал доступа", func(t *testing.T) {
+		buf := captureLog(t)
+		h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("сбой обработчика")
+		}), RequestID, AccessLog, Recover(onPanic))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/boom", nil))
+		if rr.Code != http.StatusInternalServerError || rr.Body.String() != "recovered" {
+			t.Fatalf("ожидался ответ onPanic, получено %d %q", rr.Code, rr.Body.String())
+		}
+		if !strings.Contains(buf.String(), "сбой обработчика") || !strings.Contains(buf.String(), "status=500") {
+			t.Errorf("в журнале нет паники или статуса 500: %s", buf.String())
+		}
+	})
+
+	t.Run("Начатый ответ не перезаписывается", func(t *testing.T) {
+		captureLog(t)
+		h := Recover(onPanic)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("после заголовков")
+		}))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != http.StatusAccepted || rr.Body.Len() != 0 {
+			t.Errorf("ожидался исходный статус 202 без тела, получено %d %q", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("ErrAbortHandler пробрасывается", func(t *testing.T) {
+		h := Recover(onPanic)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("ожидалась паника http.ErrAbortHandler, получено %v", p)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestMaxBodySize(t *testing.T) {
+	var readErr error
+	h := MaxBodySize(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345678")))
+	if readErr != nil {
+		t.Fatalf("тело в пределах лимита: неожиданная ошибка %v", readErr)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("123456789")))
+	var tooLarge *http.MaxBytesError
+	if !errors.As(readErr, &tooLarge) || tooLarge.Limit != 8 {
+		t.Errorf("ожидалась *http.MaxBytesError с лимитом 8, получено %v", readErr)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	buf := captureLog(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "обработка")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	h := Chain(mux, RequestID, Tracing)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ожидался 1 спан, получено %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /users/{id}" {
+		t.Errorf("имя спана: ожидалось 'GET /users/{id}', получено '%s'", span.Name())
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" || !span.Parent().IsRemote() {
+		t.Errorf("родитель спана: ожидался спан из traceparent, получено '%s'", got)
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("статус спана для ответа 503: ожидался Error, получено %v", span.Status().Code)
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, a := range span.Attributes() {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["http.route"].AsString() != "/users/{id}" || attrs["http.response.status_code"].AsInt64() != 503 {
+		t.Errorf("атрибуты спана: получено %v", span.Attributes())
+	}
+	if !strings.Contains(buf.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("в журнале нет trace_id: %s", buf.String())
+	}
+}
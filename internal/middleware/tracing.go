@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/logging"
+)
+
+// tracer создает спаны входящих запросов через глобальный TracerProvider (см. пакет tracing)
+var tracer = otel.Tracer("github.com/casanera/GiperboreyaTechnologies/internal/middleware")
+
+// Tracing открывает серверный спан на каждый запрос. Родительский контекст берется из
+// заголовка traceparent, если клиент его передал. ID трассировки и спана добавляются к записям
+// журнала с контекстом запроса.
+//
+// Имя спана — метод и шаблон маршрута ("GET /api/v1/users/{id}"). Шаблон читается из r.Pattern
+// запроса, переданного дальше, поэтому, как и для Metrics, между Tracing и ServeMux не должно
+// быть оберток, заменяющих запрос.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", r.RemoteAddr),
+			),
+		)
+		defer span.End()
+		if id := RequestIDFromContext(ctx); id != "" {
+			span.SetAttributes(attribute.String("request_id", id))
+		}
+		if sc := span.SpanContext(); sc.IsValid() {
+			ctx = logging.WithAttrs(ctx,
+				slog.String("trace_id", sc.TraceID().String()),
+				slog.String("span_id", sc.SpanID().String()),
+			)
+		}
+
+		rec := wrapResponseWriter(w)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if route := routeLabel(r.Pattern); route != unmatchedRoute {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
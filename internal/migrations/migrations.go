@@ -0,0 +1,311 @@
+// Package migrations содержит версионированные миграции схемы БД и их исполнитель.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var embeddedFiles embed.FS
+
+// advisoryLockID — ключ pg_advisory_lock, под которым выполняются миграции.
+// Благодаря ему несколько реплик backend, стартующих одновременно, не мешают друг другу.
+const advisoryLockID int64 = 7_340_581_220_114
+
+// Migration описывает одну миграцию схемы
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status описывает состояние миграции в конкретной базе данных
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator применяет и откатывает миграции в PostgreSQL
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New создает Migrator со встроенными в бинарник миграциями
+func New(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(embeddedFiles, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("migrations.New: %w", err)
+	}
+	list, err := Load(sub)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: list}, nil
+}
+
+// Load читает миграции из fsys. Файлы должны называться
+// <версия>_<имя>.up.sql и <версия>_<имя>.down.sql; результат упорядочен по версии.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("migrations.Load: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		fileName := entry.Name()
+		base := strings.TrimSuffix(fileName, ".sql")
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migrations.Load: файл %s должен оканчиваться на .up.sql или .down.sql", fileName)
+		}
+		base = strings.TrimSuffix(base, "."+direction)
+
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("migrations.Load: файл %s должен называться <версия>_<имя>.%s.sql", fileName, direction)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrations.Load: некорректная версия в имени файла %s", fileName)
+		}
+
+		content, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("migrations.Load: не удалось прочитать %s: %w", fileName, err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migrations.Load: версия %d используется миграциями '%s' и '%s'", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migrations.Load: у миграции %d_%s нет up-скрипта", m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migrations.Load: у миграции %d_%s нет down-скрипта", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Up применяет все еще не примененные миграции и возвращает список примененных
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.Migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			slog.InfoContext(ctx, "Применение миграции", "version", mig.Version, "migration", mig.Name)
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", mig.Version, mig.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migrations.Up: миграция %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down откатывает последние steps примененных миграций в обратном порядке
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("migrations.Down: количество шагов должно быть положительным, получено %d", steps)
+	}
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.Migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := m.Migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			slog.InfoContext(ctx, "Откат миграции", "version", mig.Version, "migration", mig.Name)
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migrations.Down: миграция %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status возвращает состояние каждой известной миграции
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var result []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.Migrations {
+			appliedAt, ok := done[mig.Version]
+			result = append(result, Status{Migration: mig, Applied: ok, AppliedAt: appliedAt})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Pending возвращает количество еще не примененных миграций
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// CheckApplied возвращает ошибку, если не все известные миграции применены. В отличие от
+// Pending, не захватывает advisory lock и не создает schema_migrations, поэтому подходит
+// для частых проверок готовности сервиса.
+func (m *Migrator) CheckApplied(ctx context.Context) error {
+	done, err := appliedVersions(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, mig := range m.Migrations {
+		if _, ok := done[mig.Version]; !ok {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("migrations: не применено миграций: %d", pending)
+	}
+	return nil
+}
+
+// withLock выполняет fn на выделенном соединении под advisory lock.
+// Блокировка сессионная, поэтому все запросы должны идти через одно соединение.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrations: не удалось получить соединение: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+		return fmt.Errorf("migrations: не удалось захватить advisory lock: %w", err)
+	}
+	defer func() {
+		// Используем отдельный контекст: исходный может быть уже отменен
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1)", advisoryLockID); err != nil {
+			slog.Error("Не удалось освободить advisory lock миграций", "error", err)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version BIGINT PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );`)
+	if err != nil {
+		return fmt.Errorf("migrations: не удалось создать таблицу schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// querier — общий интерфейс *sql.DB и *sql.Conn
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func appliedVersions(ctx context.Context, conn querier) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("migrations: не удалось прочитать schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("migrations: ошибка сканирования schema_migrations: %w", err)
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Миграции упорядочены по версии", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"0002_add_column.up.sql":     {Data: []byte("ALTER TABLE t ADD COLUMN c INT;")},
+			"0002_add_column.down.sql":   {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+			"0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+			"README.md":                  {Data: []byte("не миграция")},
+		}
+		list, err := Load(fsys)
+		if err != nil {
+			t.Fatalf("Load вернул ошибку: %v", err)
+		}
+		if len(list) != 2 {
+			t.Fatalf("ожидалось 2 миграции, получено %d", len(list))
+		}
+		if list[0].Version != 1 || list[0].Name != "create_table" || list[1].Version != 2 {
+			t.Errorf("неверный порядок или имена миграций: %+v", list)
+		}
+		if list[1].Down != "ALTER TABLE t DROP COLUMN c;" {
+			t.Errorf("down-скрипт прочитан неверно: %q", list[1].Down)
+		}
+	})
+
+	errorCases := map[string]fstest.MapFS{
+		"Нет down-скрипта": {
+			"0001_a.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"Одна версия у двух миграций": {
+			"0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_a.down.sql": {Data: []byte("SELECT 1;")},
+			"0001_b.up.sql":   {Data: []byte("SELECT 1;")},
+		},
+		"Некорректная версия": {
+			"abc_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"abc_a.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"Нет направления": {
+			"0001_a.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+	for name, fsys := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(fsys); err == nil {
+				t.Error("ожидалась ошибка, получено nil")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := New(nil)
+	if err != nil {
+		t.Fatalf("встроенные миграции не загрузились: %v", err)
+	}
+	if len(m.Migrations) == 0 || m.Migrations[0].Version != 1 {
+		t.Errorf("ожидалась хотя бы миграция версии 1, получено %+v", m.Migrations)
+	}
+}
@@ -0,0 +1,8 @@
+-- Базовая таблица пользователей. IF NOT EXISTS оставлен намеренно: на уже
+-- развернутых базах таблица была создана старым CreateUsersTableIfNotExists.
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(100) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_email_domain_idx;
+DROP INDEX IF EXISTS users_created_at_id_idx;
+DROP INDEX IF EXISTS users_email_id_idx;
+DROP INDEX IF EXISTS users_name_id_idx;
+
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL;
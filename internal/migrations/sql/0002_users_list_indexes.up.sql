@@ -0,0 +1,9 @@
+-- Keyset-пагинация по created_at требует, чтобы колонка не содержала NULL
+UPDATE users SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL;
+
+-- Индексы под сортировки и фильтры GET /api/v1/users
+CREATE INDEX IF NOT EXISTS users_name_id_idx ON users (name, id);
+CREATE INDEX IF NOT EXISTS users_email_id_idx ON users (email, id);
+CREATE INDEX IF NOT EXISTS users_created_at_id_idx ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS users_email_domain_idx ON users (lower(split_part(email, '@', 2)));
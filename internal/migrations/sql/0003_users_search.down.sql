@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS users_email_trgm_idx;
+DROP INDEX IF EXISTS users_name_latin_trgm_idx;
+DROP INDEX IF EXISTS users_name_trgm_idx;
+DROP INDEX IF EXISTS users_search_vector_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS name_latin;
+
+DROP FUNCTION IF EXISTS translit_ru(text);
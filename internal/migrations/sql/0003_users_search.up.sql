@@ -0,0 +1,28 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Транслитерация кириллицы в латиницу для поиска латинским запросом по кириллическому имени.
+-- Должна совпадать с storage.Transliterate. Заглавные буквы переводятся явно,
+-- чтобы результат не зависел от LC_CTYPE базы.
+CREATE OR REPLACE FUNCTION translit_ru(input text) RETURNS text
+LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE AS $$
+    SELECT translate(
+        replace(replace(replace(replace(replace(replace(replace(replace(replace(replace(
+            lower(translate(input,
+                'АБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯ',
+                'абвгдеёжзийклмнопрстуфхцчшщъыьэюя')),
+            'щ', 'shch'), 'ж', 'zh'), 'х', 'kh'), 'ц', 'ts'), 'ч', 'ch'),
+            'ш', 'sh'), 'ю', 'yu'), 'я', 'ya'), 'ъ', ''), 'ь', ''),
+        'абвгдеёзийклмнопрстуфыэ',
+        'abvgdeeziyklmnoprstufye')
+$$;
+
+ALTER TABLE users
+    ADD COLUMN name_latin TEXT GENERATED ALWAYS AS (translit_ru(name)) STORED,
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('simple', name || ' ' || translit_ru(name) || ' ' || email)
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS users_search_vector_idx ON users USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS users_name_trgm_idx ON users USING GIN (lower(name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_name_latin_trgm_idx ON users USING GIN (name_latin gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_email_trgm_idx ON users USING GIN (lower(email) gin_trgm_ops);
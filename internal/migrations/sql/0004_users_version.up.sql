@@ -0,0 +1,2 @@
+-- Версия строки для оптимистичной блокировки (ETag / If-Match)
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,6 @@
+-- Пользователи из корзины удаляются окончательно: без них уникальность email восстановима
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS users_deleted_at_idx;
+DROP INDEX IF EXISTS users_email_active_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
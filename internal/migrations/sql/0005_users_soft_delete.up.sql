@@ -0,0 +1,10 @@
+-- Мягкое удаление: пользователь попадает в корзину и может быть восстановлен
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Email должен быть уникален только среди пользователей вне корзины,
+-- иначе удаленный пользователь навсегда занимает свой адрес
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX users_email_active_key ON users (email) WHERE deleted_at IS NULL;
+
+-- Для фоновой очистки корзины и ее просмотра
+CREATE INDEX users_deleted_at_idx ON users (deleted_at, id) WHERE deleted_at IS NOT NULL;
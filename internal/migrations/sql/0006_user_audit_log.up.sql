@@ -0,0 +1,31 @@
+-- Журнал аудита изменений пользователей. user_id без внешнего ключа:
+-- история должна пережить окончательное удаление пользователя.
+CREATE TABLE user_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    actor VARCHAR(200) NOT NULL,
+    request_id VARCHAR(100) NOT NULL DEFAULT '',
+    changes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX user_audit_log_user_idx ON user_audit_log (user_id, id);
+CREATE INDEX user_audit_log_actor_idx ON user_audit_log (actor, id);
+CREATE INDEX user_audit_log_created_at_idx ON user_audit_log (created_at);
+
+-- Журнал только дополняется: изменение и удаление записей запрещены
+CREATE FUNCTION user_audit_log_append_only() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'журнал аудита user_audit_log доступен только для добавления';
+END
+$$;
+
+CREATE TRIGGER user_audit_log_append_only
+    BEFORE UPDATE OR DELETE ON user_audit_log
+    FOR EACH ROW EXECUTE FUNCTION user_audit_log_append_only();
+
+CREATE TRIGGER user_audit_log_no_truncate
+    BEFORE TRUNCATE ON user_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION user_audit_log_append_only();
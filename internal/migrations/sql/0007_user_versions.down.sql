@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS users_record_version ON users;
+DROP FUNCTION IF EXISTS user_versions_record();
+DROP TABLE IF EXISTS user_versions;
@@ -0,0 +1,41 @@
+-- Снимки всех версий пользователей для запросов "на момент времени".
+-- Версия действует с valid_from до valid_from следующей версии.
+-- Без внешнего ключа: история переживает окончательное удаление пользователя.
+CREATE TABLE user_versions (
+    user_id BIGINT NOT NULL,
+    version BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, version)
+);
+
+CREATE INDEX user_versions_valid_from_idx ON user_versions (user_id, valid_from);
+
+-- Для существующих пользователей известна только текущая версия. Первая версия
+-- действует с момента создания, для остальных момент изменения неизвестен.
+INSERT INTO user_versions (user_id, version, name, email, created_at, deleted_at, valid_from)
+SELECT id, version, name, email, created_at, deleted_at,
+    CASE WHEN version = 1 THEN created_at ELSE CURRENT_TIMESTAMP END
+FROM users;
+
+-- Каждое изменение users сохраняет снимок новой версии
+CREATE FUNCTION user_versions_record() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    INSERT INTO user_versions (user_id, version, name, email, created_at, deleted_at)
+    VALUES (NEW.id, NEW.version, NEW.name, NEW.email, NEW.created_at, NEW.deleted_at)
+    ON CONFLICT (user_id, version) DO UPDATE SET
+        name = EXCLUDED.name,
+        email = EXCLUDED.email,
+        deleted_at = EXCLUDED.deleted_at,
+        valid_from = EXCLUDED.valid_from;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER users_record_version
+    AFTER INSERT OR UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION user_versions_record();
@@ -0,0 +1,27 @@
+-- Пароли пользователей (необязательные) и счетчик неудачных входов для блокировки.
+-- Хэш argon2id в формате PHC, пароль не хранится.
+CREATE TABLE user_passwords (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    hash VARCHAR(200) NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Сессии пользователей, открытые входом по паролю. Cookie содержит случайный токен,
+-- здесь хранится только его SHA-256. Завершенные сессии остаются до окончательного
+-- удаления пользователя.
+CREATE TABLE user_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(500) NOT NULL DEFAULT '',
+    CONSTRAINT user_sessions_token_hash_key UNIQUE (token_hash)
+);
+
+CREATE INDEX user_sessions_user_idx ON user_sessions (user_id, id) WHERE revoked_at IS NULL;
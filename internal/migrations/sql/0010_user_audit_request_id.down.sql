@@ -0,0 +1 @@
+ALTER TABLE user_audit_log ALTER COLUMN request_id TYPE VARCHAR(100) USING left(request_id, 100);
@@ -0,0 +1,2 @@
+-- ID запроса берется из middleware, который принимает от клиента ID до 128 символов
+ALTER TABLE user_audit_log ALTER COLUMN request_id TYPE VARCHAR(128);
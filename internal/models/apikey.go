@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// APIKey — ключ доступа к API. Сам ключ хранится только в виде хэша: клиент получает его
+// один раз при создании или ротации.
+type APIKey struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Prefix — открытая часть ключа, по которой он находится в хранилище и узнается в списке
+	Prefix string `json:"prefix"`
+	// Hash — SHA-256 полного ключа в hex
+	Hash string `json:"-"`
+	// Scopes — разрешенные области доступа, например users:read
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt — срок действия; nil — бессрочный ключ
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// RotatedAt — время последней замены ключа
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	// RevokedAt заполнен, если ключ отозван; отозванный ключ не принимается
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active сообщает, действует ли ключ в момент now: он не отозван и не истек
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Действия с пользователем, которые записываются в журнал аудита
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+	AuditActionRevert  = "revert"
+	// Пароль и блокировка входа; значения полей в журнал не попадают
+	AuditActionSetPassword    = "set_password"
+	AuditActionRemovePassword = "remove_password"
+	AuditActionLock           = "lock"
+	AuditActionUnlock         = "unlock"
+)
+
+// AuditEntry — запись журнала аудита об одном изменении пользователя
+type AuditEntry struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Action string `json:"action"`
+	// Actor — кто выполнил изменение
+	Actor     string `json:"actor"`
+	RequestID string `json:"request_id,omitempty"`
+	// Changes содержит только изменившиеся поля: значение до и после изменения
+	Changes   map[string]FieldChange `json:"changes"`
+	CreatedAt time.Time              `json:"timestamp"`
+}
+
+// FieldChange — значение поля до и после изменения; nil, если значения не было
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
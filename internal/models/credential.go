@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// PasswordCredential — пароль пользователя. Пароль необязателен: пользователь без него
+// не может войти, но остается обычной записью справочника.
+type PasswordCredential struct {
+	UserID int64 `json:"user_id"`
+	// Hash — хэш argon2id в формате PHC ($argon2id$v=19$m=...,t=...,p=...$соль$хэш)
+	Hash string `json:"-"`
+	// FailedAttempts — неудачные входы подряд с последнего успешного входа или блокировки
+	FailedAttempts int `json:"failed_attempts"`
+	// LockedUntil — до этого момента вход заблокирован после серии неудачных попыток
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Locked сообщает, заблокирован ли вход в момент now
+func (c PasswordCredential) Locked(now time.Time) bool {
+	return c.LockedUntil != nil && now.Before(*c.LockedUntil)
+}
+
+// Session — сессия пользователя, открытая входом по паролю. Сессии хранятся на сервере:
+// cookie содержит только случайный токен, в базе — его SHA-256.
+type Session struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// TokenHash — SHA-256 токена из cookie в hex
+	TokenHash string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt — окончание сессии независимо от активности
+	ExpiresAt time.Time `json:"expires_at"`
+	// LastSeenAt — время последнего запроса с сессией, обновляется не чаще раза в минуту
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// RevokedAt заполнен, если сессия завершена выходом или отозвана
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// IP и UserAgent — клиент, открывший сессию
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// Active сообщает, действует ли сессия в момент now: она не отозвана, не истекла
+// и с последнего запроса прошло меньше idleTimeout (0 — без ограничения простоя)
+func (s Session) Active(now time.Time, idleTimeout time.Duration) bool {
+	if s.RevokedAt != nil || !now.Before(s.ExpiresAt) {
+		return false
+	}
+	return idleTimeout <= 0 || now.Sub(s.LastSeenAt) < idleTimeout
+}
@@ -1,8 +1,50 @@
 // File: internal/models/user.go
 package models
 
+import (
+	"log/slog"
+	"time"
+)
+
+// User — пользователь. Теги validate читает пакет validation: ограничения длины
+// совпадают с VARCHAR(100) в таблице users, управляющие символы в имени запрещены.
 type User struct {
-	ID    int64  `json:"id"`
-	Name  string `json:"name" validate:"required"`
-	Email string `json:"email" validate:"required,email"`
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" validate:"required,max=100,pattern=^[^\\p{Cc}]*$"`
+	Email     string    `json:"email" validate:"required,email,max=100"`
+	CreatedAt time.Time `json:"created_at"`
+	// Version увеличивается при каждом изменении; из нее строится ETag
+	Version int64 `json:"version"`
+	// DeletedAt заполнен, если пользователь находится в корзине
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// LogValue описывает пользователя в журнале. Имя и email идут под ключами name и email,
+// которые пакет logging маскирует, если показ персональных данных не включен.
+func (u User) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int64("id", u.ID),
+		slog.String("name", u.Name),
+		slog.String("email", u.Email),
+		slog.Int64("version", u.Version),
+	}
+	if u.DeletedAt != nil {
+		attrs = append(attrs, slog.Time("deleted_at", *u.DeletedAt))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// UserSearchResult — пользователь, найденный поиском, с релевантностью и подсвеченными фрагментами
+type UserSearchResult struct {
+	User User    `json:"user"`
+	Rank float64 `json:"rank"`
+	// Highlights содержит поля с совпадениями, обернутыми в <mark>; текст полей экранирован как HTML
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// UserVersion — снимок пользователя в одной из его версий
+type UserVersion struct {
+	User
+	// ValidFrom — момент, с которого действует версия (до начала следующей версии)
+	ValidFrom time.Time `json:"valid_from"`
 }
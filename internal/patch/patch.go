@@ -0,0 +1,377 @@
+// Package patch применяет к JSON-документам JSON Merge Patch (RFC 7396) и JSON Patch (RFC 6902).
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MergePatchContentType — тип содержимого JSON Merge Patch
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType — тип содержимого JSON Patch
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch — документ патча синтаксически некорректен
+	ErrInvalidPatch = errors.New("некорректный документ патча")
+	// ErrTestFailed — операция test не совпала с текущим состоянием документа
+	ErrTestFailed = errors.New("операция test не выполнена")
+	// ErrCannotApply — патч корректен, но не применим к документу (например, нет пути)
+	ErrCannotApply = errors.New("патч не применим к документу")
+)
+
+// MergePatch применяет JSON Merge Patch (RFC 7396) к документу doc
+func MergePatch(doc, patchDoc []byte) ([]byte, error) {
+	var patchValue interface{}
+	if err := decode(patchDoc, &patchValue); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	var target interface{}
+	if err := decode(doc, &target); err != nil {
+		return nil, fmt.Errorf("patch.MergePatch: исходный документ: %w", err)
+	}
+	return json.Marshal(mergeValue(target, patchValue))
+}
+
+func mergeValue(target, patchValue interface{}) interface{} {
+	patchObject, ok := patchValue.(map[string]interface{})
+	if !ok {
+		return patchValue
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// Operation — одна операция JSON Patch
+type Operation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from,omitempty"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+// ParseJSONPatch разбирает и проверяет документ JSON Patch
+func ParseJSONPatch(patchDoc []byte) ([]Operation, error) {
+	var ops []Operation
+	dec := json.NewDecoder(bytes.NewReader(patchDoc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ops); err != nil {
+		return nil, fmt.Errorf("%w: ожидается массив операций: %v", ErrInvalidPatch, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%w: лишние данные после массива операций", ErrInvalidPatch)
+	}
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: операция %d (%s) требует поле value", ErrInvalidPatch, i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("%w: операция %d (%s): from: %v", ErrInvalidPatch, i, op.Op, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: операция %d: неизвестный op '%s'", ErrInvalidPatch, i, op.Op)
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w: операция %d (%s): path: %v", ErrInvalidPatch, i, op.Op, err)
+		}
+	}
+	return ops, nil
+}
+
+// ApplyJSONPatch применяет JSON Patch (RFC 6902) к документу doc.
+// Операции применяются по порядку; при ошибке любой из них документ не изменяется.
+func ApplyJSONPatch(doc, patchDoc []byte) ([]byte, error) {
+	ops, err := ParseJSONPatch(patchDoc)
+	if err != nil {
+		return nil, err
+	}
+	var root interface{}
+	if err := decode(doc, &root); err != nil {
+		return nil, fmt.Errorf("patch.ApplyJSONPatch: исходный документ: %w", err)
+	}
+
+	for i, op := range ops {
+		path, _ := parsePointer(op.Path)
+		var value interface{}
+		if op.Value != nil {
+			if err := decode(*op.Value, &value); err != nil {
+				return nil, fmt.Errorf("%w: операция %d: value: %v", ErrInvalidPatch, i, err)
+			}
+		}
+
+		switch op.Op {
+		case "add":
+			root, err = addValue(root, path, value)
+		case "remove":
+			root, _, err = removeValue(root, path)
+		case "replace":
+			root, _, err = removeValue(root, path)
+			if err == nil {
+				root, err = addValue(root, path, value)
+			}
+		case "move":
+			from, _ := parsePointer(op.From)
+			if isProperPrefix(from, path) {
+				err = fmt.Errorf("%w: нельзя переместить значение внутрь самого себя", ErrCannotApply)
+				break
+			}
+			var moved interface{}
+			root, moved, err = removeValue(root, from)
+			if err == nil {
+				root, err = addValue(root, path, moved)
+			}
+		case "copy":
+			from, _ := parsePointer(op.From)
+			var copied interface{}
+			copied, err = getValue(root, from)
+			if err == nil {
+				root, err = addValue(root, path, deepCopy(copied))
+			}
+		case "test":
+			var current interface{}
+			current, err = getValue(root, path)
+			if err == nil && !jsonEqual(current, value) {
+				err = fmt.Errorf("%w: значение по пути '%s' не совпадает", ErrTestFailed, op.Path)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("операция %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+// parsePointer разбирает JSON Pointer (RFC 6901) на токены
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("указатель '%s' должен начинаться с '/'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func isProperPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func getValue(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := node.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: ключ '%s' не найден", ErrCannotApply, token)
+			}
+			node = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			node = container[index]
+		default:
+			return nil, fmt.Errorf("%w: '%s' не является объектом или массивом", ErrCannotApply, token)
+		}
+	}
+	return node, nil
+}
+
+// addValue добавляет value по пути path и возвращает новый корень документа
+func addValue(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(node, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[last] = value
+		return node, nil
+	case []interface{}:
+		index := len(container)
+		if last != "-" {
+			if index, err = arrayIndex(last, len(container), true); err != nil {
+				return nil, err
+			}
+		}
+		updated := append(container[:index:index], append([]interface{}{value}, container[index:]...)...)
+		return replaceAt(node, path[:len(path)-1], updated)
+	default:
+		return nil, fmt.Errorf("%w: родитель пути не является объектом или массивом", ErrCannotApply)
+	}
+}
+
+// removeValue удаляет значение по пути path и возвращает новый корень и удаленное значение
+func removeValue(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, node, nil
+	}
+	parent, err := getValue(node, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		value, ok := container[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: ключ '%s' не найден", ErrCannotApply, last)
+		}
+		delete(container, last)
+		return node, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(container), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := container[index]
+		updated := append(container[:index:index], container[index+1:]...)
+		root, err := replaceAt(node, path[:len(path)-1], updated)
+		return root, value, err
+	default:
+		return nil, nil, fmt.Errorf("%w: родитель пути не является объектом или массивом", ErrCannotApply)
+	}
+}
+
+// replaceAt заменяет значение по существующему пути (нужно для массивов, которые меняют длину)
+func replaceAt(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(node, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[last] = value
+	case []interface{}:
+		index, err := arrayIndex(last, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = value
+	}
+	return node, nil
+}
+
+// arrayIndex разбирает индекс массива; allowEnd разрешает индекс, равный длине (для add)
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: некорректный индекс массива '%s'", ErrCannotApply, token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > length || (index == length && !allowEnd) {
+		return 0, fmt.Errorf("%w: индекс массива '%s' вне диапазона", ErrCannotApply, token)
+	}
+	return index, nil
+}
+
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("лишние данные после JSON-документа")
+	}
+	return nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = deepCopy(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = deepCopy(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// jsonEqual сравнивает JSON-значения по RFC 6902: числа сравниваются по значению
+func jsonEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, item := range av {
+			other, exists := bv[key]
+			if !exists || !jsonEqual(item, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okA := new(big.Float).SetString(av.String())
+		y, okB := new(big.Float).SetString(bv.String())
+		return okA && okB && x.Cmp(y) == 0
+	default:
+		return a == b
+	}
+}
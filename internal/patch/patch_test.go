@@ -0,0 +1,126 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// assertJSONEqual сравнивает документы без учета порядка ключей
+func assertJSONEqual(t *testing.T, got []byte, expected string) {
+	t.Helper()
+	var g, e interface{}
+	if err := decode(got, &g); err != nil {
+		t.Fatalf("результат не является JSON: %v", err)
+	}
+	if err := decode([]byte(expected), &e); err != nil {
+		t.Fatalf("ожидаемое значение не является JSON: %v", err)
+	}
+	if !jsonEqual(g, e) {
+		t.Errorf("получено %s, ожидалось %s", got, expected)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Примеры из приложения A RFC 7396
+	testCases := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range testCases {
+		got, err := MergePatch([]byte(tc.doc), []byte(tc.patch))
+		if err != nil {
+			t.Errorf("MergePatch(%s, %s): %v", tc.doc, tc.patch, err)
+			continue
+		}
+		assertJSONEqual(t, got, tc.expected)
+	}
+
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"a":`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("для некорректного патча ожидалась ErrInvalidPatch, получено %v", err)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	// Примеры из приложения A RFC 6902
+	testCases := []struct {
+		name, doc, patch, expected string
+	}{
+		{"add в объект", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add в массив", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"remove из объекта", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove из массива", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move в массиве", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`},
+		{"test с числом в другой записи", `{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{"добавление в конец массива", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			`{"foo":["bar",["abc","def"]]}`},
+		{"экранирование в указателе", `{"a/b":1,"m~n":2}`,
+			`[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, `{"a/b":3}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyJSONPatch([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatalf("ApplyJSONPatch: %v", err)
+			}
+			assertJSONEqual(t, got, tc.expected)
+		})
+	}
+
+	errorCases := []struct {
+		name, doc, patch string
+		expected         error
+	}{
+		{"test не совпал", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ErrTestFailed},
+		{"путь не существует", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrCannotApply},
+		{"удаление несуществующего ключа", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ErrCannotApply},
+		{"индекс вне диапазона", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/5","value":1}]`, ErrCannotApply},
+		{"перемещение внутрь себя", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, ErrCannotApply},
+		{"неизвестная операция", `{}`, `[{"op":"merge","path":"/a","value":1}]`, ErrInvalidPatch},
+		{"нет value", `{}`, `[{"op":"add","path":"/a"}]`, ErrInvalidPatch},
+		{"не массив", `{}`, `{"op":"add","path":"/a","value":1}`, ErrInvalidPatch},
+		{"указатель без слэша", `{}`, `[{"op":"remove","path":"a"}]`, ErrInvalidPatch},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ApplyJSONPatch([]byte(tc.doc), []byte(tc.patch))
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("ожидалась ошибка %v, получено %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatchIsAtomic(t *testing.T) {
+	doc := []byte(`{"name":"Old","email":"old@example.com"}`)
+	patchDoc := []byte(`[{"op":"replace","path":"/name","value":"New"},{"op":"test","path":"/email","value":"other@example.com"}]`)
+	if _, err := ApplyJSONPatch(doc, patchDoc); !errors.Is(err, ErrTestFailed) {
+		t.Fatalf("ожидалась ErrTestFailed, получено %v", err)
+	}
+	var original map[string]string
+	if err := json.Unmarshal(doc, &original); err != nil || original["name"] != "Old" {
+		t.Errorf("исходный документ изменился: %s", doc)
+	}
+}
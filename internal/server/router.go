@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/handlers"
+)
+
+// probeMethods — методы, которые проверяются при построении заголовка Allow.
+// HEAD обслуживается шаблонами GET автоматически, поэтому попадает в Allow вместе с GET.
+var probeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// Router — маршрутизатор API поверх http.ServeMux с шаблонами вида "GET /api/v1/users/{id}".
+// В отличие от ServeMux он отвечает на OPTIONS списком разрешенных методов, а ошибки 404 и 405
+// возвращает в формате problem+json; ответы 405 и OPTIONS содержат заголовок Allow.
+type Router struct {
+	mux *http.ServeMux
+}
+
+// NewRouter создает пустой маршрутизатор
+func NewRouter() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// Handle регистрирует обработчик для шаблона в синтаксисе http.ServeMux (Go 1.22+)
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	rt.mux.Handle(pattern, handler)
+}
+
+// HandleFunc регистрирует функцию-обработчик для шаблона
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.mux.Handle(pattern, handler)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern != "" {
+		rt.mux.ServeHTTP(w, r)
+		return
+	}
+
+	allowed := rt.allowedMethods(r)
+	if len(allowed) == 0 {
+		handlers.SendProblem(w, r, http.StatusNotFound, handlers.CodeNotFound, "detail.not_found")
+		return
+	}
+	allow := strings.Join(append(allowed, http.MethodOptions), ", ")
+	w.Header().Set("Allow", allow)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	handlers.SendProblem(w, r, http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed, "detail.method_not_allowed",
+		"method", r.Method, "allow", allow)
+}
+
+// allowedMethods возвращает методы, для которых путь запроса совпадает с каким-либо шаблоном
+func (rt *Router) allowedMethods(r *http.Request) []string {
+	var allowed []string
+	for _, method := range probeMethods {
+		probe := r.WithContext(r.Context())
+		probe.Method = method
+		if _, pattern := rt.mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Таймауты HTTP-сервера по умолчанию
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+	DefaultShutdownTimeout   = 20 * time.Second
+)
+
+// Timeouts — таймауты HTTP-сервера; нулевые значения заменяются значениями по умолчанию
+type Timeouts struct {
+	// ReadHeader ограничивает чтение заголовков запроса
+	ReadHeader time.Duration
+	// Read ограничивает чтение всего запроса, включая тело
+	Read time.Duration
+	// Write ограничивает время от конца чтения заголовков до конца записи ответа
+	Write time.Duration
+	// Idle ограничивает ожидание следующего запроса в keep-alive соединении
+	Idle time.Duration
+}
+
+// NewHTTPServer создает http.Server с обработчиком handler и таймаутами t
+func NewHTTPServer(addr string, handler http.Handler, t Timeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(t.ReadHeader, DefaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(t.Read, DefaultReadTimeout),
+		WriteTimeout:      orDefault(t.Write, DefaultWriteTimeout),
+		IdleTimeout:       orDefault(t.Idle, DefaultIdleTimeout),
+	}
+}
+
+func orDefault(d, defaultValue time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultValue
+	}
+	return d
+}
+
+// ShutdownOptions — порядок остановки сервера в Serve
+type ShutdownOptions struct {
+	// OnDrain вызывается первым при остановке, например health.Checker.StartDraining
+	OnDrain func()
+	// DrainDelay — пауза между OnDrain и закрытием слушателя, чтобы балансировщик успел
+	// увидеть неготовность сервиса и перестал направлять на него запросы
+	DrainDelay time.Duration
+	// Timeout ограничивает ожидание начатых запросов; 0 — DefaultShutdownTimeout
+	Timeout time.Duration
+}
+
+// Serve обслуживает соединения на ln, пока не будет отменен ctx, и затем корректно
+// останавливает сервер: вызывает OnDrain, ждет DrainDelay, перестает принимать новые
+// соединения и ждет завершения начатых запросов не дольше Timeout. Соединения, не успевшие
+// завершиться, закрываются принудительно, и Serve возвращает ошибку.
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, opts ShutdownOptions) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server.Serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Пока идет DrainDelay, сервер по-прежнему принимает запросы: балансировщик успевает
+	// увидеть неготовность и перестать направлять трафик
+	slog.Info("Остановка сервера: вывод из балансировки", "drain_delay", opts.DrainDelay)
+	if opts.OnDrain != nil {
+		opts.OnDrain()
+	}
+	if opts.DrainDelay > 0 {
+		time.Sleep(opts.DrainDelay)
+	}
+
+	slog.Info("Остановка сервера: новые запросы больше не принимаются", "timeout", orDefault(opts.Timeout, DefaultShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), orDefault(opts.Timeout, DefaultShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("server.Serve: не все запросы завершились до истечения таймаута: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server.Serve: %w", err)
+	}
+	slog.Info("Сервер остановлен, все запросы завершены")
+	return nil
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startServe запускает Serve на свободном порту и возвращает адрес и канал с результатом Serve
+func startServe(t *testing.T, ctx context.Context, handler http.Handler, opts ShutdownOptions) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, NewHTTPServer(ln.Addr().String(), handler, Timeouts{}), ln, opts)
+	}()
+	return "http://" + ln.Addr().String(), done
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var drained atomic.Bool
+	url, done := startServe(t, ctx, handler, ShutdownOptions{OnDrain: func() { drained.Store(true) }, Timeout: 5 * time.Second})
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		inFlight <- result{status: resp.StatusCode, body: string(body)}
+	}()
+	<-started
+
+	cancel()
+	// Остановка ждет начатый запрос
+	select {
+	case err := <-done:
+		t.Fatalf("Serve завершился до окончания начатого запроса: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if !drained.Load() {
+		t.Error("OnDrain не вызван при остановке")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("после начала остановки новые соединения не должны приниматься")
+	}
+
+	close(release)
+	if res := <-inFlight; res.err != nil || res.status != http.StatusOK || res.body != "done" {
+		t.Errorf("начатый запрос должен завершиться успешно, получено %d %q %v", res.status, res.body, res.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Serve: ожидалось nil, получено %v", err)
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServe(t, ctx, handler, ShutdownOptions{Timeout: 50 * time.Millisecond})
+	go http.Get(url)
+	<-started
+
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Serve: ожидалась ошибка, если запрос не завершился до таймаута")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve не завершился после таймаута остановки")
+	}
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	srv := NewHTTPServer(":0", http.NotFoundHandler(), Timeouts{Write: time.Minute})
+	if srv.WriteTimeout != time.Minute || srv.ReadTimeout != DefaultReadTimeout ||
+		srv.ReadHeaderTimeout != DefaultReadHeaderTimeout || srv.IdleTimeout != DefaultIdleTimeout {
+		t.Errorf("таймауты сервера: получено read_header=%s read=%s write=%s idle=%s",
+			srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
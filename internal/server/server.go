@@ -0,0 +1,141 @@
+// Package server собирает HTTP-маршруты приложения: API пользователей, журнал аудита, ключи API,
+// каталоги сообщений и статические файлы фронтенда.
+//
+// Маршруты API задаются шаблонами http.ServeMux с методом ("GET /api/v1/users/{id}"),
+// обработчики получают параметры пути через r.PathValue.
+package server
+
+import (
+	"net/http"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/handlers"
+	"github.com/casanera/GiperboreyaTechnologies/internal/health"
+	"github.com/casanera/GiperboreyaTechnologies/internal/metrics"
+	"github.com/casanera/GiperboreyaTechnologies/internal/middleware"
+)
+
+// DefaultMaxBodyBytes — лимит тела запроса, если в Config он не задан
+const DefaultMaxBodyBytes = 1 << 20
+
+// Config — зависимости HTTP-обработчика приложения
+type Config struct {
+	Users *handlers.UserHandler
+	// StaticDir — каталог с файлами фронтенда; пустая строка отключает их раздачу
+	StaticDir string
+	// MaxBodyBytes ограничивает размер тела запроса; 0 — DefaultMaxBodyBytes
+	MaxBodyBytes int64
+	// Metrics получает метрики HTTP и отдается по GET /metrics; nil отключает метрики
+	Metrics *metrics.Registry
+	// Health обслуживает /healthz, /readyz и /health; nil отключает эти маршруты
+	Health *health.Checker
+	// Auth проверяет ключи API и области доступа маршрутов /api/v1 и обслуживает сессии
+	// фронтенда; nil отключает аутентификацию
+	Auth *handlers.AuthHandler
+	// APIKeys обслуживает управление ключами /api/v1/keys; nil отключает эти маршруты
+	APIKeys *handlers.APIKeyHandler
+	// Sessions обслуживает вход пользователей по паролю, их сессии и пароли; nil или nil Auth
+	// отключает эти маршруты
+	Sessions *handlers.UserSessionHandler
+}
+
+// NewAPIRouter регистрирует маршруты /api/v1. Маршруты пользователей и журнала аудита
+// требуют области доступа из пакета auth, если задан cfg.Auth; каталоги сообщений,
+// вход во фронтенд и вход пользователей по паролю доступны без ключа.
+func NewAPIRouter(cfg Config) *Router {
+	rt := NewRouter()
+	users, a := cfg.Users, cfg.Auth
+	read := func(h http.HandlerFunc) http.HandlerFunc { return a.Require(auth.ScopeUsersRead, h) }
+	write := func(h http.HandlerFunc) http.HandlerFunc { return a.Require(auth.ScopeUsersWrite, h) }
+
+	// Список и создание доступны и без завершающего слэша, и с ним
+	for _, base := range []string{"/api/v1/users", "/api/v1/users/{$}"} {
+		rt.HandleFunc("GET "+base, read(users.ListUsersHandler))
+		rt.HandleFunc("POST "+base, write(users.CreateUserHandler))
+	}
+	rt.HandleFunc("GET /api/v1/users/search", read(users.SearchUsersHandler))
+	rt.HandleFunc("GET /api/v1/users/trash", read(users.ListTrashHandler))
+
+	rt.HandleFunc("GET /api/v1/users/{id}", read(users.GetUserHandler))
+	rt.HandleFunc("PUT /api/v1/users/{id}", write(users.UpdateUserHandler))
+	rt.HandleFunc("PATCH /api/v1/users/{id}", write(users.PatchUserHandler))
+	rt.HandleFunc("DELETE /api/v1/users/{id}", write(users.DeleteUserHandler))
+	rt.HandleFunc("POST /api/v1/users/{id}/restore", write(users.RestoreUserHandler))
+	rt.HandleFunc("GET /api/v1/users/{id}/history", a.Require(auth.ScopeAuditRead, users.UserHistoryHandler))
+	rt.HandleFunc("GET /api/v1/users/{id}/versions", read(users.UserVersionsHandler))
+	rt.HandleFunc("GET /api/v1/users/{id}/versions/{version}", read(users.UserVersionHandler))
+	rt.HandleFunc("POST /api/v1/users/{id}/versions/{version}/revert", write(users.RevertUserHandler))
+
+	rt.HandleFunc("GET /api/v1/audit", a.Require(auth.ScopeAuditRead, users.ListAuditHandler))
+
+	if keys := cfg.APIKeys; keys != nil {
+		admin := func(h http.HandlerFunc) http.HandlerFunc { return a.Require(auth.ScopeKeysAdmin, h) }
+		rt.HandleFunc("GET /api/v1/keys", admin(keys.ListAPIKeysHandler))
+		rt.HandleFunc("POST /api/v1/keys", admin(keys.CreateAPIKeyHandler))
+		rt.HandleFunc("GET /api/v1/keys/{id}", admin(keys.GetAPIKeyHandler))
+		rt.HandleFunc("DELETE /api/v1/keys/{id}", admin(keys.RevokeAPIKeyHandler))
+		rt.HandleFunc("POST /api/v1/keys/{id}/rotate", admin(keys.RotateAPIKeyHandler))
+	}
+
+	if a != nil {
+		rt.HandleFunc("POST /api/v1/auth/session", a.CreateSessionHandler)
+		rt.HandleFunc("GET /api/v1/auth/session", a.GetSessionHandler)
+		rt.HandleFunc("DELETE /api/v1/auth/session", a.DeleteSessionHandler)
+	}
+
+	if sessions := cfg.Sessions; sessions != nil && a != nil {
+		rt.HandleFunc("POST /api/v1/auth/login", sessions.LoginHandler)
+		rt.HandleFunc("POST /api/v1/auth/logout", sessions.LogoutHandler)
+		rt.HandleFunc("GET /api/v1/auth/sessions", a.RequireUser(sessions.ListOwnSessionsHandler))
+		rt.HandleFunc("DELETE /api/v1/auth/sessions/{session}", a.RequireUser(sessions.RevokeOwnSessionHandler))
+
+		rt.HandleFunc("GET /api/v1/users/{id}/sessions", read(sessions.ListUserSessionsHandler))
+		rt.HandleFunc("DELETE /api/v1/users/{id}/sessions", write(sessions.RevokeUserSessionsHandler))
+		rt.HandleFunc("DELETE /api/v1/users/{id}/sessions/{session}", write(sessions.RevokeUserSessionHandler))
+		rt.HandleFunc("GET /api/v1/users/{id}/password", read(sessions.GetPasswordHandler))
+		rt.HandleFunc("PUT /api/v1/users/{id}/password", write(sessions.SetPasswordHandler))
+		rt.HandleFunc("DELETE /api/v1/users/{id}/password", write(sessions.RemovePasswordHandler))
+		rt.HandleFunc("POST /api/v1/users/{id}/password/unlock", write(sessions.UnlockPasswordHandler))
+	}
+
+	rt.HandleFunc("GET /api/v1/i18n/{$}", handlers.MessagesHandler)
+	rt.HandleFunc("GET /api/v1/i18n/{lang}", handlers.MessagesHandler)
+	return rt
+}
+
+// New возвращает обработчик всех запросов приложения: /api/ обслуживает NewAPIRouter,
+// GET /metrics — метрики Prometheus, /healthz, /readyz и /health — проверки состояния, остальные пути — файлы из StaticDir. Все запросы проходят
+// через middleware: ID запроса, трассировка, журнал доступа, метрики, восстановление после паники
+// и лимит размера тела.
+func New(cfg Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/api/", NewAPIRouter(cfg))
+	if cfg.Metrics != nil {
+		mux.Handle("GET /metrics", cfg.Metrics.Handler())
+	}
+	if cfg.Health != nil {
+		mux.HandleFunc("GET /healthz", cfg.Health.LivenessHandler)
+		mux.HandleFunc("GET /readyz", cfg.Health.ReadinessHandler)
+		mux.HandleFunc("GET /health", cfg.Health.HealthHandler)
+	}
+	if cfg.StaticDir != "" {
+		// Для "/" FileServer отдает index.html из каталога
+		mux.Handle("/", http.FileServer(http.Dir(cfg.StaticDir)))
+	}
+
+	maxBodyBytes := cfg.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	chain := []middleware.Middleware{middleware.RequestID, middleware.Tracing, middleware.AccessLog}
+	if cfg.Metrics != nil {
+		chain = append(chain, middleware.Metrics(cfg.Metrics))
+	}
+	chain = append(chain, middleware.Recover(sendPanicProblem), middleware.MaxBodySize(maxBodyBytes))
+	return middleware.Chain(mux, chain...)
+}
+
+// sendPanicProblem отвечает 500 на запрос, обработчик которого завершился паникой
+func sendPanicProblem(w http.ResponseWriter, r *http.Request) {
+	handlers.SendProblem(w, r, http.StatusInternalServerError, handlers.CodeInternal, "internal.panic")
+}
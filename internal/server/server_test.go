@@ -0,0 +1,339 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/handlers"
+	"github.com/casanera/GiperboreyaTechnologies/internal/health"
+	"github.com/casanera/GiperboreyaTechnologies/internal/metrics"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+)
+
+// setupServer создает обработчик приложения поверх MockUserStorage с одним пользователем
+func setupServer(t *testing.T) (http.Handler, models.User) {
+	t.Helper()
+	mockStorage := storage.NewMockUserStorage()
+	users := handlers.NewUserHandler(mockStorage)
+	users.Audit = mockStorage.Audit
+	seeded := mockStorage.SeedUser(models.User{Name: "Alice", Email: "alice@example.com"})
+
+	staticDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(staticDir, "index.html"), []byte("<h1>Пользователи</h1>"), 0o644); err != nil {
+		t.Fatalf("Не удалось создать index.html: %v", err)
+	}
+	return New(Config{Users: users, StaticDir: staticDir}), seeded
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// problemCode возвращает поле code из тела problem+json
+func problemCode(t *testing.T, rr *httptest.ResponseRecorder) handlers.ErrorCode {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type ошибки: ожидалось application/problem+json, получено '%s'. Тело: %s", ct, rr.Body.String())
+	}
+	var resp struct {
+		Code handlers.ErrorCode `json:"code"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Не удалось декодировать ответ JSON: %v", err)
+	}
+	return resp.Code
+}
+
+func TestRoutes(t *testing.T) {
+	h, seeded := setupServer(t)
+	userPath := "/api/v1/users/" + strconv.FormatInt(seeded.ID, 10)
+
+	testCases := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+		expectedCode   handlers.ErrorCode
+	}{
+		{"Список без слэша", http.MethodGet, "/api/v1/users", "", http.StatusOK, ""},
+		{"Список со слэшем", http.MethodGet, "/api/v1/users/", "", http.StatusOK, ""},
+		{"Создание", http.MethodPost, "/api/v1/users", `{"name": "Bob", "email": "bob@example.com"}`, http.StatusCreated, ""},
+		{"Поиск", http.MethodGet, "/api/v1/users/search?q=alice", "", http.StatusOK, ""},
+		{"Корзина", http.MethodGet, "/api/v1/users/trash", "", http.StatusOK, ""},
+		{"Пользователь по ID", http.MethodGet, userPath, "", http.StatusOK, ""},
+		{"История", http.MethodGet, userPath + "/history", "", http.StatusOK, ""},
+		{"Версии", http.MethodGet, userPath + "/versions", "", http.StatusOK, ""},
+		{"Одна версия", http.MethodGet, userPath + "/versions/1", "", http.StatusOK, ""},
+		{"Журнал аудита", http.MethodGet, "/api/v1/audit", "", http.StatusOK, ""},
+		{"Каталог сообщений", http.MethodGet, "/api/v1/i18n/en", "", http.StatusOK, ""},
+		{"Каталог по Accept-Language", http.MethodGet, "/api/v1/i18n/", "", http.StatusOK, ""},
+		{"ID не число", http.MethodGet, "/api/v1/users/abc", "", http.StatusBadRequest, handlers.CodeInvalidUserID},
+		{"Некорректная версия", http.MethodGet, userPath + "/versions/x", "", http.StatusBadRequest, handlers.CodeInvalidVersion},
+		{"Лишний сегмент пути", http.MethodGet, userPath + "/extra", "", http.StatusNotFound, handlers.CodeNotFound},
+		{"Вложенный путь каталога", http.MethodGet, "/api/v1/i18n/en/extra", "", http.StatusNotFound, handlers.CodeNotFound},
+		{"Неизвестный путь API", http.MethodGet, "/api/v2/users", "", http.StatusNotFound, handlers.CodeNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serve(h, tc.method, tc.target, tc.body)
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("%s %s: неверный статус-код: получено %d, ожидалось %d. Тело: %s", tc.method, tc.target, rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+			if tc.expectedCode != "" {
+				if code := problemCode(t, rr); code != tc.expectedCode {
+					t.Errorf("Код ошибки: ожидалось '%s', получено '%s'", tc.expectedCode, code)
+				}
+			}
+		})
+	}
+}
+
+func TestMethodHandling(t *testing.T) {
+	h, seeded := setupServer(t)
+	userPath := "/api/v1/users/" + strconv.FormatInt(seeded.ID, 10)
+
+	t.Run("405 с заголовком Allow", func(t *testing.T) {
+		testCases := []struct {
+			method        string
+			target        string
+			expectedAllow string
+		}{
+			{http.MethodDelete, "/api/v1/users", "GET, HEAD, POST, OPTIONS"},
+			{http.MethodPost, userPath, "GET, HEAD, PUT, PATCH, DELETE, OPTIONS"},
+			{http.MethodGet, userPath + "/restore", "POST, OPTIONS"},
+			{http.MethodPut, "/api/v1/audit", "GET, HEAD, OPTIONS"},
+		}
+		for _, tc := range testCases {
+			rr := serve(h, tc.method, tc.target, "")
+			if rr.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("%s %s: неверный статус-код: получено %d, ожидалось %d", tc.method, tc.target, rr.Code, http.StatusMethodNotAllowed)
+			}
+			if allow := rr.Header().Get("Allow"); allow != tc.expectedAllow {
+				t.Errorf("%s %s: Allow: ожидалось '%s', получено '%s'", tc.method, tc.target, tc.expectedAllow, allow)
+			}
+			if code := problemCode(t, rr); code != handlers.CodeMethodNotAllowed {
+				t.Errorf("%s %s: код ошибки: ожидалось '%s', получено '%s'", tc.method, tc.target, handlers.CodeMethodNotAllowed, code)
+			}
+		}
+	})
+
+	t.Run("OPTIONS возвращает разрешенные методы", func(t *testing.T) {
+		rr := serve(h, http.MethodOptions, userPath+"/versions/1/revert", "")
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("неверный статус-код: получено %d, ожидалось %d", rr.Code, http.StatusNoContent)
+		}
+		if allow := rr.Header().Get("Allow"); allow != "POST, OPTIONS" {
+			t.Errorf("Allow: ожидалось 'POST, OPTIONS', получено '%s'", allow)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("ответ на OPTIONS не должен содержать тело, получено %q", rr.Body.String())
+		}
+	})
+
+	t.Run("OPTIONS для неизвестного пути", func(t *testing.T) {
+		rr := serve(h, http.MethodOptions, "/api/v1/unknown", "")
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("неверный статус-код: получено %d, ожидалось %d", rr.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("HEAD обслуживается маршрутом GET", func(t *testing.T) {
+		rr := serve(h, http.MethodHead, userPath, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("неверный статус-код: получено %d, ожидалось %d", rr.Code, http.StatusOK)
+		}
+		if rr.Header().Get("ETag") == "" {
+			t.Error("HEAD: ожидался заголовок ETag")
+		}
+	})
+}
+
+func TestStaticFiles(t *testing.T) {
+	h, _ := setupServer(t)
+	rr := serve(h, http.MethodGet, "/", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("неверный статус-код: получено %d, ожидалось %d", rr.Code, http.StatusOK)
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte("Пользователи")) {
+		t.Errorf("ожидалось содержимое index.html, получено %q", rr.Body.String())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	h, _ := setupServer(t)
+
+	t.Run("ID запроса в ответе и в теле ошибки", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/abc", nil)
+		req.Header.Set("X-Request-ID", "req-from-client")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if got := rr.Header().Get("X-Request-ID"); got != "req-from-client" {
+			t.Errorf("X-Request-ID ответа: ожидалось 'req-from-client', получено '%s'", got)
+		}
+		if !bytes.Contains(rr.Body.Bytes(), []byte(`"request_id":"req-from-client"`)) {
+			t.Errorf("request_id не совпадает с заголовком: %s", rr.Body.String())
+		}
+	})
+
+	t.Run("ID трассировки из traceparent в теле ошибки", func(t *testing.T) {
+		previous := otel.GetTextMapPropagator()
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer otel.SetTextMapPropagator(previous)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/999", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if !bytes.Contains(rr.Body.Bytes(), []byte(`"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)) {
+			t.Errorf("в теле ошибки нет trace_id: %s", rr.Body.String())
+		}
+	})
+
+	t.Run("Слишком большое тело", func(t *testing.T) {
+		body := `{"name": "` + strings.Repeat("a", DefaultMaxBodyBytes) + `", "email": "big@example.com"}`
+		rr := serve(h, http.MethodPost, "/api/v1/users", body)
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("неверный статус-код: получено %d, ожидалось %d", rr.Code, http.StatusRequestEntityTooLarge)
+		}
+		if code := problemCode(t, rr); code != handlers.CodeBodyTooLarge {
+			t.Errorf("Код ошибки: ожидалось '%s', получено '%s'", handlers.CodeBodyTooLarge, code)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	mockStorage := storage.NewMockUserStorage()
+	reg := metrics.NewRegistry()
+	seeded := mockStorage.SeedUser(models.User{Name: "Alice", Email: "alice@example.com"})
+	h := New(Config{Users: handlers.NewUserHandler(storage.NewInstrumentedUserStorage(mockStorage, reg)), Metrics: reg})
+
+	serve(h, http.MethodGet, "/api/v1/users/"+strconv.FormatInt(seeded.ID, 10), "")
+	serve(h, http.MethodGet, "/api/v1/users/999", "")
+	serve(h, http.MethodDelete, "/api/v1/users", "")
+	serve(h, http.MethodGet, "/api/v1/unknown", "")
+
+	rr := serve(h, http.MethodGet, "/metrics", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != metrics.ContentType {
+		t.Fatalf("GET /metrics: получено %d, Content-Type '%s'", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		// Маршрут — шаблон, а не путь с ID
+		`http_requests_total{method="GET",route="/api/v1/users/{id}",status="200"} 1`,
+		`http_requests_total{method="GET",route="/api/v1/users/{id}",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/api/v1/users/{id}"} 2`,
+		// 404 и 405 маршрутизатора API учитываются под общим префиксом
+		`http_requests_total{method="DELETE",route="/api/",status="405"} 1`,
+		`http_requests_total{method="GET",route="/api/",status="404"} 1`,
+		`storage_operation_errors_total{operation="GetUserByID",error="not_found"} 1`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("в метриках нет '%s':\n%s", want, rr.Body.String())
+		}
+	}
+
+	if rr := serve(h, http.MethodPost, "/metrics", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /metrics: ожидался статус 405, получено %d", rr.Code)
+	}
+	if rr := serve(New(Config{Users: handlers.NewUserHandler(mockStorage)}), http.MethodGet, "/metrics", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("GET /metrics без Config.Metrics: ожидался статус 404, получено %d", rr.Code)
+	}
+}
+
+func TestHealthRoutes(t *testing.T) {
+	checker := health.NewChecker()
+	checker.Add("database", func(ctx context.Context) error { return nil })
+	h := New(Config{Users: handlers.NewUserHandler(storage.NewMockUserStorage()), Health: checker})
+
+	for _, path := range []string{"/healthz", "/readyz", "/health"} {
+		if rr := serve(h, http.MethodGet, path, ""); rr.Code != http.StatusOK {
+			t.Errorf("GET %s: ожидался статус 200, получено %d", path, rr.Code)
+		}
+	}
+	if rr := serve(h, http.MethodGet, "/health", ""); !strings.Contains(rr.Body.String(), `"name":"database"`) {
+		t.Errorf("/health: в отчете нет проверки database: %s", rr.Body.String())
+	}
+
+	checker.StartDraining()
+	if rr := serve(h, http.MethodGet, "/readyz", ""); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz при завершении работы: ожидался статус 503, получено %d", rr.Code)
+	}
+}
+
+func TestAuthRoutes(t *testing.T) {
+	keys := storage.NewMockAPIKeyStorage()
+	sessions, _ := auth.NewSessionCodec(nil, 0)
+	issue := func(scopes ...string) string {
+		secret, prefix, hash, _ := auth.GenerateAPIKey()
+		keys.CreateAPIKey(context.Background(), &models.APIKey{Name: "test", Prefix: prefix, Hash: hash, Scopes: scopes})
+		return secret
+	}
+	reader := issue(auth.ScopeUsersRead)
+	admin := issue(auth.ScopeKeysAdmin)
+	users := storage.NewMockUserStorage()
+	h := New(Config{
+		Users:    handlers.NewUserHandler(users),
+		Auth:     handlers.NewAuthHandler(auth.NewAuthenticator(keys, sessions)),
+		APIKeys:  handlers.NewAPIKeyHandler(keys),
+		Sessions: handlers.NewUserSessionHandler(auth.NewPasswordAuth(storage.NewMockCredentialStorage(users))),
+	})
+
+	testCases := []struct {
+		name           string
+		method, target string
+		key            string
+		expectedStatus int
+	}{
+		{"Список без ключа", http.MethodGet, "/api/v1/users", "", http.StatusUnauthorized},
+		{"Список с ключом чтения", http.MethodGet, "/api/v1/users", reader, http.StatusOK},
+		{"Удаление с ключом чтения", http.MethodDelete, "/api/v1/users/1", reader, http.StatusForbidden},
+		{"Журнал аудита с ключом чтения", http.MethodGet, "/api/v1/audit", reader, http.StatusForbidden},
+		{"Ключи с ключом чтения", http.MethodGet, "/api/v1/keys", reader, http.StatusForbidden},
+		{"Ключи с ключом администратора", http.MethodGet, "/api/v1/keys", admin, http.StatusOK},
+		{"Каталог сообщений без ключа", http.MethodGet, "/api/v1/i18n/ru", "", http.StatusOK},
+		{"Сессия без ключа", http.MethodGet, "/api/v1/auth/session", "", http.StatusUnauthorized},
+		{"Вход по паролю без ключа", http.MethodPost, "/api/v1/auth/login", "", http.StatusBadRequest},
+		{"Сессии пользователя с ключом", http.MethodGet, "/api/v1/auth/sessions", reader, http.StatusForbidden},
+		{"Сессии пользователя с ключом чтения", http.MethodGet, "/api/v1/users/1/sessions", reader, http.StatusOK},
+		{"Пароль с ключом чтения", http.MethodPut, "/api/v1/users/1/password", reader, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			if tc.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.key)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("%s %s: ожидался статус %d, получено %d. Тело: %s", tc.method, tc.target, tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("Без аутентификации маршруты открыты, а сессий нет", func(t *testing.T) {
+		h, _ := setupServer(t)
+		if rr := serve(h, http.MethodGet, "/api/v1/users", ""); rr.Code != http.StatusOK {
+			t.Errorf("GET /api/v1/users: ожидался статус 200, получено %d", rr.Code)
+		}
+		if rr := serve(h, http.MethodGet, "/api/v1/auth/session", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("GET /api/v1/auth/session: ожидался статус 404, получено %d", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTLSReloadInterval — как часто CertReloader.Watch проверяет файлы сертификатов
+const DefaultTLSReloadInterval = 10 * time.Second
+
+// Режимы проверки сертификатов клиентов (ParseClientAuth)
+const (
+	// ClientAuthNone — сертификат клиента не запрашивается
+	ClientAuthNone = "none"
+	// ClientAuthOptional — сертификат проверяется, если клиент его передал
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire — без действительного сертификата соединение не устанавливается
+	ClientAuthRequire = "require"
+)
+
+// ParseTLSVersion разбирает минимальную версию TLS: "1.2" или "1.3"
+func ParseTLSVersion(s string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(s), "tls") {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("server.ParseTLSVersion: неизвестная версия '%s', ожидается 1.2 или 1.3", s)
+}
+
+// ParseClientAuth разбирает режим проверки сертификатов клиентов: none, optional или require
+func ParseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(s) {
+	case "", ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return 0, fmt.Errorf("server.ParseClientAuth: неизвестный режим '%s', ожидается %s, %s или %s",
+		s, ClientAuthNone, ClientAuthOptional, ClientAuthRequire)
+}
+
+// TLSOptions — настройки TLS для слушателя HTTP-сервера
+type TLSOptions struct {
+	// CertFile и KeyFile — сертификат сервера (с цепочкой) и его ключ в PEM
+	CertFile string
+	KeyFile  string
+	// MinVersion — минимальная версия TLS: "1.2" (по умолчанию) или "1.3"
+	MinVersion string
+	// ClientCAFile — сертификаты CA в PEM, которыми проверяются сертификаты клиентов (mTLS)
+	ClientCAFile string
+	// ClientAuth — режим проверки сертификатов клиентов (ClientAuthNone и т.д.)
+	ClientAuth string
+}
+
+// NewTLSConfig создает tls.Config для слушателя. Сертификат сервера и CA клиентов берутся из
+// возвращаемого CertReloader при каждом подключении, поэтому после Reload или Watch новые
+// соединения используют новые файлы без перезапуска сервера.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, *CertReloader, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if opts.MinVersion != "" {
+		var err error
+		if minVersion, err = ParseTLSVersion(opts.MinVersion); err != nil {
+			return nil, nil, fmt.Errorf("server.NewTLSConfig: %w", err)
+		}
+	}
+	clientAuth, err := ParseClientAuth(opts.ClientAuth)
+	if err != nil {
+		return nil, nil, fmt.Errorf("server.NewTLSConfig: %w", err)
+	}
+	if clientAuth != tls.NoClientCert && opts.ClientCAFile == "" {
+		return nil, nil, fmt.Errorf("server.NewTLSConfig: для проверки сертификатов клиентов нужен файл CA")
+	}
+
+	reloader, err := NewCertReloader(opts.CertFile, opts.KeyFile, opts.ClientCAFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("server.NewTLSConfig: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:     minVersion,
+		ClientAuth:     clientAuth,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if opts.ClientCAFile != "" {
+		// ClientCAs нельзя подменить в общем tls.Config, поэтому на каждое подключение
+		// выдается копия с текущим набором CA
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			perConn := config.Clone()
+			perConn.ClientCAs = reloader.ClientCAs()
+			return perConn, nil
+		}
+	}
+	return config, reloader, nil
+}
+
+// CertReloader хранит сертификат сервера и CA клиентов, прочитанные из файлов, и
+// перечитывает их при изменении файлов. Если новые файлы некорректны (например, сертификат
+// уже заменен, а ключ еще нет), продолжают использоваться прежние.
+type CertReloader struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	versions  map[string]fileVersion
+}
+
+// fileVersion отличает измененный файл от прежнего
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader читает сертификат, ключ и, если clientCAFile не пуст, CA клиентов
+func NewCertReloader(certFile, keyFile, clientCAFile string) (*CertReloader, error) {
+	r := &CertReloader{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files возвращает отслеживаемые файлы
+func (r *CertReloader) files() []string {
+	files := []string{r.CertFile, r.KeyFile}
+	if r.ClientCAFile != "" {
+		files = append(files, r.ClientCAFile)
+	}
+	return files
+}
+
+// Reload перечитывает файлы. При ошибке прежние сертификаты остаются в силе.
+func (r *CertReloader) Reload() error {
+	versions := make(map[string]fileVersion, 3)
+	for _, name := range r.files() {
+		info, err := os.Stat(name)
+		if err != nil {
+			return fmt.Errorf("server.CertReloader: %w", err)
+		}
+		versions[name] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return fmt.Errorf("server.CertReloader: сертификат сервера: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if r.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("server.CertReloader: CA клиентов: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("server.CertReloader: в %s нет сертификатов PEM", r.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.versions = &cert, clientCAs, versions
+	r.mu.Unlock()
+	return nil
+}
+
+// changed сообщает, изменился ли какой-либо файл после последней успешной загрузки
+func (r *CertReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, name := range r.files() {
+		info, err := os.Stat(name)
+		if err != nil {
+			// Файл мог быть временно удален при замене; дождемся следующей проверки
+			continue
+		}
+		if (fileVersion{modTime: info.ModTime(), size: info.Size()}) != r.versions[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch проверяет файлы каждые interval и перечитывает их при изменении, пока не отменен ctx
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultTLSReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !r.changed() {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			slog.Error("Не удалось перечитать сертификаты TLS, используются прежние", "error", err)
+			continue
+		}
+		slog.Info("Сертификаты TLS перечитаны", "cert_file", r.CertFile, "not_after", r.NotAfter())
+	}
+}
+
+// GetCertificate возвращает текущий сертификат сервера; подходит для tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// ClientCAs возвращает текущий набор CA для проверки сертификатов клиентов
+func (r *CertReloader) ClientCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clientCAs
+}
+
+// NotAfter возвращает срок действия текущего сертификата сервера
+func (r *CertReloader) NotAfter() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert.Leaf == nil {
+		return time.Time{}
+	}
+	return r.cert.Leaf.NotAfter
+}
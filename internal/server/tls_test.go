@@ -0,0 +1,280 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA — одноразовый CA для тестов
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue выпускает сертификат с именем cn для сервера (127.0.0.1) или клиента и возвращает
+// сертификат и ключ в PEM
+func (ca *testCA) issue(t *testing.T, cn string, serial int64, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// clientCert выпускает сертификат клиента в виде tls.Certificate
+func (ca *testCA) clientCert(t *testing.T, cn string) tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, cn, 100, x509.ExtKeyUsageClientAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("tls.X509KeyPair: %v", err)
+	}
+	return cert
+}
+
+// writeTLSFiles записывает сертификат сервера с серийным номером serial и CA клиентов в dir
+func writeTLSFiles(t *testing.T, dir string, ca *testCA, serial int64) TLSOptions {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, "server", serial, x509.ExtKeyUsageServerAuth)
+	opts := TLSOptions{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "client-ca.crt"),
+	}
+	for name, data := range map[string][]byte{opts.CertFile: certPEM, opts.KeyFile: keyPEM, opts.ClientCAFile: ca.pem} {
+		if err := os.WriteFile(name, data, 0o600); err != nil {
+			t.Fatalf("os.WriteFile: %v", err)
+		}
+	}
+	return opts
+}
+
+// startTLS запускает сервер с tls.Config и возвращает его адрес
+func startTLS(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	srv := NewHTTPServer(ln.Addr().String(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "anonymous"
+		if len(r.TLS.PeerCertificates) > 0 {
+			name = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		io.WriteString(w, name)
+	}), Timeouts{})
+	go srv.Serve(tls.NewListener(ln, config))
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+// tlsGet выполняет GET с клиентскими настройками TLS и возвращает тело и серийный номер
+// сертификата сервера
+func tlsGet(url string, config *tls.Config) (string, int64, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}, Timeout: 5 * time.Second}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), resp.TLS.PeerCertificates[0].SerialNumber.Int64(), err
+}
+
+func TestTLSClientAuth(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	opts := writeTLSFiles(t, t.TempDir(), ca, 2)
+	clientCert := ca.clientCert(t, "billing-service")
+	otherCert := newTestCA(t).clientCert(t, "intruder")
+
+	tests := []struct {
+		name       string
+		clientAuth string
+		certs      []tls.Certificate
+		wantBody   string
+		wantErr    bool
+	}{
+		{"Без mTLS", ClientAuthNone, nil, "anonymous", false},
+		{"Необязательный сертификат не передан", ClientAuthOptional, nil, "anonymous", false},
+		{"Необязательный сертификат передан", ClientAuthOptional, []tls.Certificate{clientCert}, "billing-service", false},
+		{"Обязательный сертификат передан", ClientAuthRequire, []tls.Certificate{clientCert}, "billing-service", false},
+		{"Обязательный сертификат не передан", ClientAuthRequire, nil, "", true},
+		{"Сертификат чужого CA", ClientAuthRequire, []tls.Certificate{otherCert}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := opts
+			opts.ClientAuth = tt.clientAuth
+			config, _, err := NewTLSConfig(opts)
+			if err != nil {
+				t.Fatalf("NewTLSConfig: %v", err)
+			}
+			body, _, err := tlsGet(startTLS(t, config), &tls.Config{RootCAs: roots, Certificates: tt.certs})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ожидалась ошибка, получено тело %q", body)
+				}
+				return
+			}
+			if err != nil || body != tt.wantBody {
+				t.Errorf("ожидалось %q, получено %q %v", tt.wantBody, body, err)
+			}
+		})
+	}
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	opts := writeTLSFiles(t, t.TempDir(), ca, 2)
+	opts.MinVersion = "1.3"
+	config, _, err := NewTLSConfig(opts)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	url := startTLS(t, config)
+
+	if _, _, err := tlsGet(url, &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS12}); err == nil {
+		t.Error("клиент с TLS 1.2 не должен подключиться при минимальной версии 1.3")
+	}
+	if _, _, err := tlsGet(url, &tls.Config{RootCAs: roots}); err != nil {
+		t.Errorf("клиент с TLS 1.3: %v", err)
+	}
+}
+
+func TestTLSOptionErrors(t *testing.T) {
+	ca := newTestCA(t)
+	opts := writeTLSFiles(t, t.TempDir(), ca, 2)
+
+	cases := map[string]TLSOptions{
+		"неизвестная версия":      {CertFile: opts.CertFile, KeyFile: opts.KeyFile, MinVersion: "1.0"},
+		"неизвестный режим":       {CertFile: opts.CertFile, KeyFile: opts.KeyFile, ClientAuth: "always"},
+		"mTLS без CA":             {CertFile: opts.CertFile, KeyFile: opts.KeyFile, ClientAuth: ClientAuthRequire},
+		"нет файла ключа":         {CertFile: opts.CertFile, KeyFile: opts.KeyFile + ".missing"},
+		"в файле ключа нет ключа": {CertFile: opts.CertFile, KeyFile: opts.ClientCAFile},
+	}
+	for name, o := range cases {
+		if _, _, err := NewTLSConfig(o); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
+
+func TestCertReloaderWatch(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	dir := t.TempDir()
+	opts := writeTLSFiles(t, dir, ca, 2)
+	config, reloader, err := NewTLSConfig(opts)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	url := startTLS(t, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	if _, serial, err := tlsGet(url, &tls.Config{RootCAs: roots}); err != nil || serial != 2 {
+		t.Fatalf("исходный сертификат: ожидался серийный номер 2, получено %d %v", serial, err)
+	}
+
+	// Поврежденный сертификат не заменяет действующий
+	if err := os.WriteFile(opts.CertFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, serial, err := tlsGet(url, &tls.Config{RootCAs: roots}); err != nil || serial != 2 {
+		t.Fatalf("после поврежденного файла: ожидался прежний сертификат, получено %d %v", serial, err)
+	}
+
+	writeTLSFiles(t, dir, ca, 3)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, serial, err := tlsGet(url, &tls.Config{RootCAs: roots})
+		if err == nil && serial == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("новый сертификат не подхвачен: серийный номер %d, ошибка %v", serial, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := reloader.NotAfter(); time.Until(got) <= 0 {
+		t.Errorf("NotAfter: ожидался срок в будущем, получено %v", got)
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	for input, want := range map[string]uint16{"1.2": tls.VersionTLS12, "TLS1.3": tls.VersionTLS13} {
+		if got, err := ParseTLSVersion(input); err != nil || got != want {
+			t.Errorf("ParseTLSVersion(%q): ожидалось %d, получено %d %v", input, want, got, err)
+		}
+	}
+	if _, err := ParseTLSVersion("1.1"); err == nil || !strings.Contains(err.Error(), "1.2 или 1.3") {
+		t.Errorf("ParseTLSVersion(1.1): ожидалась ошибка, получено %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// APIKeyStorage хранит ключи API. Ключи не удаляются: отозванный ключ остается в списке,
+// чтобы было видно, кто и когда пользовался доступом.
+type APIKeyStorage interface {
+	// CreateAPIKey сохраняет ключ и заполняет его ID и CreatedAt
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error)
+	// GetAPIKeyByPrefix находит ключ по открытой части; проверка хэша — забота вызывающего
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	// ListAPIKeys возвращает все ключи, включая отозванные, от новых к старым
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	// RotateAPIKey заменяет открытую часть и хэш действующего ключа; прежний ключ сразу
+	// перестает приниматься. ErrNotFound, если ключа нет или он отозван.
+	RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*models.APIKey, error)
+	// RevokeAPIKey отзывает ключ. ErrNotFound, если ключа нет или он уже отозван.
+	RevokeAPIKey(ctx context.Context, id int64) (*models.APIKey, error)
+	// TouchAPIKey запоминает время последнего использования ключа
+	TouchAPIKey(ctx context.Context, id int64, at time.Time) error
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// PostgresAPIKeyStorage реализует APIKeyStorage для PostgreSQL (таблица api_keys)
+type PostgresAPIKeyStorage struct {
+	DB *sql.DB
+}
+
+// NewPostgresAPIKeyStorage создает новый экземпляр PostgresAPIKeyStorage
+func NewPostgresAPIKeyStorage(db *sql.DB) *PostgresAPIKeyStorage {
+	return &PostgresAPIKeyStorage{DB: db}
+}
+
+// apiKeyColumns — колонки api_keys в порядке, ожидаемом scanAPIKey
+const apiKeyColumns = "id, name, prefix, hash, scopes, created_at, expires_at, last_used_at, rotated_at, revoked_at"
+
+// scanAPIKey читает строку, выбранную по apiKeyColumns
+func scanAPIKey(row rowScanner, k *models.APIKey) error {
+	var expiresAt, lastUsedAt, rotatedAt, revokedAt sql.NullTime
+	var scopes pq.StringArray
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Hash, &scopes, &k.CreatedAt,
+		&expiresAt, &lastUsedAt, &rotatedAt, &revokedAt); err != nil {
+		return err
+	}
+	k.Scopes = []string(scopes)
+	k.ExpiresAt = nullTimePtr(expiresAt)
+	k.LastUsedAt = nullTimePtr(lastUsedAt)
+	k.RotatedAt = nullTimePtr(rotatedAt)
+	k.RevokedAt = nullTimePtr(revokedAt)
+	return nil
+}
+
+// nullTimePtr возвращает nil для NULL и указатель на время иначе
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// CreateAPIKey сохраняет новый ключ
+func (s *PostgresAPIKeyStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := "INSERT INTO api_keys (name, prefix, hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING " + apiKeyColumns
+	created := &models.APIKey{}
+	err := traceQuery(ctx, "api_keys.insert", query, func(ctx context.Context) error {
+		return scanAPIKey(s.DB.QueryRowContext(ctx, query,
+			key.Name, key.Prefix, key.Hash, pq.StringArray(key.Scopes), key.ExpiresAt), created)
+	})
+	if err != nil {
+		return fmt.Errorf("storage.CreateAPIKey: %w", classifyPostgresError(ctx, err))
+	}
+	*key = *created
+	return nil
+}
+
+// GetAPIKey возвращает ключ по ID
+func (s *PostgresAPIKeyStorage) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE id = $1"
+	key := &models.APIKey{}
+	err := traceQuery(ctx, "api_keys.select_by_id", query, func(ctx context.Context) error {
+		return scanAPIKey(s.DB.QueryRowContext(ctx, query, id), key)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("storage.GetAPIKey: ключ с ID %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage.GetAPIKey: %w", classifyPostgresError(ctx, err))
+	}
+	return key, nil
+}
+
+// GetAPIKeyByPrefix возвращает ключ по открытой части
+func (s *PostgresAPIKeyStorage) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE prefix = $1"
+	key := &models.APIKey{}
+	err := traceQuery(ctx, "api_keys.select_by_prefix", query, func(ctx context.Context) error {
+		return scanAPIKey(s.DB.QueryRowContext(ctx, query, prefix), key)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("storage.GetAPIKeyByPrefix: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage.GetAPIKeyByPrefix: %w", classifyPostgresError(ctx, err))
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает все ключи от новых к старым
+func (s *PostgresAPIKeyStorage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id DESC"
+	keys := []models.APIKey{}
+	err := traceQuery(ctx, "api_keys.list", query, func(ctx context.Context) error {
+		rows, err := s.DB.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var key models.APIKey
+			if err := scanAPIKey(rows, &key); err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage.ListAPIKeys: %w", classifyPostgresError(ctx, err))
+	}
+	return keys, nil
+}
+
+// RotateAPIKey заменяет открытую часть и хэш действующего ключа
+func (s *PostgresAPIKeyStorage) RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*models.APIKey, error) {
+	query := `UPDATE api_keys SET prefix = $2, hash = $3, rotated_at = CURRENT_TIMESTAMP
+    WHERE id = $1 AND revoked_at IS NULL RETURNING ` + apiKeyColumns
+	key := &models.APIKey{}
+	err := traceQuery(ctx, "api_keys.rotate", query, func(ctx context.Context) error {
+		return scanAPIKey(s.DB.QueryRowContext(ctx, query, id, prefix, hash), key)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("storage.RotateAPIKey: действующий ключ с ID %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage.RotateAPIKey: %w", classifyPostgresError(ctx, err))
+	}
+	return key, nil
+}
+
+// RevokeAPIKey отзывает ключ
+func (s *PostgresAPIKeyStorage) RevokeAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+    WHERE id = $1 AND revoked_at IS NULL RETURNING ` + apiKeyColumns
+	key := &models.APIKey{}
+	err := traceQuery(ctx, "api_keys.revoke", query, func(ctx context.Context) error {
+		return scanAPIKey(s.DB.QueryRowContext(ctx, query, id), key)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("storage.RevokeAPIKey: действующий ключ с ID %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage.RevokeAPIKey: %w", classifyPostgresError(ctx, err))
+	}
+	return key, nil
+}
+
+// TouchAPIKey запоминает время последнего использования ключа. Более раннее время
+// не затирает более позднее, записанное параллельным запросом.
+func (s *PostgresAPIKeyStorage) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	query := "UPDATE api_keys SET last_used_at = $2 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)"
+	err := traceQuery(ctx, "api_keys.touch", query, func(ctx context.Context) error {
+		_, err := s.DB.ExecContext(ctx, query, id, at)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("storage.TouchAPIKey: %w", classifyPostgresError(ctx, err))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// MockAPIKeyStorage - мок-реализация APIKeyStorage для тестов
+type MockAPIKeyStorage struct {
+	mu            sync.Mutex
+	Keys          map[int64]*models.APIKey
+	NextID        int64
+	SimulateError error
+}
+
+// NewMockAPIKeyStorage создает новый экземпляр MockAPIKeyStorage
+func NewMockAPIKeyStorage() *MockAPIKeyStorage {
+	return &MockAPIKeyStorage{Keys: make(map[int64]*models.APIKey), NextID: 1}
+}
+
+// copyAPIKey возвращает копию ключа, чтобы вызывающий не менял состояние мока
+func copyAPIKey(k *models.APIKey) *models.APIKey {
+	c := *k
+	c.Scopes = append([]string(nil), k.Scopes...)
+	return &c
+}
+
+// check проверяет контекст и SimulateError. Вызывается под m.mu
+func (m *MockAPIKeyStorage) check(ctx context.Context, op string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if m.SimulateError != nil {
+		return fmt.Errorf("%s: %w", op, m.SimulateError)
+	}
+	return nil
+}
+
+func (m *MockAPIKeyStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.CreateAPIKey"); err != nil {
+		return err
+	}
+	for _, existing := range m.Keys {
+		if existing.Prefix == key.Prefix {
+			return fmt.Errorf("storage.CreateAPIKey: %w", &ConflictError{Field: "prefix"})
+		}
+	}
+	key.ID = m.NextID
+	m.NextID++
+	key.CreatedAt = time.Now()
+	m.Keys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+func (m *MockAPIKeyStorage) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.GetAPIKey"); err != nil {
+		return nil, err
+	}
+	key, ok := m.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("storage.GetAPIKey: ключ с ID %d: %w", id, ErrNotFound)
+	}
+	return copyAPIKey(key), nil
+}
+
+func (m *MockAPIKeyStorage) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.GetAPIKeyByPrefix"); err != nil {
+		return nil, err
+	}
+	for _, key := range m.Keys {
+		if key.Prefix == prefix {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, fmt.Errorf("storage.GetAPIKeyByPrefix: %w", ErrNotFound)
+}
+
+func (m *MockAPIKeyStorage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.ListAPIKeys"); err != nil {
+		return nil, err
+	}
+	keys := make([]models.APIKey, 0, len(m.Keys))
+	for _, key := range m.Keys {
+		keys = append(keys, *copyAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+// activeKey возвращает неотозванный ключ. Вызывается под m.mu
+func (m *MockAPIKeyStorage) activeKey(op string, id int64) (*models.APIKey, error) {
+	key, ok := m.Keys[id]
+	if !ok || key.RevokedAt != nil {
+		return nil, fmt.Errorf("%s: действующий ключ с ID %d: %w", op, id, ErrNotFound)
+	}
+	return key, nil
+}
+
+func (m *MockAPIKeyStorage) RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.RotateAPIKey"); err != nil {
+		return nil, err
+	}
+	key, err := m.activeKey("storage.RotateAPIKey", id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	key.Prefix, key.Hash, key.RotatedAt = prefix, hash, &now
+	return copyAPIKey(key), nil
+}
+
+func (m *MockAPIKeyStorage) RevokeAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.RevokeAPIKey"); err != nil {
+		return nil, err
+	}
+	key, err := m.activeKey("storage.RevokeAPIKey", id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	return copyAPIKey(key), nil
+}
+
+func (m *MockAPIKeyStorage) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.TouchAPIKey"); err != nil {
+		return err
+	}
+	if key, ok := m.Keys[id]; ok && (key.LastUsedAt == nil || key.LastUsedAt.Before(at)) {
+		key.LastUsedAt = &at
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+const (
+	// DefaultAuditLimit — размер страницы журнала аудита, если limit не указан
+	DefaultAuditLimit = 50
+	// MaxAuditLimit — максимальный размер страницы журнала аудита
+	MaxAuditLimit = 200
+
+	// systemActor записывается в журнал, если в контексте нет сведений об инициаторе
+	systemActor = "system"
+)
+
+// AuditStorage хранит журнал аудита. Журнал только дополняется:
+// записи нельзя изменить или удалить.
+type AuditStorage interface {
+	// AppendAuditEntry добавляет запись и заполняет ее ID и CreatedAt.
+	// UserStorage вызывает его до фиксации изменения, поэтому ошибка записи отменяет изменение.
+	AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error
+	// ListAuditEntries возвращает записи от новых к старым
+	ListAuditEntries(ctx context.Context, q AuditQuery) (*AuditPage, error)
+}
+
+// AuditQuery описывает выборку из журнала аудита. Пустые поля не ограничивают выборку.
+type AuditQuery struct {
+	UserID int64
+	Actor  string
+	Action string
+	// From и To ограничивают время записи: From <= CreatedAt < To
+	From  time.Time
+	To    time.Time
+	Limit int
+	// BeforeID — курсор: выбираются записи с ID меньше указанного
+	BeforeID int64
+}
+
+// withDefaults подставляет значения по умолчанию для незаполненных полей
+func (q AuditQuery) withDefaults() AuditQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultAuditLimit
+	}
+	if q.Limit > MaxAuditLimit {
+		q.Limit = MaxAuditLimit
+	}
+	return q
+}
+
+// matches проверяет запись на соответствие фильтрам запроса (для MockAuditStorage)
+func (q AuditQuery) matches(e models.AuditEntry) bool {
+	switch {
+	case q.UserID != 0 && e.UserID != q.UserID,
+		q.Actor != "" && e.Actor != q.Actor,
+		q.Action != "" && e.Action != q.Action,
+		!q.From.IsZero() && e.CreatedAt.Before(q.From),
+		!q.To.IsZero() && !e.CreatedAt.Before(q.To),
+		q.BeforeID != 0 && e.ID >= q.BeforeID:
+		return false
+	}
+	return true
+}
+
+// AuditPage — одна страница журнала аудита
+type AuditPage struct {
+	Entries []models.AuditEntry
+	// NextCursor — значение BeforeID для следующей страницы; 0, если страница последняя
+	NextCursor int64
+}
+
+// newAuditPage формирует страницу из выборки, содержащей до q.Limit+1 записей
+func newAuditPage(entries []models.AuditEntry, q AuditQuery) *AuditPage {
+	page := &AuditPage{Entries: entries}
+	if len(entries) > q.Limit {
+		page.Entries = entries[:q.Limit]
+		page.NextCursor = page.Entries[q.Limit-1].ID
+	}
+	return page
+}
+
+// Размеры колонок actor и request_id журнала аудита
+const (
+	MaxAuditActorLength     = 200
+	MaxAuditRequestIDLength = 128
+)
+
+// AuditInfo — сведения об инициаторе изменения, которые попадают в журнал аудита
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo возвращает контекст, изменения в котором записываются в журнал от имени info
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+// AuditInfoFromContext возвращает сведения, сохраненные WithAuditInfo
+func AuditInfoFromContext(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	if info.Actor == "" {
+		info.Actor = systemActor
+	}
+	return info
+}
+
+// newAuditEntry строит запись журнала об изменении пользователя before -> after.
+// before равен nil при создании, after — при окончательном удалении.
+func newAuditEntry(ctx context.Context, action string, before, after *models.User) models.AuditEntry {
+	info := AuditInfoFromContext(ctx)
+	entry := models.AuditEntry{
+		Action:    action,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		Changes:   userChanges(before, after),
+	}
+	if after != nil {
+		entry.UserID = after.ID
+	} else if before != nil {
+		entry.UserID = before.ID
+	}
+	return entry
+}
+
+// auditedFields — поля пользователя, изменения которых попадают в журнал
+var auditedFields = []struct {
+	name  string
+	value func(u *models.User) interface{}
+}{
+	{"name", func(u *models.User) interface{} { return u.Name }},
+	{"email", func(u *models.User) interface{} { return u.Email }},
+	{"deleted_at", func(u *models.User) interface{} {
+		if u.DeletedAt == nil {
+			return nil
+		}
+		return u.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}},
+}
+
+// userChanges возвращает изменившиеся поля пользователя
+func userChanges(before, after *models.User) map[string]models.FieldChange {
+	changes := make(map[string]models.FieldChange)
+	for _, field := range auditedFields {
+		var oldValue, newValue interface{}
+		if before != nil {
+			oldValue = field.value(before)
+		}
+		if after != nil {
+			newValue = field.value(after)
+		}
+		if oldValue != newValue {
+			changes[field.name] = models.FieldChange{Old: oldValue, New: newValue}
+		}
+	}
+	return changes
+}
+
+// queryRower — общий интерфейс *sql.DB и *sql.Tx для запросов, возвращающих одну строку
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// contextWithTx передает транзакцию хранилищам, вызываемым внутри нее (например, журналу аудита)
+func contextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// queryRowerFromContext возвращает транзакцию из контекста или db, если транзакции нет
+func queryRowerFromContext(ctx context.Context, db *sql.DB) queryRower {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
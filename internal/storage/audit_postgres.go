@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// PostgresAuditStorage реализует AuditStorage для PostgreSQL (таблица user_audit_log)
+type PostgresAuditStorage struct {
+	DB *sql.DB
+}
+
+// NewPostgresAuditStorage создает новый экземпляр PostgresAuditStorage
+func NewPostgresAuditStorage(db *sql.DB) *PostgresAuditStorage {
+	return &PostgresAuditStorage{DB: db}
+}
+
+// AppendAuditEntry добавляет запись в журнал. Если ctx получен внутри транзакции
+// PostgresUserStorage, запись выполняется в той же транзакции, что и изменение.
+func (s *PostgresAuditStorage) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("storage.AppendAuditEntry: не удалось сериализовать изменения: %w", err)
+	}
+	query := `INSERT INTO user_audit_log (user_id, action, actor, request_id, changes)
+    VALUES ($1, $2, $3, $4, $5::jsonb) RETURNING id, created_at`
+	err = traceQuery(ctx, "user_audit_log.insert", query, func(ctx context.Context) error {
+		return queryRowerFromContext(ctx, s.DB).QueryRowContext(ctx, query,
+			entry.UserID, entry.Action, entry.Actor, entry.RequestID, string(changes)).Scan(&entry.ID, &entry.CreatedAt)
+	})
+	if err != nil {
+		return fmt.Errorf("storage.AppendAuditEntry: %w", classifyPostgresError(ctx, err))
+	}
+	return nil
+}
+
+// ListAuditEntries возвращает страницу журнала от новых записей к старым
+func (s *PostgresAuditStorage) ListAuditEntries(ctx context.Context, q AuditQuery) (*AuditPage, error) {
+	q = q.withDefaults()
+
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.UserID != 0 {
+		conditions = append(conditions, "user_id = "+arg(q.UserID))
+	}
+	if q.Actor != "" {
+		conditions = append(conditions, "actor = "+arg(q.Actor))
+	}
+	if q.Action != "" {
+		conditions = append(conditions, "action = "+arg(q.Action))
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(q.To))
+	}
+	if q.BeforeID != 0 {
+		conditions = append(conditions, "id < "+arg(q.BeforeID))
+	}
+
+	query := "SELECT id, user_id, action, actor, request_id, changes, created_at FROM user_audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(q.Limit+1)
+
+	entries := make([]models.AuditEntry, 0, q.Limit)
+	err := traceQuery(ctx, "user_audit_log.list", query, func(ctx context.Context) error {
+		rows, err := s.DB.QueryContext(ctx, query, args...)
+		if err != nil {
+			return classifyPostgresError(ctx, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var e models.AuditEntry
+			var changes []byte
+			if err := rows.Scan(&e.ID, &e.UserID, &e.Action, &e.Actor, &e.RequestID, &changes, &e.CreatedAt); err != nil {
+				return fmt.Errorf("ошибка сканирования строки: %w", classifyPostgresError(ctx, err))
+			}
+			if err := json.Unmarshal(changes, &e.Changes); err != nil {
+				return fmt.Errorf("некорректные изменения в записи %d: %w", e.ID, err)
+			}
+			entries = append(entries, e)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("ошибка после итерации: %w", classifyPostgresError(ctx, err))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage.ListAuditEntries: %w", err)
+	}
+	return newAuditPage(entries, q), nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// MockAuditStorage - мок-реализация AuditStorage для тестов
+type MockAuditStorage struct {
+	mu            sync.Mutex
+	Entries       []models.AuditEntry
+	NextID        int64
+	SimulateError error
+}
+
+// NewMockAuditStorage создает новый экземпляр MockAuditStorage
+func NewMockAuditStorage() *MockAuditStorage {
+	return &MockAuditStorage{NextID: 1}
+}
+
+func (m *MockAuditStorage) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("storage.AppendAuditEntry: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return m.SimulateError
+	}
+	// Как и PostgreSQL, значение длиннее колонки не записывается
+	if utf8.RuneCountInString(entry.Actor) > MaxAuditActorLength || utf8.RuneCountInString(entry.RequestID) > MaxAuditRequestIDLength {
+		return fmt.Errorf("storage.AppendAuditEntry: значение длиннее колонки журнала аудита")
+	}
+	entry.ID = m.NextID
+	m.NextID++
+	entry.CreatedAt = time.Now()
+	m.Entries = append(m.Entries, *entry)
+	return nil
+}
+
+func (m *MockAuditStorage) ListAuditEntries(ctx context.Context, q AuditQuery) (*AuditPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("storage.ListAuditEntries: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return nil, m.SimulateError
+	}
+	q = q.withDefaults()
+	entries := []models.AuditEntry{}
+	for _, e := range m.Entries {
+		if q.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+	if len(entries) > q.Limit+1 {
+		entries = entries[:q.Limit+1]
+	}
+	return newAuditPage(entries, q), nil
+}
+
+// Reset очищает журнал
+func (m *MockAuditStorage) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries = nil
+	m.NextID = 1
+	m.SimulateError = nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// CredentialStorage хранит пароли пользователей и их сессии. Пользователи в корзине
+// не могут войти, а их сессии не принимаются; окончательное удаление пользователя
+// удаляет и пароль, и сессии.
+type CredentialStorage interface {
+	// GetPasswordByEmail находит пользователя вне корзины по email вместе с его паролем.
+	// ErrNotFound, если такого пользователя нет или пароль ему не задан.
+	GetPasswordByEmail(ctx context.Context, email string) (*models.User, *models.PasswordCredential, error)
+	// GetPassword возвращает пароль пользователя. ErrNotFound, если пароль не задан.
+	GetPassword(ctx context.Context, userID int64) (*models.PasswordCredential, error)
+	// SetPassword задает или заменяет пароль пользователя вне корзины, снимает блокировку
+	// и отзывает все его сессии. ErrNotFound, если пользователь не найден.
+	SetPassword(ctx context.Context, userID int64, hash string) error
+	// RemovePassword удаляет пароль и отзывает все сессии пользователя.
+	// ErrNotFound, если пароль не задан.
+	RemovePassword(ctx context.Context, userID int64) error
+	// RecordLoginFailure учитывает неудачный вход. Когда неудач подряд становится
+	// maxFailures, вход блокируется до lockUntil, а счетчик сбрасывается.
+	// Возвращает пароль с обновленным счетчиком.
+	RecordLoginFailure(ctx context.Context, userID int64, maxFailures int, lockUntil time.Time) (*models.PasswordCredential, error)
+	// ResetLoginFailures сбрасывает счетчик неудачных входов и блокировку: после успешного
+	// входа или по запросу администратора. ErrNotFound, если пароль не задан.
+	ResetLoginFailures(ctx context.Context, userID int64) error
+
+	// CreateSession сохраняет сессию и заполняет ее ID, CreatedAt и LastSeenAt
+	CreateSession(ctx context.Context, session *models.Session) error
+	// GetSessionByTokenHash находит неотозванную сессию пользователя вне корзины по хэшу
+	// токена. Срок действия и простой проверяет вызывающий.
+	GetSessionByTokenHash(ctx context.Context, hash string) (*models.Session, error)
+	// ListSessions возвращает неотозванные и неистекшие сессии пользователя от новых к старым
+	ListSessions(ctx context.Context, userID int64) ([]models.Session, error)
+	// RevokeSession отзывает сессию id пользователя userID.
+	// ErrNotFound, если такой сессии нет или она уже отозвана.
+	RevokeSession(ctx context.Context, userID, id int64) error
+	// RevokeSessions отзывает все сессии пользователя и возвращает их количество
+	RevokeSessions(ctx context.Context, userID int64) (int64, error)
+	// TouchSession запоминает время последнего запроса с сессией
+	TouchSession(ctx context.Context, id int64, at time.Time) error
+}
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// PostgresCredentialStorage реализует CredentialStorage для PostgreSQL
+// (таблицы user_passwords и user_sessions)
+type PostgresCredentialStorage struct {
+	DB *sql.DB
+	// Audit получает записи об изменении пароля и блокировках входа; nil отключает аудит
+	Audit AuditStorage
+}
+
+// NewPostgresCredentialStorage создает новый экземпляр PostgresCredentialStorage
+// с журналом аудита в той же базе данных
+func NewPostgresCredentialStorage(db *sql.DB) *PostgresCredentialStorage {
+	return &PostgresCredentialStorage{DB: db, Audit: NewPostgresAuditStorage(db)}
+}
+
+// passwordColumns — колонки user_passwords в порядке, ожидаемом scanPassword
+const passwordColumns = "user_id, hash, failed_attempts, locked_until, updated_at"
+
+// scanPassword читает строку, выбранную по passwordColumns
+func scanPassword(row rowScanner, c *models.PasswordCredential) error {
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&c.UserID, &c.Hash, &c.FailedAttempts, &lockedUntil, &c.UpdatedAt); err != nil {
+		return err
+	}
+	c.LockedUntil = nullTimePtr(lockedUntil)
+	return nil
+}
+
+// sessionColumns — колонки user_sessions в порядке, ожидаемом scanSession
+const sessionColumns = "id, user_id, token_hash, created_at, expires_at, last_seen_at, revoked_at, ip, user_agent"
+
+// scanSession читает строку, выбранную по sessionColumns
+func scanSession(row rowScanner, s *models.Session) error {
+	var revokedAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.UserID, &s.TokenHash, &s.CreatedAt, &s.ExpiresAt, &s.LastSeenAt,
+		&revokedAt, &s.IP, &s.UserAgent); err != nil {
+		return err
+	}
+	s.RevokedAt = nullTimePtr(revokedAt)
+	return nil
+}
+
+// GetPasswordByEmail находит пользователя вне корзины и его пароль по email
+func (s *PostgresCredentialStorage) GetPasswordByEmail(ctx context.Context, email string) (*models.User, *models.PasswordCredential, error) {
+	query := `SELECT u.id, u.name, u.email, u.created_at, u.version, u.deleted_at,
+    p.user_id, p.hash, p.failed_attempts, p.locked_until, p.updated_at
+    FROM users u JOIN user_passwords p ON p.user_id = u.id
+    WHERE u.email = $1 AND u.deleted_at IS NULL`
+	user, cred := &models.User{}, &models.PasswordCredential{}
+	err := traceQuery(ctx, "user_passwords.select_by_email", query, func(ctx context.Context) error {
+		var deletedAt, lockedUntil sql.NullTime
+		err := s.DB.QueryRowContext(ctx, query, email).Scan(
+			&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.Version, &deletedAt,
+			&cred.UserID, &cred.Hash, &cred.FailedAttempts, &lockedUntil, &cred.UpdatedAt)
+		user.DeletedAt = nullTimePtr(deletedAt)
+		cred.LockedUntil = nullTimePtr(lockedUntil)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("storage.GetPasswordByEmail: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage.GetPasswordByEmail: %w", classifyPostgresError(ctx, err))
+	}
+	return user, cred, nil
+}
+
+// GetPassword возвращает пароль пользователя
+func (s *PostgresCredentialStorage) GetPassword(ctx context.Context, userID int64) (*models.PasswordCredential, error) {
+	query := "SELECT " + passwordColumns + " FROM user_passwords WHERE user_id = $1"
+	cred := &models.PasswordCredential{}
+	err := traceQuery(ctx, "user_passwords.select", query, func(ctx context.Context) error {
+		return scanPassword(s.DB.QueryRowContext(ctx, query, userID), cred)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("storage.GetPassword: пароль пользователя с ID %d: %w", userID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage.GetPassword: %w", classifyPostgresError(ctx, err))
+	}
+	return cred, nil
+}
+
+// lockPassword читает пароль пользователя и блокирует строку до конца транзакции
+func lockPassword(ctx context.Context, tx *sql.Tx, userID int64) (*models.PasswordCredential, error) {
+	query := "SELECT " + passwordColumns + " FROM user_passwords WHERE user_id = $1 FOR UPDATE"
+	cred := &models.PasswordCredential{}
+	err := traceQuery(ctx, "user_passwords.lock", query, func(ctx context.Context) error {
+		return scanPassword(tx.QueryRowContext(ctx, query, userID), cred)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("пароль пользователя с ID %d: %w", userID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, classifyPostgresError(ctx, err)
+	}
+	return cred, nil
+}
+
+// revokeSessionsTx отзывает все сессии пользователя в транзакции tx
+func revokeSessionsTx(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := "UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL"
+	err := traceQuery(ctx, "user_sessions.revoke_all", query, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, query, userID)
+		return err
+	})
+	if err != nil {
+		return classifyPostgresError(ctx, err)
+	}
+	return nil
+}
+
+// passwordAuditEntry — запись журнала о действии с паролем; значения полей в нее не попадают
+func passwordAuditEntry(ctx context.Context, action string, userID int64) models.AuditEntry {
+	user := &models.User{ID: userID}
+	return newAuditEntry(ctx, action, user, user)
+}
+
+// SetPassword задает или заменяет пароль и отзывает сессии пользователя
+func (s *PostgresCredentialStorage) SetPassword(ctx context.Context, userID int64, hash string) error {
+	return runInTx(ctx, s.DB, s.Audit, "storage.SetPassword", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		if _, err := lockUser(ctx, tx, userID, activeUserCondition); err != nil {
+			return nil, err
+		}
+		query := `INSERT INTO user_passwords (user_id, hash) VALUES ($1, $2)
+    ON CONFLICT (user_id) DO UPDATE SET hash = EXCLUDED.hash, failed_attempts = 0,
+    locked_until = NULL, updated_at = CURRENT_TIMESTAMP`
+		err := traceQuery(ctx, "user_passwords.upsert", query, func(ctx context.Context) error {
+			_, err := tx.ExecContext(ctx, query, userID, hash)
+			return err
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		if err := revokeSessionsTx(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+		return []models.AuditEntry{passwordAuditEntry(ctx, models.AuditActionSetPassword, userID)}, nil
+	})
+}
+
+// RemovePassword удаляет пароль и отзывает сессии пользователя
+func (s *PostgresCredentialStorage) RemovePassword(ctx context.Context, userID int64) error {
+	return runInTx(ctx, s.DB, s.Audit, "storage.RemovePassword", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		query := "DELETE FROM user_passwords WHERE user_id = $1"
+		var deleted int64
+		err := traceQuery(ctx, "user_passwords.delete", query, func(ctx context.Context) error {
+			result, err := tx.ExecContext(ctx, query, userID)
+			if err != nil {
+				return err
+			}
+			deleted, err = result.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		if deleted == 0 {
+			return nil, fmt.Errorf("пароль пользователя с ID %d: %w", userID, ErrNotFound)
+		}
+		if err := revokeSessionsTx(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+		return []models.AuditEntry{passwordAuditEntry(ctx, models.AuditActionRemovePassword, userID)}, nil
+	})
+}
+
+// RecordLoginFailure учитывает неудачный вход и при необходимости блокирует вход
+func (s *PostgresCredentialStorage) RecordLoginFailure(ctx context.Context, userID int64, maxFailures int, lockUntil time.Time) (*models.PasswordCredential, error) {
+	var cred *models.PasswordCredential
+	err := runInTx(ctx, s.DB, s.Audit, "storage.RecordLoginFailure", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		var err error
+		if cred, err = lockPassword(ctx, tx, userID); err != nil {
+			return nil, err
+		}
+		var entries []models.AuditEntry
+		cred.FailedAttempts++
+		if maxFailures > 0 && cred.FailedAttempts >= maxFailures {
+			cred.FailedAttempts, cred.LockedUntil = 0, &lockUntil
+			entries = append(entries, passwordAuditEntry(ctx, models.AuditActionLock, userID))
+		}
+		query := "UPDATE user_passwords SET failed_attempts = $2, locked_until = $3 WHERE user_id = $1"
+		err = traceQuery(ctx, "user_passwords.record_failure", query, func(ctx context.Context) error {
+			_, err := tx.ExecContext(ctx, query, userID, cred.FailedAttempts, cred.LockedUntil)
+			return err
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// ResetLoginFailures сбрасывает счетчик неудачных входов и блокировку
+func (s *PostgresCredentialStorage) ResetLoginFailures(ctx context.Context, userID int64) error {
+	return runInTx(ctx, s.DB, s.Audit, "storage.ResetLoginFailures", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		cred, err := lockPassword(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		query := "UPDATE user_passwords SET failed_attempts = 0, locked_until = NULL WHERE user_id = $1"
+		err = traceQuery(ctx, "user_passwords.reset_failures", query, func(ctx context.Context) error {
+			_, err := tx.ExecContext(ctx, query, userID)
+			return err
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		if cred.Locked(time.Now()) {
+			return []models.AuditEntry{passwordAuditEntry(ctx, models.AuditActionUnlock, userID)}, nil
+		}
+		return nil, nil
+	})
+}
+
+// CreateSession сохраняет новую сессию
+func (s *PostgresCredentialStorage) CreateSession(ctx context.Context, session *models.Session) error {
+	query := `INSERT INTO user_sessions (user_id, token_hash, expires_at, ip, user_agent)
+    VALUES ($1, $2, $3, $4, $5) RETURNING ` + sessionColumns
+	created := &models.Session{}
+	err := traceQuery(ctx, "user_sessions.insert", query, func(ctx context.Context) error {
+		return scanSession(s.DB.QueryRowContext(ctx, query, session.UserID, session.TokenHash,
+			session.ExpiresAt, session.IP, session.UserAgent), created)
+	})
+	if err != nil {
+		return fmt.Errorf("storage.CreateSession: %w", classifyPostgresError(ctx, err))
+	}
+	*session = *created
+	return nil
+}
+
+// GetSessionByTokenHash находит неотозванную сессию пользователя вне корзины
+func (s *PostgresCredentialStorage) GetSessionByTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	query := "SELECT " + sessionColumns + ` FROM user_sessions
+    WHERE token_hash = $1 AND revoked_at IS NULL
+    AND EXISTS (SELECT 1 FROM users u WHERE u.id = user_sessions.user_id AND u.deleted_at IS NULL)`
+	session := &models.Session{}
+	err := traceQuery(ctx, "user_sessions.select_by_token", query, func(ctx context.Context) error {
+		return scanSession(s.DB.QueryRowContext(ctx, query, hash), session)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("storage.GetSessionByTokenHash: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage.GetSessionByTokenHash: %w", classifyPostgresError(ctx, err))
+	}
+	return session, nil
+}
+
+// ListSessions возвращает действующие сессии пользователя от новых к старым
+func (s *PostgresCredentialStorage) ListSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	query := "SELECT " + sessionColumns + ` FROM user_sessions
+    WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP ORDER BY id DESC`
+	sessions := []models.Session{}
+	err := traceQuery(ctx, "user_sessions.list", query, func(ctx context.Context) error {
+		rows, err := s.DB.QueryContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var session models.Session
+			if err := scanSession(rows, &session); err != nil {
+				return err
+			}
+			sessions = append(sessions, session)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage.ListSessions: %w", classifyPostgresError(ctx, err))
+	}
+	return sessions, nil
+}
+
+// RevokeSession отзывает одну сессию пользователя
+func (s *PostgresCredentialStorage) RevokeSession(ctx context.Context, userID, id int64) error {
+	query := "UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $2 AND user_id = $1 AND revoked_at IS NULL"
+	var revoked int64
+	err := traceQuery(ctx, "user_sessions.revoke", query, func(ctx context.Context) error {
+		result, err := s.DB.ExecContext(ctx, query, userID, id)
+		if err != nil {
+			return err
+		}
+		revoked, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("storage.RevokeSession: %w", classifyPostgresError(ctx, err))
+	}
+	if revoked == 0 {
+		return fmt.Errorf("storage.RevokeSession: сессия %d пользователя с ID %d: %w", id, userID, ErrNotFound)
+	}
+	return nil
+}
+
+// RevokeSessions отзывает все сессии пользователя
+func (s *PostgresCredentialStorage) RevokeSessions(ctx context.Context, userID int64) (int64, error) {
+	query := "UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL"
+	var revoked int64
+	err := traceQuery(ctx, "user_sessions.revoke_all", query, func(ctx context.Context) error {
+		result, err := s.DB.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+		revoked, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("storage.RevokeSessions: %w", classifyPostgresError(ctx, err))
+	}
+	return revoked, nil
+}
+
+// TouchSession запоминает время последнего запроса с сессией. Более раннее время
+// не затирает более позднее, записанное параллельным запросом.
+func (s *PostgresCredentialStorage) TouchSession(ctx context.Context, id int64, at time.Time) error {
+	query := "UPDATE user_sessions SET last_seen_at = $2 WHERE id = $1 AND last_seen_at < $2"
+	err := traceQuery(ctx, "user_sessions.touch", query, func(ctx context.Context) error {
+		_, err := s.DB.ExecContext(ctx, query, id, at)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("storage.TouchSession: %w", classifyPostgresError(ctx, err))
+	}
+	return nil
+}
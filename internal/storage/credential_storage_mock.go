@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// MockCredentialStorage - мок-реализация CredentialStorage для тестов. Пользователей
+// берет из Users, как PostgresCredentialStorage — из таблицы users.
+type MockCredentialStorage struct {
+	mu            sync.Mutex
+	Users         *MockUserStorage
+	Passwords     map[int64]*models.PasswordCredential
+	Sessions      map[int64]*models.Session
+	NextSessionID int64
+	SimulateError error
+	// Audit получает записи об изменении пароля и блокировках; nil отключает аудит
+	Audit AuditStorage
+}
+
+// NewMockCredentialStorage создает новый экземпляр MockCredentialStorage поверх users
+// с тем же журналом аудита
+func NewMockCredentialStorage(users *MockUserStorage) *MockCredentialStorage {
+	return &MockCredentialStorage{
+		Users:         users,
+		Passwords:     make(map[int64]*models.PasswordCredential),
+		Sessions:      make(map[int64]*models.Session),
+		NextSessionID: 1,
+		Audit:         users.Audit,
+	}
+}
+
+// check проверяет контекст и SimulateError. Вызывается под m.mu
+func (m *MockCredentialStorage) check(ctx context.Context, op string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if m.SimulateError != nil {
+		return fmt.Errorf("%s: %w", op, m.SimulateError)
+	}
+	return nil
+}
+
+// activeUser возвращает копию пользователя вне корзины. Вызывается под m.mu
+func (m *MockCredentialStorage) activeUser(id int64) (*models.User, bool) {
+	m.Users.mu.Lock()
+	defer m.Users.mu.Unlock()
+	user, ok := m.Users.activeUser(id)
+	if !ok {
+		return nil, false
+	}
+	c := *user
+	return &c, true
+}
+
+// audit записывает запись о действии с паролем. Вызывается под m.mu
+func (m *MockCredentialStorage) audit(ctx context.Context, op, action string, userID int64) error {
+	if m.Audit == nil {
+		return nil
+	}
+	entry := passwordAuditEntry(ctx, action, userID)
+	if err := m.Audit.AppendAuditEntry(ctx, &entry); err != nil {
+		return fmt.Errorf("%s: запись в журнал аудита: %w", op, err)
+	}
+	return nil
+}
+
+// revokeAll отзывает все сессии пользователя. Вызывается под m.mu
+func (m *MockCredentialStorage) revokeAll(userID int64) int64 {
+	now := time.Now()
+	var revoked int64
+	for _, session := range m.Sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			revoked++
+		}
+	}
+	return revoked
+}
+
+func (m *MockCredentialStorage) GetPasswordByEmail(ctx context.Context, email string) (*models.User, *models.PasswordCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.GetPasswordByEmail"); err != nil {
+		return nil, nil, err
+	}
+	for id, cred := range m.Passwords {
+		if user, ok := m.activeUser(id); ok && user.Email == email {
+			c := *cred
+			return user, &c, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("storage.GetPasswordByEmail: %w", ErrNotFound)
+}
+
+func (m *MockCredentialStorage) GetPassword(ctx context.Context, userID int64) (*models.PasswordCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.GetPassword"); err != nil {
+		return nil, err
+	}
+	cred, ok := m.Passwords[userID]
+	if !ok {
+		return nil, fmt.Errorf("storage.GetPassword: пароль пользователя с ID %d: %w", userID, ErrNotFound)
+	}
+	c := *cred
+	return &c, nil
+}
+
+func (m *MockCredentialStorage) SetPassword(ctx context.Context, userID int64, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.SetPassword"); err != nil {
+		return err
+	}
+	if _, ok := m.activeUser(userID); !ok {
+		return fmt.Errorf("storage.SetPassword: пользователь с ID %d: %w", userID, ErrNotFound)
+	}
+	if err := m.audit(ctx, "storage.SetPassword", models.AuditActionSetPassword, userID); err != nil {
+		return err
+	}
+	m.Passwords[userID] = &models.PasswordCredential{UserID: userID, Hash: hash, UpdatedAt: time.Now()}
+	m.revokeAll(userID)
+	return nil
+}
+
+func (m *MockCredentialStorage) RemovePassword(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.RemovePassword"); err != nil {
+		return err
+	}
+	if _, ok := m.Passwords[userID]; !ok {
+		return fmt.Errorf("storage.RemovePassword: пароль пользователя с ID %d: %w", userID, ErrNotFound)
+	}
+	if err := m.audit(ctx, "storage.RemovePassword", models.AuditActionRemovePassword, userID); err != nil {
+		return err
+	}
+	delete(m.Passwords, userID)
+	m.revokeAll(userID)
+	return nil
+}
+
+func (m *MockCredentialStorage) RecordLoginFailure(ctx context.Context, userID int64, maxFailures int, lockUntil time.Time) (*models.PasswordCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.RecordLoginFailure"); err != nil {
+		return nil, err
+	}
+	cred, ok := m.Passwords[userID]
+	if !ok {
+		return nil, fmt.Errorf("storage.RecordLoginFailure: пароль пользователя с ID %d: %w", userID, ErrNotFound)
+	}
+	failures, lockedUntil := cred.FailedAttempts+1, cred.LockedUntil
+	if maxFailures > 0 && failures >= maxFailures {
+		if err := m.audit(ctx, "storage.RecordLoginFailure", models.AuditActionLock, userID); err != nil {
+			return nil, err
+		}
+		failures, lockedUntil = 0, &lockUntil
+	}
+	cred.FailedAttempts, cred.LockedUntil = failures, lockedUntil
+	c := *cred
+	return &c, nil
+}
+
+func (m *MockCredentialStorage) ResetLoginFailures(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.ResetLoginFailures"); err != nil {
+		return err
+	}
+	cred, ok := m.Passwords[userID]
+	if !ok {
+		return fmt.Errorf("storage.ResetLoginFailures: пароль пользователя с ID %d: %w", userID, ErrNotFound)
+	}
+	if cred.Locked(time.Now()) {
+		if err := m.audit(ctx, "storage.ResetLoginFailures", models.AuditActionUnlock, userID); err != nil {
+			return err
+		}
+	}
+	cred.FailedAttempts, cred.LockedUntil = 0, nil
+	return nil
+}
+
+func (m *MockCredentialStorage) CreateSession(ctx context.Context, session *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.CreateSession"); err != nil {
+		return err
+	}
+	for _, existing := range m.Sessions {
+		if existing.TokenHash == session.TokenHash {
+			return fmt.Errorf("storage.CreateSession: %w", &ConflictError{Field: "token"})
+		}
+	}
+	now := time.Now()
+	session.ID = m.NextSessionID
+	m.NextSessionID++
+	session.CreatedAt, session.LastSeenAt = now, now
+	c := *session
+	m.Sessions[session.ID] = &c
+	return nil
+}
+
+func (m *MockCredentialStorage) GetSessionByTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.GetSessionByTokenHash"); err != nil {
+		return nil, err
+	}
+	for _, session := range m.Sessions {
+		if session.TokenHash != hash || session.RevokedAt != nil {
+			continue
+		}
+		if _, ok := m.activeUser(session.UserID); ok {
+			c := *session
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("storage.GetSessionByTokenHash: %w", ErrNotFound)
+}
+
+func (m *MockCredentialStorage) ListSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.ListSessions"); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, session := range m.Sessions {
+		if session.UserID == userID && session.RevokedAt == nil && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID > sessions[j].ID })
+	return sessions, nil
+}
+
+func (m *MockCredentialStorage) RevokeSession(ctx context.Context, userID, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.RevokeSession"); err != nil {
+		return err
+	}
+	session, ok := m.Sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return fmt.Errorf("storage.RevokeSession: сессия %d пользователя с ID %d: %w", id, userID, ErrNotFound)
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return nil
+}
+
+func (m *MockCredentialStorage) RevokeSessions(ctx context.Context, userID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.RevokeSessions"); err != nil {
+		return 0, err
+	}
+	return m.revokeAll(userID), nil
+}
+
+func (m *MockCredentialStorage) TouchSession(ctx context.Context, id int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.check(ctx, "storage.TouchSession"); err != nil {
+		return err
+	}
+	if session, ok := m.Sessions[id]; ok && session.LastSeenAt.Before(at) {
+		session.LastSeenAt = at
+	}
+	return nil
+}
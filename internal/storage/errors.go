@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// Ошибки хранилища. Реализации UserStorage оборачивают их через %w,
+// а обработчики сопоставляют со статусами HTTP через errors.Is/errors.As.
+var (
+	// ErrNotFound — запрошенная запись не существует
+	ErrNotFound = errors.New("запись не найдена")
+	// ErrConflict — нарушено ограничение уникальности (см. ConflictError)
+	ErrConflict = errors.New("конфликт уникальности")
+	// ErrValidation — данные не прошли проверку хранилища (см. ValidationError)
+	ErrValidation = errors.New("некорректные данные")
+	// ErrUnavailable — хранилище временно недоступно
+	ErrUnavailable = errors.New("хранилище недоступно")
+	// ErrVersionMismatch — запись изменилась после того, как клиент ее прочитал
+	ErrVersionMismatch = errors.New("версия записи не совпадает")
+)
+
+// ConflictError описывает нарушение уникальности конкретного поля
+type ConflictError struct {
+	Field string
+	Err   error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("значение поля '%s' уже используется", e.Field)
+}
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+func (e *ConflictError) Unwrap() error { return e.Err }
+
+// ValidationError описывает значение поля, отвергнутое хранилищем
+type ValidationError struct {
+	Field   string
+	Message string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("поле '%s': %s", e.Field, e.Message)
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// constraintFields сопоставляет ограничения таблиц с полями модели
+var constraintFields = map[string]string{
+	"users_email_key":              "email",
+	"users_email_active_key":       "email",
+	"api_keys_prefix_key":          "prefix",
+	"user_sessions_token_hash_key": "token",
+}
+
+// classifyPostgresError переводит ошибки драйвера в ошибки пакета storage.
+// Если ctx уже отменен, результат оборачивает ctx.Err(): драйвер сообщает об
+// отмене собственной ошибкой, а обработчикам нужен context.DeadlineExceeded.
+// Неизвестные ошибки возвращаются без изменений.
+func classifyPostgresError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code.Name() == "unique_violation":
+			field := constraintFields[pqErr.Constraint]
+			if field == "" {
+				field = pqErr.Column
+			}
+			return &ConflictError{Field: field, Err: err}
+		case pqErr.Code.Name() == "string_data_right_truncation":
+			return &ValidationError{Field: pqErr.Column, Message: "значение слишком длинное", Err: err}
+		case pqErr.Code.Name() == "not_null_violation":
+			return &ValidationError{Field: pqErr.Column, Message: "значение обязательно", Err: err}
+		case pqErr.Code.Name() == "check_violation":
+			return &ValidationError{Field: pqErr.Column, Message: "значение не прошло проверку", Err: err}
+		case pqErr.Code.Name() == "query_canceled":
+			return err
+		case pqErr.Code.Class() == "08", // connection_exception
+			pqErr.Code.Class() == "53", // insufficient_resources
+			pqErr.Code.Class() == "57": // operator_intervention (в т.ч. admin_shutdown)
+			return fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestClassifyPostgresError(t *testing.T) {
+	t.Run("Нарушение уникальности email", func(t *testing.T) {
+		err := classifyPostgresError(context.Background(), &pq.Error{Code: "23505", Constraint: "users_email_key"})
+		var conflictErr *ConflictError
+		if !errors.As(err, &conflictErr) || conflictErr.Field != "email" {
+			t.Fatalf("ожидалась ConflictError по полю email, получено %v", err)
+		}
+		if !errors.Is(err, ErrConflict) {
+			t.Error("ConflictError должна сопоставляться с ErrConflict")
+		}
+	})
+
+	t.Run("Нарушение уникальности открытой части ключа API", func(t *testing.T) {
+		err := classifyPostgresError(context.Background(), &pq.Error{Code: "23505", Constraint: "api_keys_prefix_key"})
+		var conflictErr *ConflictError
+		if !errors.As(err, &conflictErr) || conflictErr.Field != "prefix" {
+			t.Fatalf("ожидалась ConflictError по полю prefix, получено %v", err)
+		}
+	})
+
+	t.Run("Слишком длинное значение", func(t *testing.T) {
+		err := classifyPostgresError(context.Background(), &pq.Error{Code: "22001"})
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("ожидалась ErrValidation, получено %v", err)
+		}
+	})
+
+	t.Run("Обрыв соединения", func(t *testing.T) {
+		err := classifyPostgresError(context.Background(), &pq.Error{Code: "08006"})
+		if !errors.Is(err, ErrUnavailable) {
+			t.Errorf("ожидалась ErrUnavailable, получено %v", err)
+		}
+	})
+
+	t.Run("Истекший дедлайн контекста", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+		err := classifyPostgresError(ctx, &pq.Error{Code: "57014"})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ожидалась context.DeadlineExceeded, получено %v", err)
+		}
+	})
+
+	t.Run("Прочие ошибки не меняются", func(t *testing.T) {
+		original := fmt.Errorf("синтаксическая ошибка")
+		if err := classifyPostgresError(context.Background(), original); err != original {
+			t.Errorf("ошибка должна вернуться без изменений, получено %v", err)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/metrics"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+// InstrumentedUserStorage оборачивает любую реализацию UserStorage и измеряет ее методы:
+// длительность каждого вызова и число ошибок по видам. Так измеряются и PostgreSQL, и мок.
+type InstrumentedUserStorage struct {
+	Next UserStorage
+
+	duration *metrics.HistogramVec
+	errors   *metrics.CounterVec
+}
+
+// NewInstrumentedUserStorage регистрирует метрики хранилища в reg и возвращает обертку над next
+func NewInstrumentedUserStorage(next UserStorage, reg *metrics.Registry) *InstrumentedUserStorage {
+	return &InstrumentedUserStorage{
+		Next: next,
+		duration: reg.NewHistogramVec("storage_operation_duration_seconds",
+			"Длительность операций хранилища пользователей в секундах", nil, "operation"),
+		errors: reg.NewCounterVec("storage_operation_errors_total",
+			"Число ошибок операций хранилища пользователей по видам", "operation", "error"),
+	}
+}
+
+// observe записывает длительность операции и, если err не nil, ее вид
+func (s *InstrumentedUserStorage) observe(operation string, started time.Time, err error) {
+	s.duration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+	if err != nil {
+		s.errors.WithLabelValues(operation, ErrorKind(err)).Inc()
+	}
+}
+
+// ErrorKind возвращает вид ошибки хранилища для меток метрик: not_found, conflict, validation,
+// version_mismatch, unavailable, timeout, canceled или other
+func ErrorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrConflict):
+		return "conflict"
+	case errors.Is(err, ErrValidation):
+		return "validation"
+	case errors.Is(err, ErrVersionMismatch):
+		return "version_mismatch"
+	case errors.Is(err, ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "other"
+}
+
+func (s *InstrumentedUserStorage) CreateUser(ctx context.Context, user *models.User) (int64, error) {
+	started := time.Now()
+	result, err := s.Next.CreateUser(ctx, user)
+	s.observe("CreateUser", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	started := time.Now()
+	result, err := s.Next.GetUserByID(ctx, id)
+	s.observe("GetUserByID", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) ListUsers(ctx context.Context, q UserQuery) (*UserPage, error) {
+	started := time.Now()
+	result, err := s.Next.ListUsers(ctx, q)
+	s.observe("ListUsers", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) SearchUsers(ctx context.Context, q string, limit int) ([]models.UserSearchResult, error) {
+	started := time.Now()
+	result, err := s.Next.SearchUsers(ctx, q, limit)
+	s.observe("SearchUsers", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) UpdateUser(ctx context.Context, user *models.User) error {
+	started := time.Now()
+	err := s.Next.UpdateUser(ctx, user)
+	s.observe("UpdateUser", started, err)
+	return err
+}
+
+func (s *InstrumentedUserStorage) CompareAndSwapUser(ctx context.Context, user *models.User, expectedVersion int64) error {
+	started := time.Now()
+	err := s.Next.CompareAndSwapUser(ctx, user, expectedVersion)
+	s.observe("CompareAndSwapUser", started, err)
+	return err
+}
+
+func (s *InstrumentedUserStorage) PatchUser(ctx context.Context, id int64, mutate func(user *models.User) error) (*models.User, error) {
+	started := time.Now()
+	result, err := s.Next.PatchUser(ctx, id, mutate)
+	s.observe("PatchUser", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) DeleteUser(ctx context.Context, id int64) error {
+	started := time.Now()
+	err := s.Next.DeleteUser(ctx, id)
+	s.observe("DeleteUser", started, err)
+	return err
+}
+
+func (s *InstrumentedUserStorage) RestoreUser(ctx context.Context, id int64) (*models.User, error) {
+	started := time.Now()
+	result, err := s.Next.RestoreUser(ctx, id)
+	s.observe("RestoreUser", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) PurgeUser(ctx context.Context, id int64) error {
+	started := time.Now()
+	err := s.Next.PurgeUser(ctx, id)
+	s.observe("PurgeUser", started, err)
+	return err
+}
+
+func (s *InstrumentedUserStorage) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	started := time.Now()
+	result, err := s.Next.PurgeDeletedBefore(ctx, cutoff)
+	s.observe("PurgeDeletedBefore", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) GetUserAsOf(ctx context.Context, id int64, at time.Time) (*models.User, error) {
+	started := time.Now()
+	result, err := s.Next.GetUserAsOf(ctx, id, at)
+	s.observe("GetUserAsOf", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) ListUserVersions(ctx context.Context, id int64) ([]models.UserVersion, error) {
+	started := time.Now()
+	result, err := s.Next.ListUserVersions(ctx, id)
+	s.observe("ListUserVersions", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) GetUserVersion(ctx context.Context, id, version int64) (*models.UserVersion, error) {
+	started := time.Now()
+	result, err := s.Next.GetUserVersion(ctx, id, version)
+	s.observe("GetUserVersion", started, err)
+	return result, err
+}
+
+func (s *InstrumentedUserStorage) RevertUser(ctx context.Context, id, version int64) (*models.User, error) {
+	started := time.Now()
+	result, err := s.Next.RevertUser(ctx, id, version)
+	s.observe("RevertUser", started, err)
+	return result, err
+}
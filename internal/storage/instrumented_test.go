@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/metrics"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+func TestInstrumentedUserStorage(t *testing.T) {
+	mock := NewMockUserStorage()
+	reg := metrics.NewRegistry()
+	s := NewInstrumentedUserStorage(mock, reg)
+	ctx := context.Background()
+
+	seeded := mock.SeedUser(models.User{Name: "Alice", Email: "alice@example.com"})
+	if user, err := s.GetUserByID(ctx, seeded.ID); err != nil || user.Email != seeded.Email {
+		t.Fatalf("GetUserByID через обертку: получено %v, %v", user, err)
+	}
+	if _, err := s.GetUserByID(ctx, 999); err == nil {
+		t.Fatal("GetUserByID: ожидалась ErrNotFound")
+	}
+	if _, err := s.CreateUser(ctx, &models.User{Name: "Alice 2", Email: "alice@example.com"}); err == nil {
+		t.Fatal("CreateUser: ожидался конфликт email")
+	}
+	mock.SimulateError = fmt.Errorf("%w: соединение разорвано", ErrUnavailable)
+	if err := s.DeleteUser(ctx, seeded.ID); err == nil {
+		t.Fatal("DeleteUser: ожидалась ошибка хранилища")
+	}
+
+	var b strings.Builder
+	if err := reg.WriteText(&b); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	for _, want := range []string{
+		`storage_operation_duration_seconds_count{operation="GetUserByID"} 2`,
+		`storage_operation_duration_seconds_count{operation="CreateUser"} 1`,
+		`storage_operation_errors_total{operation="GetUserByID",error="not_found"} 1`,
+		`storage_operation_errors_total{operation="CreateUser",error="conflict"} 1`,
+		`storage_operation_errors_total{operation="DeleteUser",error="unavailable"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("в метриках нет '%s':\n%s", want, b.String())
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	testCases := map[string]error{
+		"not_found":        fmt.Errorf("GetUserByID: %w", ErrNotFound),
+		"conflict":         &ConflictError{Field: "email"},
+		"validation":       &ValidationError{Field: "name", Message: "значение слишком длинное"},
+		"version_mismatch": ErrVersionMismatch,
+		"timeout":          fmt.Errorf("%w: запрос прерван", context.DeadlineExceeded),
+		"canceled":         context.Canceled,
+		"other":            fmt.Errorf("неизвестная ошибка"),
+	}
+	for expected, err := range testCases {
+		if got := ErrorKind(err); got != expected {
+			t.Errorf("ErrorKind(%v): ожидалось '%s', получено '%s'", err, expected, got)
+		}
+	}
+}
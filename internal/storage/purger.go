@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// DefaultTrashRetention — сколько пользователь хранится в корзине до окончательного удаления
+	DefaultTrashRetention = 30 * 24 * time.Hour
+	// DefaultTrashPurgeInterval — как часто запускается очистка корзины
+	DefaultTrashPurgeInterval = time.Hour
+
+	// trashPurgerActor — инициатор окончательного удаления в журнале аудита
+	trashPurgerActor = "system:trash-purger"
+)
+
+// TrashPurger периодически окончательно удаляет пользователей,
+// которые находятся в корзине дольше Retention
+type TrashPurger struct {
+	Storage   UserStorage
+	Retention time.Duration
+	Interval  time.Duration
+	// OperationTimeout ограничивает один проход очистки; 0 — без ограничения
+	OperationTimeout time.Duration
+}
+
+// NewTrashPurger создает TrashPurger с настройками по умолчанию
+func NewTrashPurger(s UserStorage) *TrashPurger {
+	return &TrashPurger{
+		Storage:   s,
+		Retention: DefaultTrashRetention,
+		Interval:  DefaultTrashPurgeInterval,
+	}
+}
+
+// PurgeOnce выполняет один проход очистки и возвращает количество удаленных пользователей
+func (p *TrashPurger) PurgeOnce(ctx context.Context) (int64, error) {
+	ctx = WithAuditInfo(ctx, AuditInfo{Actor: trashPurgerActor})
+	if p.OperationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.OperationTimeout)
+		defer cancel()
+	}
+	purged, err := p.Storage.PurgeDeletedBefore(ctx, time.Now().Add(-p.Retention))
+	if err != nil {
+		return 0, fmt.Errorf("storage.TrashPurger: %w", err)
+	}
+	return purged, nil
+}
+
+// Run запускает очистку сразу и затем каждые Interval, пока ctx не будет отменен
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		purged, err := p.PurgeOnce(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Ошибка очистки корзины", "error", err)
+		} else if purged > 0 {
+			slog.InfoContext(ctx, "Корзина очищена", "purged", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
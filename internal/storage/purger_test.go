@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+func TestTrashPurger(t *testing.T) {
+	mock := NewMockUserStorage()
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Hour)
+	expired := mock.SeedUser(models.User{Name: "Old", Email: "old@example.com", DeletedAt: &old})
+	fresh := mock.SeedUser(models.User{Name: "Recent", Email: "recent@example.com", DeletedAt: &recent})
+	active := mock.SeedUser(models.User{Name: "Active", Email: "active@example.com"})
+
+	purger := NewTrashPurger(mock)
+	purger.Retention = 24 * time.Hour
+	purged, err := purger.PurgeOnce(context.Background())
+	if err != nil {
+		t.Fatalf("PurgeOnce: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeOnce: удалено %d пользователей, ожидался 1", purged)
+	}
+	if _, exists := mock.Users[expired.ID]; exists {
+		t.Errorf("пользователь, пролежавший в корзине дольше срока хранения, не удален")
+	}
+	for _, u := range []models.User{fresh, active} {
+		if _, exists := mock.Users[u.ID]; !exists {
+			t.Errorf("пользователь %s не должен был удаляться", u.Name)
+		}
+	}
+}
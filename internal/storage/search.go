@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+const (
+	// DefaultSearchLimit — количество результатов поиска, если limit не указан
+	DefaultSearchLimit = 20
+	// MaxSearchLimit — максимальное количество результатов поиска
+	MaxSearchLimit = 100
+
+	// trigramThreshold совпадает со значением pg_trgm.similarity_threshold по умолчанию
+	trigramThreshold = 0.3
+	// wordTrigramThreshold совпадает со значением pg_trgm.word_similarity_threshold по умолчанию
+	wordTrigramThreshold = 0.6
+
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// translitTable — транслитерация кириллицы в латиницу.
+// Должна совпадать с SQL-функцией translit_ru из миграции 0003.
+var translitTable = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// Transliterate приводит строку к нижнему регистру и переводит кириллицу в латиницу,
+// чтобы латинский запрос "dzetovetskiy" находил имя "Дзетовецкий"
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if latin, ok := translitTable[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// SearchWords разбивает поисковый запрос на слова из букв и цифр в нижнем регистре
+func SearchWords(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// prefixTSQuery строит выражение для to_tsquery('simple', ...), где каждое слово
+// ищется по префиксу. Слова содержат только буквы и цифры, поэтому синтаксис
+// tsquery через них внедрить нельзя.
+func prefixTSQuery(words []string) string {
+	parts := make([]string, len(words))
+	for i, w := range words {
+		parts[i] = w + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// trigrams возвращает множество триграмм строки по правилам pg_trgm:
+// каждое слово дополняется двумя пробелами слева и одним справа
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range SearchWords(s) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// trigramSimilarity — аналог функции similarity() из pg_trgm
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+// wordSimilarity приближает word_similarity() из pg_trgm: доля триграмм запроса,
+// найденных в наиболее похожем отрезке text из стольких же слов, сколько в запросе
+func wordSimilarity(q, text string) float64 {
+	queryTrigrams := trigrams(q)
+	queryWordCount := len(SearchWords(q))
+	textWords := SearchWords(text)
+	if len(queryTrigrams) == 0 || len(textWords) == 0 {
+		return 0
+	}
+	best := 0.0
+	for i := 0; i < len(textWords); i++ {
+		end := min(i+queryWordCount, len(textWords))
+		extentTrigrams := trigrams(strings.Join(textWords[i:end], " "))
+		common := 0
+		for t := range queryTrigrams {
+			if _, ok := extentTrigrams[t]; ok {
+				common++
+			}
+		}
+		best = max(best, float64(common)/float64(len(queryTrigrams)))
+	}
+	return best
+}
+
+// highlightMatches оборачивает в <mark> слова text, по которым пользователь найден. Слово
+// запроса отмечает слова text, начинающиеся с него самого или с его транслитерации, —
+// как префиксный tsquery по search_vector. Если таких нет, но пользователь найден нечетко,
+// отмечается слово с наибольшим триграммным сходством не ниже wordTrigramThreshold.
+// Текст экранируется как HTML до вставки разметки, поэтому результат безопасно выводить как HTML.
+func highlightMatches(text string, words []string) string {
+	var tokens []string
+	var isWord []bool
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		letter := unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])
+		if letter {
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		isWord = append(isWord, letter)
+		i = j
+	}
+
+	marked := make([]bool, len(tokens))
+	for _, w := range words {
+		latin := Transliterate(w)
+		found := false
+		for i, token := range tokens {
+			if isWord[i] && matchesPrefix(token, w, latin) {
+				marked[i], found = true, true
+			}
+		}
+		if found {
+			continue
+		}
+		best, bestSimilarity := -1, 0.0
+		for i, token := range tokens {
+			if !isWord[i] {
+				continue
+			}
+			// Та же мера, что у word_similarity в запросе: доля триграмм слова запроса в слове text
+			similarity := max(wordSimilarity(w, token), wordSimilarity(latin, Transliterate(token)))
+			if similarity >= wordTrigramThreshold && similarity > bestSimilarity {
+				best, bestSimilarity = i, similarity
+			}
+		}
+		if best >= 0 {
+			marked[best] = true
+		}
+	}
+
+	var b strings.Builder
+	for i, token := range tokens {
+		if marked[i] {
+			b.WriteString(highlightStart + html.EscapeString(token) + highlightStop)
+		} else {
+			b.WriteString(html.EscapeString(token))
+		}
+	}
+	return b.String()
+}
+
+// matchesPrefix сообщает, начинается ли token со слова запроса word или его транслитерация —
+// с транслитерации latin
+func matchesPrefix(token, word, latin string) bool {
+	return strings.HasPrefix(strings.ToLower(token), word) || (latin != "" && strings.HasPrefix(Transliterate(token), latin))
+}
+
+// userHighlights строит подсветку совпадений в имени и email пользователя.
+// Email в search_vector — одно слово, поэтому он подсвечивается целиком: при совпадении
+// по префиксу или при нечетком совпадении всего email с запросом.
+func userHighlights(u *models.User, words []string) map[string]string {
+	emailHighlight := html.EscapeString(u.Email)
+	if hasAnyPrefix(strings.ToLower(u.Email), words) || trigramSimilarity(u.Email, strings.Join(words, " ")) >= trigramThreshold {
+		emailHighlight = highlightStart + emailHighlight + highlightStop
+	}
+	return collectHighlights(map[string]string{"name": highlightMatches(u.Name, words), "email": emailHighlight})
+}
+
+func hasAnyPrefix(token string, words []string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(token, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectHighlights оставляет только поля, в которых действительно есть подсветка
+func collectHighlights(fields map[string]string) map[string]string {
+	var result map[string]string
+	for field, fragment := range fields {
+		if !strings.Contains(fragment, highlightStart) {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[field] = fragment
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+func TestTransliterate(t *testing.T) {
+	testCases := map[string]string{
+		"Дзетовецкий": "dzetovetskiy",
+		"Щукина Юлия": "shchukina yuliya",
+		"Объявление":  "obyavlenie",
+		"Mixed Смесь": "mixed smes",
+	}
+	for input, expected := range testCases {
+		if got := Transliterate(input); got != expected {
+			t.Errorf("Transliterate(%q) = %q, ожидалось %q", input, got, expected)
+		}
+	}
+}
+
+func TestTrigramSimilarity(t *testing.T) {
+	if sim := trigramSimilarity("Дзетовецкий", "дзетовецкий"); sim != 1 {
+		t.Errorf("сходство одинаковых строк без учета регистра должно быть 1, получено %f", sim)
+	}
+	if sim := trigramSimilarity("dzetovetskiy", "dzetovecky"); sim < trigramThreshold {
+		t.Errorf("сходство строк с опечаткой ниже порога: %f", sim)
+	}
+	if sim := trigramSimilarity("dzetovetskiy", "smith"); sim >= trigramThreshold {
+		t.Errorf("сходство разных строк выше порога: %f", sim)
+	}
+}
+
+func TestPrefixTSQuery(t *testing.T) {
+	if got := prefixTSQuery(SearchWords("Кирилл  & дзет:*")); got != "кирилл:* & дзет:*" {
+		t.Errorf("неверный tsquery: %q", got)
+	}
+}
+
+func TestUserHighlightsEscapesHTML(t *testing.T) {
+	u := &models.User{Name: `<img src=x onerror="alert(1)"> Иван`, Email: "img<b>@example.com"}
+	got := userHighlights(u, SearchWords("img"))
+	if expected := `&lt;<mark>img</mark> src=x onerror=&#34;alert(1)&#34;&gt; Иван`; got["name"] != expected {
+		t.Errorf("name: получено %q, ожидалось %q", got["name"], expected)
+	}
+	if expected := "<mark>img&lt;b&gt;@example.com</mark>"; got["email"] != expected {
+		t.Errorf("email: получено %q, ожидалось %q", got["email"], expected)
+	}
+	if got := userHighlights(u, SearchWords("петр")); got != nil {
+		t.Errorf("без совпадений подсветка должна отсутствовать, получено %v", got)
+	}
+}
+
+func TestUserHighlightsMatches(t *testing.T) {
+	u := &models.User{Name: "Кирилл Дзетовецкий", Email: "kirill@example.com"}
+	testCases := map[string]string{
+		"кир":             "<mark>Кирилл</mark> Дзетовецкий",
+		"дзет":            "Кирилл <mark>Дзетовецкий</mark>",
+		"dzetovetskiy":    "Кирилл <mark>Дзетовецкий</mark>",
+		"kir dzet":        "<mark>Кирилл</mark> <mark>Дзетовецкий</mark>",
+		"dzetovetskij":    "Кирилл <mark>Дзетовецкий</mark>",
+		"дзетовецкей":     "Кирилл <mark>Дзетовецкий</mark>",
+		"кирилл петров":   "<mark>Кирилл</mark> Дзетовецкий",
+		"совсем не похож": "",
+	}
+	for q, expected := range testCases {
+		if got := userHighlights(u, SearchWords(q))["name"]; got != expected {
+			t.Errorf("%q: получено %q, ожидалось %q", q, got, expected)
+		}
+	}
+	if got := userHighlights(u, SearchWords("krill example"))["email"]; got != "<mark>kirill@example.com</mark>" {
+		t.Errorf("email с опечаткой: получено %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer создает спаны запросов к PostgreSQL через глобальный TracerProvider (см. пакет tracing)
+var tracer = otel.Tracer("github.com/casanera/GiperboreyaTechnologies/internal/storage")
+
+// traceQuery выполняет run в дочернем спане запроса. statement — короткое имя запроса
+// ("users.select_active"), оно же имя спана; query — текст SQL с параметрами $N, без значений.
+// Ошибка run отмечается в спане, sql.ErrNoRows ошибкой не считается.
+func traceQuery(ctx context.Context, statement, query string, run func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, statement,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", statement),
+			attribute.String("db.query.text", query),
+		),
+	)
+	defer span.End()
+
+	err := run(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, ErrorKind(err))
+	}
+	return err
+}
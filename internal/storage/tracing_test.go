@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceQuery(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	parentCtx, parent := otel.Tracer("test").Start(context.Background(), "GET /api/v1/users/{id}")
+	query := "SELECT id FROM users WHERE id = $1"
+	var inner context.Context
+	if err := traceQuery(parentCtx, "users.select_by_id", query, func(ctx context.Context) error {
+		inner = ctx
+		return sql.ErrNoRows
+	}); err != sql.ErrNoRows {
+		t.Fatalf("traceQuery должна вернуть ошибку run без изменений, получено %v", err)
+	}
+	traceQuery(parentCtx, "users.update", query, func(ctx context.Context) error { return ErrUnavailable })
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ожидалось 3 спана, получено %d", len(spans))
+	}
+	selectSpan, updateSpan := spans[0], spans[1]
+	if selectSpan.Name() != "users.select_by_id" || selectSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("ожидался дочерний спан users.select_by_id, получено '%s' с родителем %s", selectSpan.Name(), selectSpan.Parent().SpanID())
+	}
+	if inner == nil || trace.SpanContextFromContext(inner).SpanID() != selectSpan.SpanContext().SpanID() {
+		t.Error("run должна получать контекст спана запроса")
+	}
+	if selectSpan.Status().Code == codes.Error {
+		t.Error("sql.ErrNoRows не должна отмечаться в спане как ошибка")
+	}
+	if updateSpan.Status().Code != codes.Error || updateSpan.Status().Description != "unavailable" {
+		t.Errorf("статус спана с ошибкой: получено %v", updateSpan.Status())
+	}
+	for _, a := range selectSpan.Attributes() {
+		if a.Key == "db.query.text" && a.Value.AsString() != query {
+			t.Errorf("db.query.text: получено '%s'", a.Value.AsString())
+		}
+	}
+}
@@ -1,129 +1,595 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"strings"
+	"time"
 
 	"github.com/casanera/GiperboreyaTechnologies/internal/models"
 
 	_ "github.com/lib/pq" // Драйвер PostgreSQL
 )
 
-// UserStorage определяет интерфейс для операций с пользователями
+// UserStorage определяет интерфейс для операций с пользователями.
+// Все методы прерываются при отмене ctx или истечении его дедлайна.
 type UserStorage interface {
-	CreateUser(user *models.User) (int64, error)
-	GetUserByID(id int64) (*models.User, error)
-	GetAllUsers() ([]models.User, error)
-	UpdateUser(user *models.User) error
-	DeleteUser(id int64) error
+	CreateUser(ctx context.Context, user *models.User) (int64, error)
+	GetUserByID(ctx context.Context, id int64) (*models.User, error)
+	ListUsers(ctx context.Context, q UserQuery) (*UserPage, error)
+	SearchUsers(ctx context.Context, q string, limit int) ([]models.UserSearchResult, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	// CompareAndSwapUser обновляет пользователя, только если его версия равна expectedVersion
+	CompareAndSwapUser(ctx context.Context, user *models.User, expectedVersion int64) error
+	// PatchUser атомарно читает пользователя, применяет к нему mutate и сохраняет результат.
+	// Ошибка mutate отменяет изменение и возвращается обернутой.
+	PatchUser(ctx context.Context, id int64, mutate func(user *models.User) error) (*models.User, error)
+	// DeleteUser переносит пользователя в корзину (мягкое удаление)
+	DeleteUser(ctx context.Context, id int64) error
+	// RestoreUser возвращает пользователя из корзины
+	RestoreUser(ctx context.Context, id int64) (*models.User, error)
+	// PurgeUser окончательно удаляет пользователя, в том числе из корзины
+	PurgeUser(ctx context.Context, id int64) error
+	// PurgeDeletedBefore окончательно удаляет пользователей, попавших в корзину раньше cutoff
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// GetUserAsOf возвращает пользователя в том состоянии, в котором он был в момент at.
+	// ErrNotFound, если пользователь тогда еще не существовал или находился в корзине.
+	GetUserAsOf(ctx context.Context, id int64, at time.Time) (*models.User, error)
+	// ListUserVersions возвращает все версии пользователя от первой к последней
+	ListUserVersions(ctx context.Context, id int64) ([]models.UserVersion, error)
+	// GetUserVersion возвращает одну версию пользователя
+	GetUserVersion(ctx context.Context, id, version int64) (*models.UserVersion, error)
+	// RevertUser возвращает пользователю имя и email из версии version как новое изменение
+	RevertUser(ctx context.Context, id, version int64) (*models.User, error)
 }
 
 // PostgresUserStorage реализует UserStorage для PostgreSQL
 type PostgresUserStorage struct {
 	DB *sql.DB
+	// Audit получает запись о каждом изменении в транзакции этого изменения; nil отключает аудит
+	Audit AuditStorage
+}
+
+// userColumns — колонки users в порядке, ожидаемом scanUser
+const userColumns = "id, name, email, created_at, version, deleted_at"
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser читает строку, выбранную по userColumns
+func scanUser(row rowScanner, u *models.User) error {
+	var deletedAt sql.NullTime
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.Version, &deletedAt); err != nil {
+		return err
+	}
+	u.DeletedAt = nil
+	if deletedAt.Valid {
+		u.DeletedAt = &deletedAt.Time
+	}
+	return nil
 }
 
 // NewPostgresUserStorage создает новый экземпляр PostgresUserStorage
+// с журналом аудита в той же базе данных
 func NewPostgresUserStorage(db *sql.DB) *PostgresUserStorage {
-	return &PostgresUserStorage{DB: db}
+	return &PostgresUserStorage{DB: db, Audit: NewPostgresAuditStorage(db)}
 }
 
-// CreateUsersTableIfNotExists создает таблицу users, если она еще не существует.
-func (s *PostgresUserStorage) CreateUsersTableIfNotExists() error {
-	query := `
-    CREATE TABLE IF NOT EXISTS users (
-        id SERIAL PRIMARY KEY,
-        name VARCHAR(100) NOT NULL,
-        email VARCHAR(100) UNIQUE NOT NULL,
-        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-    );`
-	_, err := s.DB.Exec(query)
+// Условия выбора строки в lockUser
+const (
+	activeUserCondition  = "deleted_at IS NULL"
+	trashedUserCondition = "deleted_at IS NOT NULL"
+	anyUserCondition     = "TRUE"
+)
+
+// inTx выполняет fn в транзакции и в ней же записывает в журнал аудита возвращенные fn записи.
+// Ошибка fn или журнала откатывает изменение целиком.
+func (s *PostgresUserStorage) inTx(ctx context.Context, op string, fn func(tx *sql.Tx) ([]models.AuditEntry, error)) error {
+	return runInTx(ctx, s.DB, s.Audit, op, fn)
+}
+
+// runInTx — inTx для любого хранилища с журналом аудита audit (nil отключает аудит)
+func runInTx(ctx context.Context, db *sql.DB, audit AuditStorage, op string, fn func(tx *sql.Tx) ([]models.AuditEntry, error)) error {
+	var tx *sql.Tx
+	err := traceQuery(ctx, "tx.begin", "BEGIN", func(ctx context.Context) error {
+		var err error
+		tx, err = db.BeginTx(ctx, nil)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: не удалось начать транзакцию: %w", op, classifyPostgresError(ctx, err))
+	}
+	defer tx.Rollback()
+
+	entries, err := fn(tx)
 	if err != nil {
-		return fmt.Errorf("не удалось создать таблицу users: %w", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if audit != nil {
+		txCtx := contextWithTx(ctx, tx)
+		for i := range entries {
+			if err := audit.AppendAuditEntry(txCtx, &entries[i]); err != nil {
+				return fmt.Errorf("%s: запись в журнал аудита: %w", op, err)
+			}
+		}
+	}
+	commit := func(context.Context) error { return tx.Commit() }
+	if err := traceQuery(ctx, "tx.commit", "COMMIT", commit); err != nil {
+		return fmt.Errorf("%s: не удалось зафиксировать транзакцию: %w", op, classifyPostgresError(ctx, err))
 	}
-	log.Println("Таблица 'users' проверена/создана успешно.")
 	return nil
 }
 
+// lockUser читает пользователя, подходящего под condition, и блокирует строку до конца транзакции
+func lockUser(ctx context.Context, tx *sql.Tx, id int64, condition string) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE id = $1 AND " + condition + " FOR UPDATE"
+	user := &models.User{}
+	err := traceQuery(ctx, "users.lock", query, func(ctx context.Context) error {
+		return scanUser(tx.QueryRowContext(ctx, query, id), user)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("пользователь с ID %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, classifyPostgresError(ctx, err)
+	}
+	return user, nil
+}
+
 // CreateUser добавляет нового пользователя в базу данных
-func (s *PostgresUserStorage) CreateUser(user *models.User) (int64, error) {
-	query := "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id"
-	var id int64
-	err := s.DB.QueryRow(query, user.Name, user.Email).Scan(&id)
+func (s *PostgresUserStorage) CreateUser(ctx context.Context, user *models.User) (int64, error) {
+	created := *user
+	err := s.inTx(ctx, "storage.CreateUser", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		query := "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING " + userColumns
+		err := traceQuery(ctx, "users.insert", query, func(ctx context.Context) error {
+			return scanUser(tx.QueryRowContext(ctx, query, user.Name, user.Email), &created)
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		return []models.AuditEntry{newAuditEntry(ctx, models.AuditActionCreate, nil, &created)}, nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("storage.CreateUser: %w", err)
+		return 0, err
 	}
-	return id, nil
+	user.CreatedAt, user.Version = created.CreatedAt, created.Version
+	return created.ID, nil
 }
 
 // GetUserByID получает пользователя по ID
-func (s *PostgresUserStorage) GetUserByID(id int64) (*models.User, error) {
-	query := "SELECT id, name, email FROM users WHERE id = $1"
+func (s *PostgresUserStorage) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE id = $1 AND deleted_at IS NULL"
 	user := &models.User{}
-	err := s.DB.QueryRow(query, id).Scan(&user.ID, &user.Name, &user.Email)
+	err := traceQuery(ctx, "users.select_by_id", query, func(ctx context.Context) error {
+		return scanUser(s.DB.QueryRowContext(ctx, query, id), user)
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("storage.GetUserByID: пользователь с ID %d не найден", id)
+			return nil, fmt.Errorf("storage.GetUserByID: пользователь с ID %d: %w", id, ErrNotFound)
 		}
-		return nil, fmt.Errorf("storage.GetUserByID: %w", err)
+		return nil, fmt.Errorf("storage.GetUserByID: %w", classifyPostgresError(ctx, err))
 	}
 	return user, nil
 }
 
-// GetAllUsers получает всех пользователей
-func (s *PostgresUserStorage) GetAllUsers() ([]models.User, error) {
-	query := "SELECT id, name, email FROM users ORDER BY id ASC"
-	rows, err := s.DB.Query(query)
+// ListUsers получает страницу пользователей с учетом фильтров и сортировки.
+// Пагинация keyset: следующая страница начинается строго после курсора q.After.
+func (s *PostgresUserStorage) ListUsers(ctx context.Context, q UserQuery) (*UserPage, error) {
+	q = q.withDefaults()
+	if err := q.Validate(); err != nil {
+		return nil, fmt.Errorf("storage.ListUsers: %w", err)
+	}
+	sortColumn := userSortColumns[q.SortField]
+
+	conditions := []string{"deleted_at IS NULL"}
+	if q.Trashed {
+		conditions[0] = "deleted_at IS NOT NULL"
+	}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q.EmailDomain != "" {
+		conditions = append(conditions, "lower(split_part(email, '@', 2)) = lower("+arg(q.EmailDomain)+")")
+	}
+	if q.NamePrefix != "" {
+		conditions = append(conditions, "name ILIKE "+arg(escapeLike(q.NamePrefix)+"%"))
+	}
+	if q.After != nil {
+		op := ">"
+		if q.SortDesc {
+			op = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s::%s, %s::bigint)",
+			sortColumn.column, op, arg(q.After.Value), sortColumn.sqlType, arg(q.After.ID)))
+	}
+
+	where := "WHERE " + strings.Join(conditions, " AND ")
+	direction := "ASC"
+	if q.SortDesc {
+		direction = "DESC"
+	}
+	// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	query := fmt.Sprintf("SELECT %s FROM users %s ORDER BY %s %s, id %s LIMIT %s",
+		userColumns, where, sortColumn.column, direction, direction, arg(q.Limit+1))
+
+	users := make([]models.User, 0, q.Limit)
+	err := traceQuery(ctx, "users.list", query, func(ctx context.Context) error {
+		rows, err := s.DB.QueryContext(ctx, query, args...)
+		if err != nil {
+			return classifyPostgresError(ctx, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var u models.User
+			if err := scanUser(rows, &u); err != nil {
+				return fmt.Errorf("ошибка сканирования строки: %w", classifyPostgresError(ctx, err))
+			}
+			users = append(users, u)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("ошибка после итерации: %w", classifyPostgresError(ctx, err))
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("storage.GetAllUsers: %w", err)
+		return nil, fmt.Errorf("storage.ListUsers: %w", err)
 	}
-	defer rows.Close()
+	return newUserPage(users, q), nil
+}
 
-	var users []models.User
-	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
-			return nil, fmt.Errorf("storage.GetAllUsers: ошибка сканирования строки: %w", err)
+// SearchUsers ищет пользователей по имени и email: полнотекстово по префиксам слов
+// и нечетко по триграммам (сходство запроса с частью имени), в том числе по
+// транслитерации имени. Результаты с
+// полнотекстовым совпадением идут первыми, внутри групп — по убыванию сходства.
+func (s *PostgresUserStorage) SearchUsers(ctx context.Context, q string, limit int) ([]models.UserSearchResult, error) {
+	words := SearchWords(q)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("storage.SearchUsers: %w", &ValidationError{Field: "q", Message: "запрос должен содержать буквы или цифры"})
+	}
+	if limit <= 0 || limit > MaxSearchLimit {
+		limit = DefaultSearchLimit
+	}
+
+	query := `
+    WITH params AS (
+        SELECT lower($1::text) AS q, translit_ru($1::text) AS q_latin, to_tsquery('simple', $2) AS tsq
+    )
+    SELECT u.id, u.name, u.email, u.created_at, u.version,
+        (u.search_vector @@ p.tsq)::int + greatest(
+            word_similarity(p.q, lower(u.name)),
+            word_similarity(p.q_latin, u.name_latin),
+            similarity(lower(u.email), p.q)
+        ) AS rank
+    FROM users u, params p
+    WHERE u.deleted_at IS NULL AND (
+        u.search_vector @@ p.tsq
+        OR p.q <% lower(u.name)
+        OR p.q_latin <% u.name_latin
+        OR lower(u.email) % p.q
+    )
+    ORDER BY rank DESC, u.id ASC
+    LIMIT $3`
+
+	results := []models.UserSearchResult{}
+	err := traceQuery(ctx, "users.search", query, func(ctx context.Context) error {
+		rows, err := s.DB.QueryContext(ctx, query, q, prefixTSQuery(words), limit)
+		if err != nil {
+			return classifyPostgresError(ctx, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var r models.UserSearchResult
+			err := rows.Scan(&r.User.ID, &r.User.Name, &r.User.Email, &r.User.CreatedAt, &r.User.Version, &r.Rank)
+			if err != nil {
+				return fmt.Errorf("ошибка сканирования строки: %w", classifyPostgresError(ctx, err))
+			}
+			// Подсветка строится в Go, а не ts_headline: текст полей нужно экранировать
+			r.Highlights = userHighlights(&r.User, words)
+			results = append(results, r)
 		}
-		users = append(users, u)
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("ошибка после итерации: %w", classifyPostgresError(ctx, err))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage.SearchUsers: %w", err)
 	}
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("storage.GetAllUsers: ошибка после итерации: %w", err)
+	return results, nil
+}
+
+// UpdateUser безусловно обновляет данные пользователя и увеличивает его версию
+func (s *PostgresUserStorage) UpdateUser(ctx context.Context, user *models.User) error {
+	return s.update(ctx, "storage.UpdateUser", user, nil)
+}
+
+// CompareAndSwapUser обновляет пользователя, только если его текущая версия равна
+// expectedVersion. Иначе возвращает ErrVersionMismatch, ничего не меняя.
+func (s *PostgresUserStorage) CompareAndSwapUser(ctx context.Context, user *models.User, expectedVersion int64) error {
+	return s.update(ctx, "storage.CompareAndSwapUser", user, &expectedVersion)
+}
+
+// update — общая часть UpdateUser и CompareAndSwapUser; expectedVersion == nil отключает проверку версии
+func (s *PostgresUserStorage) update(ctx context.Context, op string, user *models.User, expectedVersion *int64) error {
+	updated := *user
+	err := s.inTx(ctx, op, func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		before, err := lockUser(ctx, tx, user.ID, activeUserCondition)
+		if err != nil {
+			return nil, err
+		}
+		if expectedVersion != nil && before.Version != *expectedVersion {
+			return nil, fmt.Errorf("пользователь с ID %d, ожидалась версия %d, текущая %d: %w",
+				user.ID, *expectedVersion, before.Version, ErrVersionMismatch)
+		}
+		query := "UPDATE users SET name = $1, email = $2, version = version + 1 WHERE id = $3 RETURNING " + userColumns
+		err = traceQuery(ctx, "users.update", query, func(ctx context.Context) error {
+			return scanUser(tx.QueryRowContext(ctx, query, user.Name, user.Email, user.ID), &updated)
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		return []models.AuditEntry{newAuditEntry(ctx, models.AuditActionUpdate, before, &updated)}, nil
+	})
+	if err != nil {
+		return err
+	}
+	*user = updated
+	return nil
+}
+
+// PatchUser атомарно изменяет пользователя: строка блокируется SELECT ... FOR UPDATE
+// до конца транзакции, поэтому параллельные изменения не теряются
+func (s *PostgresUserStorage) PatchUser(ctx context.Context, id int64, mutate func(user *models.User) error) (*models.User, error) {
+	user := &models.User{}
+	err := s.inTx(ctx, "storage.PatchUser", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		before, err := lockUser(ctx, tx, id, activeUserCondition)
+		if err != nil {
+			return nil, err
+		}
+		*user = *before
+		if err := mutate(user); err != nil {
+			return nil, err
+		}
+
+		query := "UPDATE users SET name = $1, email = $2, version = version + 1 WHERE id = $3 RETURNING " + userColumns
+		err = traceQuery(ctx, "users.update", query, func(ctx context.Context) error {
+			return scanUser(tx.QueryRowContext(ctx, query, user.Name, user.Email, id), user)
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		return []models.AuditEntry{newAuditEntry(ctx, models.AuditActionUpdate, before, user)}, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return users, nil
+	return user, nil
+}
+
+// DeleteUser переносит пользователя в корзину: строка остается в таблице с отметкой deleted_at
+// и исключается из обычных выборок до восстановления или окончательного удаления
+func (s *PostgresUserStorage) DeleteUser(ctx context.Context, id int64) error {
+	return s.inTx(ctx, "storage.DeleteUser", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		before, err := lockUser(ctx, tx, id, activeUserCondition)
+		if err != nil {
+			return nil, err
+		}
+		deleted := &models.User{}
+		query := "UPDATE users SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 RETURNING " + userColumns
+		err = traceQuery(ctx, "users.soft_delete", query, func(ctx context.Context) error {
+			return scanUser(tx.QueryRowContext(ctx, query, id), deleted)
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		return []models.AuditEntry{newAuditEntry(ctx, models.AuditActionDelete, before, deleted)}, nil
+	})
+}
+
+// RestoreUser возвращает пользователя из корзины
+func (s *PostgresUserStorage) RestoreUser(ctx context.Context, id int64) (*models.User, error) {
+	restored := &models.User{}
+	err := s.inTx(ctx, "storage.RestoreUser", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		before, err := lockUser(ctx, tx, id, trashedUserCondition)
+		if err != nil {
+			return nil, err
+		}
+		// Пока пользователь был в корзине, его email мог занять другой пользователь:
+		// тогда UPDATE нарушит users_email_active_key и вернется ConflictError
+		query := "UPDATE users SET deleted_at = NULL, version = version + 1 WHERE id = $1 RETURNING " + userColumns
+		err = traceQuery(ctx, "users.restore", query, func(ctx context.Context) error {
+			return scanUser(tx.QueryRowContext(ctx, query, id), restored)
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		return []models.AuditEntry{newAuditEntry(ctx, models.AuditActionRestore, before, restored)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PurgeUser окончательно удаляет пользователя, в том числе находящегося в корзине
+func (s *PostgresUserStorage) PurgeUser(ctx context.Context, id int64) error {
+	return s.inTx(ctx, "storage.PurgeUser", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		before, err := lockUser(ctx, tx, id, anyUserCondition)
+		if err != nil {
+			return nil, err
+		}
+		query := "DELETE FROM users WHERE id = $1"
+		err = traceQuery(ctx, "users.delete", query, func(ctx context.Context) error {
+			_, err := tx.ExecContext(ctx, query, id)
+			return err
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		return []models.AuditEntry{newAuditEntry(ctx, models.AuditActionPurge, before, nil)}, nil
+	})
 }
 
-// UpdateUser обновляет данные пользователя
-func (s *PostgresUserStorage) UpdateUser(user *models.User) error {
-	query := "UPDATE users SET name = $1, email = $2 WHERE id = $3"
-	result, err := s.DB.Exec(query, user.Name, user.Email, user.ID)
+// PurgeDeletedBefore окончательно удаляет пользователей, перенесенных в корзину раньше cutoff
+func (s *PostgresUserStorage) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := s.inTx(ctx, "storage.PurgeDeletedBefore", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		query := "DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1 RETURNING " + userColumns
+		var entries []models.AuditEntry
+		err := traceQuery(ctx, "users.purge_trash", query, func(queryCtx context.Context) error {
+			rows, err := tx.QueryContext(queryCtx, query, cutoff)
+			if err != nil {
+				return classifyPostgresError(queryCtx, err)
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				before := &models.User{}
+				if err := scanUser(rows, before); err != nil {
+					return fmt.Errorf("ошибка сканирования строки: %w", classifyPostgresError(queryCtx, err))
+				}
+				entries = append(entries, newAuditEntry(ctx, models.AuditActionPurge, before, nil))
+			}
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("ошибка после итерации: %w", classifyPostgresError(queryCtx, err))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		purged = int64(len(entries))
+		return entries, nil
+	})
 	if err != nil {
-		return fmt.Errorf("storage.UpdateUser: %w", err)
+		return 0, err
 	}
-	rowsAffected, err := result.RowsAffected()
+	return purged, nil
+}
+
+// userVersionColumns — колонки user_versions в порядке, ожидаемом scanUserVersion
+const userVersionColumns = "user_id, version, name, email, created_at, deleted_at, valid_from"
+
+// scanUserVersion читает строку, выбранную по userVersionColumns
+func scanUserVersion(row rowScanner, v *models.UserVersion) error {
+	var deletedAt sql.NullTime
+	err := row.Scan(&v.ID, &v.Version, &v.Name, &v.Email, &v.CreatedAt, &deletedAt, &v.ValidFrom)
 	if err != nil {
-		return fmt.Errorf("storage.UpdateUser: не удалось получить количество измененных строк: %w", err)
+		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("storage.UpdateUser: пользователь с ID %d не найден для обновления", user.ID)
+	v.DeletedAt = nil
+	if deletedAt.Valid {
+		v.DeletedAt = &deletedAt.Time
 	}
 	return nil
 }
 
-// DeleteUser удаляет пользователя по ID
-func (s *PostgresUserStorage) DeleteUser(id int64) error {
-	query := "DELETE FROM users WHERE id = $1"
-	result, err := s.DB.Exec(query, id)
+// GetUserAsOf находит версию, действовавшую в момент at (таблица user_versions)
+func (s *PostgresUserStorage) GetUserAsOf(ctx context.Context, id int64, at time.Time) (*models.User, error) {
+	query := "SELECT " + userVersionColumns + ` FROM user_versions
+    WHERE user_id = $1 AND valid_from <= $2 ORDER BY version DESC LIMIT 1`
+	v := &models.UserVersion{}
+	err := traceQuery(ctx, "user_versions.select_as_of", query, func(ctx context.Context) error {
+		return scanUserVersion(s.DB.QueryRowContext(ctx, query, id, at), v)
+	})
+	if err == sql.ErrNoRows || (err == nil && v.DeletedAt != nil) {
+		return nil, fmt.Errorf("storage.GetUserAsOf: пользователь с ID %d на момент %s: %w", id, at.Format(time.RFC3339), ErrNotFound)
+	}
 	if err != nil {
-		return fmt.Errorf("storage.DeleteUser: %w", err)
+		return nil, fmt.Errorf("storage.GetUserAsOf: %w", classifyPostgresError(ctx, err))
 	}
-	rowsAffected, err := result.RowsAffected()
+	return &v.User, nil
+}
+
+// ListUserVersions возвращает все сохраненные версии пользователя
+func (s *PostgresUserStorage) ListUserVersions(ctx context.Context, id int64) ([]models.UserVersion, error) {
+	query := "SELECT " + userVersionColumns + " FROM user_versions WHERE user_id = $1 ORDER BY version"
+	versions := []models.UserVersion{}
+	err := traceQuery(ctx, "user_versions.list", query, func(ctx context.Context) error {
+		rows, err := s.DB.QueryContext(ctx, query, id)
+		if err != nil {
+			return classifyPostgresError(ctx, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var v models.UserVersion
+			if err := scanUserVersion(rows, &v); err != nil {
+				return fmt.Errorf("ошибка сканирования строки: %w", classifyPostgresError(ctx, err))
+			}
+			versions = append(versions, v)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("ошибка после итерации: %w", classifyPostgresError(ctx, err))
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("storage.DeleteUser: не удалось получить количество удаленных строк: %w", err)
+		return nil, fmt.Errorf("storage.ListUserVersions: %w", err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("storage.DeleteUser: пользователь с ID %d не найден для удаления", id)
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("storage.ListUserVersions: пользователь с ID %d: %w", id, ErrNotFound)
 	}
-	return nil
+	return versions, nil
+}
+
+// GetUserVersion возвращает версию version пользователя
+func (s *PostgresUserStorage) GetUserVersion(ctx context.Context, id, version int64) (*models.UserVersion, error) {
+	query := "SELECT " + userVersionColumns + " FROM user_versions WHERE user_id = $1 AND version = $2"
+	v := &models.UserVersion{}
+	err := traceQuery(ctx, "user_versions.select", query, func(ctx context.Context) error {
+		return scanUserVersion(s.DB.QueryRowContext(ctx, query, id, version), v)
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("storage.GetUserVersion: версия %d пользователя с ID %d: %w", version, id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage.GetUserVersion: %w", classifyPostgresError(ctx, err))
+	}
+	return v, nil
+}
+
+// RevertUser записывает имя и email из версии version как новую версию пользователя.
+// Сама история не переписывается: откат виден в ней и в журнале аудита как отдельное изменение.
+func (s *PostgresUserStorage) RevertUser(ctx context.Context, id, version int64) (*models.User, error) {
+	reverted := &models.User{}
+	err := s.inTx(ctx, "storage.RevertUser", func(tx *sql.Tx) ([]models.AuditEntry, error) {
+		before, err := lockUser(ctx, tx, id, activeUserCondition)
+		if err != nil {
+			return nil, err
+		}
+		var name, email string
+		versionQuery := "SELECT name, email FROM user_versions WHERE user_id = $1 AND version = $2"
+		err = traceQuery(ctx, "user_versions.select_fields", versionQuery, func(ctx context.Context) error {
+			return tx.QueryRowContext(ctx, versionQuery, id, version).Scan(&name, &email)
+		})
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("версия %d пользователя с ID %d: %w", version, id, ErrNotFound)
+		}
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+
+		query := "UPDATE users SET name = $1, email = $2, version = version + 1 WHERE id = $3 RETURNING " + userColumns
+		err = traceQuery(ctx, "users.update", query, func(ctx context.Context) error {
+			return scanUser(tx.QueryRowContext(ctx, query, name, email, id), reverted)
+		})
+		if err != nil {
+			return nil, classifyPostgresError(ctx, err)
+		}
+		return []models.AuditEntry{newAuditEntry(ctx, models.AuditActionRevert, before, reverted)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
 }
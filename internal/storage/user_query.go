@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+)
+
+const (
+	// DefaultUserLimit — размер страницы, если limit не указан
+	DefaultUserLimit = 50
+	// MaxUserLimit — максимальный размер страницы
+	MaxUserLimit = 200
+)
+
+// UserSortField — поле, по которому сортируется список пользователей
+type UserSortField string
+
+const (
+	SortByID        UserSortField = "id"
+	SortByName      UserSortField = "name"
+	SortByEmail     UserSortField = "email"
+	SortByCreatedAt UserSortField = "created_at"
+)
+
+// userSortColumns сопоставляет поля сортировки с колонками и типами таблицы users
+var userSortColumns = map[UserSortField]struct{ column, sqlType string }{
+	SortByID:        {"id", "bigint"},
+	SortByName:      {"name", "text"},
+	SortByEmail:     {"email", "text"},
+	SortByCreatedAt: {"created_at", "timestamptz"},
+}
+
+// ParseUserSort разбирает параметр сортировки вида "name" или "-email"
+func ParseUserSort(s string) (field UserSortField, desc bool, err error) {
+	if s == "" {
+		return SortByID, false, nil
+	}
+	if strings.HasPrefix(s, "-") {
+		desc = true
+		s = s[1:]
+	}
+	field = UserSortField(s)
+	if _, ok := userSortColumns[field]; !ok {
+		return "", false, fmt.Errorf("неизвестное поле сортировки '%s'", s)
+	}
+	return field, desc, nil
+}
+
+// UserQuery описывает выборку списка пользователей: фильтры, сортировку и страницу.
+// Одинаково понимается PostgresUserStorage и MockUserStorage.
+type UserQuery struct {
+	Limit       int
+	SortField   UserSortField
+	SortDesc    bool
+	EmailDomain string
+	NamePrefix  string
+	// After — курсор последней записи предыдущей страницы; nil для первой страницы
+	After *UserCursor
+	// Trashed выбирает пользователей из корзины вместо активных
+	Trashed bool
+}
+
+// withDefaults подставляет значения по умолчанию для незаполненных полей
+func (q UserQuery) withDefaults() UserQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultUserLimit
+	}
+	if q.Limit > MaxUserLimit {
+		q.Limit = MaxUserLimit
+	}
+	if q.SortField == "" {
+		q.SortField = SortByID
+	}
+	return q
+}
+
+// Validate проверяет согласованность запроса
+func (q UserQuery) Validate() error {
+	if _, ok := userSortColumns[q.SortField]; !ok {
+		return &ValidationError{Field: "sort", Message: fmt.Sprintf("неизвестное поле сортировки '%s'", q.SortField)}
+	}
+	if q.After != nil && (q.After.SortField != q.SortField || q.After.SortDesc != q.SortDesc) {
+		return &ValidationError{Field: "cursor", Message: "курсор получен для другой сортировки"}
+	}
+	return nil
+}
+
+// UserPage — одна страница списка пользователей
+type UserPage struct {
+	Users []models.User
+	// NextCursor указывает на последнюю запись страницы; nil, если страница последняя
+	NextCursor *UserCursor
+}
+
+// UserCursor — позиция в списке пользователей для keyset-пагинации.
+// Клиенту передается только в закодированном виде (см. Encode).
+type UserCursor struct {
+	SortField UserSortField `json:"f"`
+	SortDesc  bool          `json:"d,omitempty"`
+	Value     string        `json:"v"`
+	ID        int64         `json:"id"`
+}
+
+// Encode кодирует курсор в непрозрачную строку
+func (c UserCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeUserCursor разбирает строку, полученную из Encode
+func DecodeUserCursor(s string) (*UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, &ValidationError{Field: "cursor", Message: "некорректный курсор", Err: err}
+	}
+	var c UserCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, &ValidationError{Field: "cursor", Message: "некорректный курсор", Err: err}
+	}
+	if _, ok := userSortColumns[c.SortField]; !ok || c.ID <= 0 {
+		return nil, &ValidationError{Field: "cursor", Message: "некорректный курсор"}
+	}
+	return &c, nil
+}
+
+// newUserPage формирует страницу из выборки, содержащей до q.Limit+1 записей
+func newUserPage(users []models.User, q UserQuery) *UserPage {
+	page := &UserPage{Users: users}
+	if len(users) > q.Limit {
+		page.Users = users[:q.Limit]
+		page.NextCursor = cursorFor(page.Users[q.Limit-1], q.SortField, q.SortDesc)
+	}
+	return page
+}
+
+// cursorFor строит курсор, указывающий на пользователя u при заданной сортировке
+func cursorFor(u models.User, field UserSortField, desc bool) *UserCursor {
+	return &UserCursor{SortField: field, SortDesc: desc, Value: sortValue(u, field), ID: u.ID}
+}
+
+// sortValue возвращает значение поля сортировки в виде строки для курсора
+func sortValue(u models.User, field UserSortField) string {
+	switch field {
+	case SortByName:
+		return u.Name
+	case SortByEmail:
+		return u.Email
+	case SortByCreatedAt:
+		return u.CreatedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return strconv.FormatInt(u.ID, 10)
+	}
+}
+
+// cursorUser восстанавливает из курсора пользователя с заполненным полем сортировки и ID
+func cursorUser(c *UserCursor) (models.User, error) {
+	u := models.User{ID: c.ID}
+	switch c.SortField {
+	case SortByName:
+		u.Name = c.Value
+	case SortByEmail:
+		u.Email = c.Value
+	case SortByCreatedAt:
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return u, &ValidationError{Field: "cursor", Message: "некорректный курсор", Err: err}
+		}
+		u.CreatedAt = t
+	}
+	return u, nil
+}
+
+// compareUsers сравнивает пользователей по одному полю сортировки
+func compareUsers(a, b models.User, field UserSortField) int {
+	switch field {
+	case SortByName:
+		return strings.Compare(a.Name, b.Name)
+	case SortByEmail:
+		return strings.Compare(a.Email, b.Email)
+	case SortByCreatedAt:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	default:
+		return cmp.Compare(a.ID, b.ID)
+	}
+}
+
+// emailDomain возвращает домен email в нижнем регистре
+func emailDomain(email string) string {
+	_, domain, _ := strings.Cut(email, "@")
+	return strings.ToLower(domain)
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
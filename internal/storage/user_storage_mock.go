@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/casanera/GiperboreyaTechnologies/internal/models"
 )
@@ -13,111 +17,540 @@ type MockUserStorage struct {
 	Users         map[int64]*models.User
 	NextID        int64
 	SimulateError error
+	// SimulateDelay задерживает каждую операцию, позволяя проверять дедлайны и отмену
+	SimulateDelay time.Duration
+	// Audit получает запись о каждом изменении до его применения; nil отключает аудит
+	Audit AuditStorage
+	// Versions повторяет таблицу user_versions: снимки всех версий каждого пользователя
+	Versions map[int64][]models.UserVersion
 }
 
-// NewMockUserStorage создает новый экземпляр MockUserStorage.
+// NewMockUserStorage создает новый экземпляр MockUserStorage с MockAuditStorage в качестве журнала.
 func NewMockUserStorage() *MockUserStorage {
 	return &MockUserStorage{
-		Users:  make(map[int64]*models.User),
-		NextID: 1,
+		Users:    make(map[int64]*models.User),
+		NextID:   1,
+		Audit:    NewMockAuditStorage(),
+		Versions: make(map[int64][]models.UserVersion),
 	}
 }
 
-func (m *MockUserStorage) CreateUser(user *models.User) (int64, error) {
+// store сохраняет пользователя и, как триггер users_record_version, снимок его версии.
+// Вызывается под m.mu
+func (m *MockUserStorage) store(user *models.User) {
+	m.Users[user.ID] = user
+	m.Versions[user.ID] = append(m.Versions[user.ID], models.UserVersion{User: *user, ValidFrom: time.Now()})
+}
+
+// audit записывает изменения в журнал. Как и транзакция в PostgresUserStorage,
+// изменение применяется только после успешной записи. Вызывается под m.mu
+func (m *MockUserStorage) audit(ctx context.Context, op string, entries ...models.AuditEntry) error {
+	if m.Audit == nil {
+		return nil
+	}
+	for i := range entries {
+		if err := m.Audit.AppendAuditEntry(ctx, &entries[i]); err != nil {
+			return fmt.Errorf("%s: запись в журнал аудита: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// wait имитирует время выполнения запроса и, как и драйвер БД, прерывается при отмене ctx
+func (m *MockUserStorage) wait(ctx context.Context) error {
+	m.mu.Lock()
+	delay := m.SimulateDelay
+	m.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+	return ctx.Err()
+}
+
+// activeUser возвращает пользователя, не находящегося в корзине. Вызывается под m.mu
+func (m *MockUserStorage) activeUser(id int64) (*models.User, bool) {
+	user, exists := m.Users[id]
+	if !exists || user.DeletedAt != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+// emailTaken повторяет частичный уникальный индекс users_email_active_key:
+// email уникален только среди пользователей вне корзины. Вызывается под m.mu
+func (m *MockUserStorage) emailTaken(email string, exceptID int64) bool {
+	for id, user := range m.Users {
+		if id != exceptID && user.DeletedAt == nil && user.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockUserStorage) CreateUser(ctx context.Context, user *models.User) (int64, error) {
+	if err := m.wait(ctx); err != nil {
+		return 0, fmt.Errorf("storage.CreateUser: %w", err)
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if m.SimulateError != nil {
 		return 0, m.SimulateError
 	}
-	for _, existingUser := range m.Users {
-		if existingUser.Email == user.Email {
-			return 0, fmt.Errorf("мок: email '%s' уже существует", user.Email)
-		}
+	if m.emailTaken(user.Email, 0) {
+		return 0, fmt.Errorf("мок: email '%s': %w", user.Email, &ConflictError{Field: "email"})
 	}
 
 	newID := m.NextID
 	m.NextID++
-	user.ID = newID // Присваиваем ID мок-объекту
 	userCopy := *user
-	m.Users[newID] = &userCopy
+	userCopy.ID = newID
+	userCopy.CreatedAt = time.Now()
+	userCopy.Version = 1
+	userCopy.DeletedAt = nil
+	if err := m.audit(ctx, "storage.CreateUser", newAuditEntry(ctx, models.AuditActionCreate, nil, &userCopy)); err != nil {
+		return 0, err
+	}
+	*user = userCopy // Присваиваем ID мок-объекту
+	m.store(&userCopy)
 	return newID, nil
 }
 
-func (m *MockUserStorage) GetUserByID(id int64) (*models.User, error) {
+func (m *MockUserStorage) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.GetUserByID: %w", err)
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if m.SimulateError != nil {
 		return nil, m.SimulateError
 	}
-	user, exists := m.Users[id]
+	user, exists := m.activeUser(id)
 	if !exists {
-		return nil, fmt.Errorf("storage.GetUserByID: пользователь с ID %d не найден", id) // Совпадает с ошибкой в PostgresUserStorage
+		return nil, fmt.Errorf("storage.GetUserByID: пользователь с ID %d: %w", id, ErrNotFound) // Совпадает с ошибкой в PostgresUserStorage
 	}
 	userCopy := *user // Возвращаем копию
 	return &userCopy, nil
 }
 
-func (m *MockUserStorage) GetAllUsers() ([]models.User, error) {
+// ListUsers фильтрует, сортирует и разбивает на страницы пользователей так же, как PostgresUserStorage
+func (m *MockUserStorage) ListUsers(ctx context.Context, q UserQuery) (*UserPage, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.ListUsers: %w", err)
+	}
+	q = q.withDefaults()
+	if err := q.Validate(); err != nil {
+		return nil, fmt.Errorf("storage.ListUsers: %w", err)
+	}
+	var after *models.User
+	if q.After != nil {
+		u, err := cursorUser(q.After)
+		if err != nil {
+			return nil, fmt.Errorf("storage.ListUsers: %w", err)
+		}
+		after = &u
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if m.SimulateError != nil {
 		return nil, m.SimulateError
 	}
-	var usersList []models.User
+	// less сравнивает пользователей в порядке выдачи: по полю сортировки, затем по ID
+	less := func(a, b models.User) bool {
+		c := compareUsers(a, b, q.SortField)
+		if c == 0 {
+			c = compareUsers(a, b, SortByID)
+		}
+		if q.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	}
+
+	usersList := []models.User{}
 	for _, user := range m.Users {
-		userCopy := *user
-		usersList = append(usersList, userCopy)
+		if (user.DeletedAt != nil) != q.Trashed {
+			continue
+		}
+		if q.EmailDomain != "" && emailDomain(user.Email) != strings.ToLower(q.EmailDomain) {
+			continue
+		}
+		if q.NamePrefix != "" && !strings.HasPrefix(strings.ToLower(user.Name), strings.ToLower(q.NamePrefix)) {
+			continue
+		}
+		if after != nil && !less(*after, *user) {
+			continue
+		}
+		usersList = append(usersList, *user)
+	}
+	sort.Slice(usersList, func(i, j int) bool { return less(usersList[i], usersList[j]) })
+	if len(usersList) > q.Limit+1 {
+		usersList = usersList[:q.Limit+1]
 	}
-	return usersList, nil
+	return newUserPage(usersList, q), nil
 }
 
-func (m *MockUserStorage) UpdateUser(user *models.User) error {
+// SearchUsers приближенно повторяет поиск PostgresUserStorage: префиксное совпадение
+// слов и триграммное сходство, в том числе по транслитерации имени
+func (m *MockUserStorage) SearchUsers(ctx context.Context, q string, limit int) ([]models.UserSearchResult, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.SearchUsers: %w", err)
+	}
+	words := SearchWords(q)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("storage.SearchUsers: %w", &ValidationError{Field: "q", Message: "запрос должен содержать буквы или цифры"})
+	}
+	if limit <= 0 || limit > MaxSearchLimit {
+		limit = DefaultSearchLimit
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if m.SimulateError != nil {
-		return m.SimulateError
+		return nil, m.SimulateError
 	}
-	// Проверка на существующий email (кроме текущего пользователя)
-	for id, existingUser := range m.Users {
-		if id != user.ID && existingUser.Email == user.Email {
-			return fmt.Errorf("мок: email '%s' уже используется другим пользователем", user.Email)
+	results := []models.UserSearchResult{}
+	for _, user := range m.Users {
+		if user.DeletedAt != nil {
+			continue
+		}
+		nameLatin := Transliterate(user.Name)
+		// Как в search_vector: слова имени, слова транслитерации и email целиком
+		tokens := append(SearchWords(user.Name), SearchWords(nameLatin)...)
+		tokens = append(tokens, strings.ToLower(user.Email))
+		fullTextMatch := true
+		for _, w := range words {
+			matched := false
+			for _, token := range tokens {
+				if strings.HasPrefix(token, w) {
+					matched = true
+					break
+				}
+			}
+			fullTextMatch = fullTextMatch && matched
+		}
+
+		nameSimilarity := max(wordSimilarity(q, user.Name), wordSimilarity(Transliterate(q), nameLatin))
+		emailSimilarity := trigramSimilarity(user.Email, q)
+		if !fullTextMatch && nameSimilarity < wordTrigramThreshold && emailSimilarity < trigramThreshold {
+			continue
+		}
+		similarity := max(nameSimilarity, emailSimilarity)
+
+		r := models.UserSearchResult{User: *user, Rank: similarity}
+		if fullTextMatch {
+			r.Rank++
+		}
+		r.Highlights = userHighlights(user, words)
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
 		}
+		return results[i].User.ID < results[j].User.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
 	}
-	_, exists := m.Users[user.ID]
+	return results, nil
+}
+
+func (m *MockUserStorage) UpdateUser(ctx context.Context, user *models.User) error {
+	return m.update(ctx, "storage.UpdateUser", user, nil)
+}
+
+func (m *MockUserStorage) CompareAndSwapUser(ctx context.Context, user *models.User, expectedVersion int64) error {
+	return m.update(ctx, "storage.CompareAndSwapUser", user, &expectedVersion)
+}
+
+// update — общая часть UpdateUser и CompareAndSwapUser; expectedVersion == nil означает безусловное обновление
+func (m *MockUserStorage) update(ctx context.Context, op string, user *models.User, expectedVersion *int64) error {
+	if err := m.wait(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return m.SimulateError
+	}
+	// Как и в PostgreSQL, UPDATE несуществующей строки не доходит до проверки уникальности
+	existing, exists := m.activeUser(user.ID)
 	if !exists {
-		return fmt.Errorf("storage.UpdateUser: пользователь с ID %d не найден для обновления", user.ID)
+		return fmt.Errorf("%s: пользователь с ID %d: %w", op, user.ID, ErrNotFound)
+	}
+	if expectedVersion != nil && existing.Version != *expectedVersion {
+		return fmt.Errorf("%s: пользователь с ID %d, ожидалась версия %d, текущая %d: %w",
+			op, user.ID, *expectedVersion, existing.Version, ErrVersionMismatch)
+	}
+	// Проверка на существующий email (кроме текущего пользователя)
+	if m.emailTaken(user.Email, user.ID) {
+		return fmt.Errorf("мок: email '%s': %w", user.Email, &ConflictError{Field: "email"})
 	}
 	userCopy := *user
-	m.Users[user.ID] = &userCopy
+	userCopy.CreatedAt = existing.CreatedAt
+	userCopy.Version = existing.Version + 1
+	userCopy.DeletedAt = nil
+	if err := m.audit(ctx, op, newAuditEntry(ctx, models.AuditActionUpdate, existing, &userCopy)); err != nil {
+		return err
+	}
+	*user = userCopy
+	m.store(&userCopy)
+	return nil
+}
+
+func (m *MockUserStorage) PatchUser(ctx context.Context, id int64, mutate func(user *models.User) error) (*models.User, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.PatchUser: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return nil, m.SimulateError
+	}
+	existing, exists := m.activeUser(id)
+	if !exists {
+		return nil, fmt.Errorf("storage.PatchUser: пользователь с ID %d: %w", id, ErrNotFound)
+	}
+	user := *existing
+	if err := mutate(&user); err != nil {
+		return nil, fmt.Errorf("storage.PatchUser: %w", err)
+	}
+	user.ID = id
+	user.CreatedAt = existing.CreatedAt
+	user.Version = existing.Version + 1
+	if m.emailTaken(user.Email, id) {
+		return nil, fmt.Errorf("мок: email '%s': %w", user.Email, &ConflictError{Field: "email"})
+	}
+	if err := m.audit(ctx, "storage.PatchUser", newAuditEntry(ctx, models.AuditActionUpdate, existing, &user)); err != nil {
+		return nil, err
+	}
+	m.store(&user)
+	userCopy := user
+	return &userCopy, nil
+}
+
+func (m *MockUserStorage) DeleteUser(ctx context.Context, id int64) error {
+	if err := m.wait(ctx); err != nil {
+		return fmt.Errorf("storage.DeleteUser: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return m.SimulateError
+	}
+	user, exists := m.activeUser(id)
+	if !exists {
+		return fmt.Errorf("storage.DeleteUser: пользователь с ID %d: %w", id, ErrNotFound)
+	}
+	deleted := *user
+	deletedAt := time.Now()
+	deleted.DeletedAt = &deletedAt
+	deleted.Version++
+	if err := m.audit(ctx, "storage.DeleteUser", newAuditEntry(ctx, models.AuditActionDelete, user, &deleted)); err != nil {
+		return err
+	}
+	m.store(&deleted)
 	return nil
 }
 
-func (m *MockUserStorage) DeleteUser(id int64) error {
+func (m *MockUserStorage) RestoreUser(ctx context.Context, id int64) (*models.User, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.RestoreUser: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return nil, m.SimulateError
+	}
+	user, exists := m.Users[id]
+	if !exists || user.DeletedAt == nil {
+		return nil, fmt.Errorf("storage.RestoreUser: пользователь с ID %d в корзине: %w", id, ErrNotFound)
+	}
+	if m.emailTaken(user.Email, id) {
+		return nil, fmt.Errorf("мок: email '%s': %w", user.Email, &ConflictError{Field: "email"})
+	}
+	restored := *user
+	restored.DeletedAt = nil
+	restored.Version++
+	if err := m.audit(ctx, "storage.RestoreUser", newAuditEntry(ctx, models.AuditActionRestore, user, &restored)); err != nil {
+		return nil, err
+	}
+	m.store(&restored)
+	userCopy := restored
+	return &userCopy, nil
+}
+
+func (m *MockUserStorage) PurgeUser(ctx context.Context, id int64) error {
+	if err := m.wait(ctx); err != nil {
+		return fmt.Errorf("storage.PurgeUser: %w", err)
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if m.SimulateError != nil {
 		return m.SimulateError
 	}
-	_, exists := m.Users[id]
+	user, exists := m.Users[id]
 	if !exists {
-		return fmt.Errorf("storage.DeleteUser: пользователь с ID %d не найден для удаления", id)
+		return fmt.Errorf("storage.PurgeUser: пользователь с ID %d: %w", id, ErrNotFound)
+	}
+	if err := m.audit(ctx, "storage.PurgeUser", newAuditEntry(ctx, models.AuditActionPurge, user, nil)); err != nil {
+		return err
 	}
 	delete(m.Users, id)
 	return nil
 }
 
+func (m *MockUserStorage) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	if err := m.wait(ctx); err != nil {
+		return 0, fmt.Errorf("storage.PurgeDeletedBefore: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return 0, m.SimulateError
+	}
+	var entries []models.AuditEntry
+	for _, user := range m.Users {
+		if user.DeletedAt != nil && user.DeletedAt.Before(cutoff) {
+			entries = append(entries, newAuditEntry(ctx, models.AuditActionPurge, user, nil))
+		}
+	}
+	if err := m.audit(ctx, "storage.PurgeDeletedBefore", entries...); err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		delete(m.Users, entry.UserID)
+	}
+	return int64(len(entries)), nil
+}
+
+func (m *MockUserStorage) GetUserAsOf(ctx context.Context, id int64, at time.Time) (*models.User, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.GetUserAsOf: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return nil, m.SimulateError
+	}
+	var found *models.UserVersion
+	for i, v := range m.Versions[id] {
+		if !v.ValidFrom.After(at) {
+			found = &m.Versions[id][i]
+		}
+	}
+	if found == nil || found.DeletedAt != nil {
+		return nil, fmt.Errorf("storage.GetUserAsOf: пользователь с ID %d на момент %s: %w", id, at.Format(time.RFC3339), ErrNotFound)
+	}
+	user := found.User
+	return &user, nil
+}
+
+func (m *MockUserStorage) ListUserVersions(ctx context.Context, id int64) ([]models.UserVersion, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.ListUserVersions: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return nil, m.SimulateError
+	}
+	if len(m.Versions[id]) == 0 {
+		return nil, fmt.Errorf("storage.ListUserVersions: пользователь с ID %d: %w", id, ErrNotFound)
+	}
+	return append([]models.UserVersion(nil), m.Versions[id]...), nil
+}
+
+func (m *MockUserStorage) GetUserVersion(ctx context.Context, id, version int64) (*models.UserVersion, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.GetUserVersion: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return nil, m.SimulateError
+	}
+	v, ok := m.version(id, version)
+	if !ok {
+		return nil, fmt.Errorf("storage.GetUserVersion: версия %d пользователя с ID %d: %w", version, id, ErrNotFound)
+	}
+	return &v, nil
+}
+
+func (m *MockUserStorage) RevertUser(ctx context.Context, id, version int64) (*models.User, error) {
+	if err := m.wait(ctx); err != nil {
+		return nil, fmt.Errorf("storage.RevertUser: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SimulateError != nil {
+		return nil, m.SimulateError
+	}
+	existing, exists := m.activeUser(id)
+	if !exists {
+		return nil, fmt.Errorf("storage.RevertUser: пользователь с ID %d: %w", id, ErrNotFound)
+	}
+	v, ok := m.version(id, version)
+	if !ok {
+		return nil, fmt.Errorf("storage.RevertUser: версия %d пользователя с ID %d: %w", version, id, ErrNotFound)
+	}
+	if m.emailTaken(v.Email, id) {
+		return nil, fmt.Errorf("мок: email '%s': %w", v.Email, &ConflictError{Field: "email"})
+	}
+	reverted := *existing
+	reverted.Name, reverted.Email = v.Name, v.Email
+	reverted.Version++
+	if err := m.audit(ctx, "storage.RevertUser", newAuditEntry(ctx, models.AuditActionRevert, existing, &reverted)); err != nil {
+		return nil, err
+	}
+	m.store(&reverted)
+	userCopy := reverted
+	return &userCopy, nil
+}
+
+// version ищет снимок версии пользователя. Вызывается под m.mu
+func (m *MockUserStorage) version(id, version int64) (models.UserVersion, bool) {
+	for _, v := range m.Versions[id] {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return models.UserVersion{}, false
+}
+
 // Вспомогательный метод для тестов, чтобы очищать мок между тестами
 func (m *MockUserStorage) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.Users = make(map[int64]*models.User)
+	m.Versions = make(map[int64][]models.UserVersion)
 	m.NextID = 1
 	m.SimulateError = nil
+	m.SimulateDelay = 0
+	if audit, ok := m.Audit.(*MockAuditStorage); ok {
+		audit.Reset()
+	}
 }
 
 // Вспомогательный метод для добавления пользователя напрямую в мок для настройки тестов
@@ -130,6 +563,12 @@ func (m *MockUserStorage) SeedUser(user models.User) models.User {
 	} else if user.ID >= m.NextID {
 		m.NextID = user.ID + 1
 	}
-	m.Users[user.ID] = &user
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	if user.Version == 0 {
+		user.Version = 1
+	}
+	m.store(&user)
 	return user
 }
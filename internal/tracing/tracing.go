@@ -0,0 +1,109 @@
+// Package tracing настраивает трассировку OpenTelemetry: экспорт спанов (OTLP, stdout или
+// отключен) и распространение контекста трассировки в заголовках W3C traceparent/tracestate.
+//
+// Спаны создаются через глобальный TracerProvider (otel.Tracer), поэтому пакеты, которые
+// их создают, не зависят от выбранного экспортера. Без Setup спаны не записываются, но
+// контекст трассировки из входящего traceparent все равно передается дальше.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортеры спанов
+const (
+	// ExporterOTLP отправляет спаны по OTLP/HTTP; адрес и заголовки задаются стандартными
+	// переменными OTEL_EXPORTER_OTLP_* (по умолчанию http://localhost:4318)
+	ExporterOTLP = "otlp"
+	// ExporterStdout пишет спаны в JSON в Config.Stdout; для локальной отладки
+	ExporterStdout = "stdout"
+	// ExporterNone отключает экспорт
+	ExporterNone = "none"
+)
+
+// DefaultServiceName — имя сервиса в спанах, если OTEL_SERVICE_NAME не задана
+const DefaultServiceName = "giperboreya-backend"
+
+// Config — настройки трассировки
+type Config struct {
+	// Exporter — ExporterOTLP, ExporterStdout или ExporterNone (по умолчанию)
+	Exporter string
+	// ServiceName — имя сервиса; OTEL_SERVICE_NAME и OTEL_RESOURCE_ATTRIBUTES имеют приоритет
+	ServiceName string
+	// Stdout — куда пишет ExporterStdout; nil — os.Stdout
+	Stdout io.Writer
+}
+
+// ShutdownFunc отправляет накопленные спаны и останавливает экспорт
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup устанавливает глобальные распространитель контекста (W3C Trace Context и Baggage)
+// и TracerProvider с выбранным экспортером. Возвращенную функцию нужно вызвать при
+// завершении приложения, чтобы не потерять последние спаны.
+func Setup(ctx context.Context, cfg Config) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.Exporter) {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout, "console":
+		stdout := cfg.Stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(stdout))
+	default:
+		return nil, fmt.Errorf("tracing.Setup: неизвестный экспортер '%s', ожидается %s, %s или %s",
+			cfg.Exporter, ExporterOTLP, ExporterStdout, ExporterNone)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing.Setup: не удалось создать экспортер %s: %w", cfg.Exporter, err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	// Переменные окружения OTEL_* объявлены последними и переопределяют имя из Config
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing.Setup: не удалось описать ресурс: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// TraceID возвращает ID трассировки из ctx или пустую строку, если трассировки нет
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetup(t *testing.T) {
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	t.Run("stdout пишет спаны при остановке", func(t *testing.T) {
+		var buf bytes.Buffer
+		shutdown, err := Setup(context.Background(), Config{Exporter: ExporterStdout, ServiceName: "test-service", Stdout: &buf})
+		if err != nil {
+			t.Fatalf("Setup: %v", err)
+		}
+		ctx, span := otel.Tracer("test").Start(context.Background(), "тестовый спан")
+		if TraceID(ctx) == "" || TraceID(ctx) != span.SpanContext().TraceID().String() {
+			t.Errorf("TraceID: получено '%s', ожидался ID трассировки спана", TraceID(ctx))
+		}
+		span.End()
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+		for _, want := range []string{"тестовый спан", "test-service"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("в выводе экспортера нет '%s': %s", want, buf.String())
+			}
+		}
+	})
+
+	t.Run("none распространяет traceparent без экспорта", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+		if err != nil {
+			t.Fatalf("Setup: %v", err)
+		}
+		defer shutdown(context.Background())
+
+		header := http.Header{}
+		header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+		if got := TraceID(ctx); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("TraceID из traceparent: получено '%s'", got)
+		}
+	})
+
+	t.Run("Неизвестный экспортер", func(t *testing.T) {
+		if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+			t.Error("Setup: ожидалась ошибка для неизвестного экспортера")
+		}
+	})
+
+	if got := TraceID(context.Background()); got != "" {
+		t.Errorf("TraceID без трассировки: ожидалась пустая строка, получено '%s'", got)
+	}
+}
@@ -0,0 +1,271 @@
+// Package validation проверяет структуры по тегам `validate`, например
+// `validate:"required,email,max=100"`, и возвращает список ошибок по полям.
+//
+// Встроенные правила: required, email, min=N, max=N (для строк — длина в символах,
+// для чисел — значение) и pattern=<регулярное выражение>. pattern должен быть
+// последним правилом тега: все после "pattern=", включая запятые, считается выражением.
+// Собственные правила добавляются через Validator.RegisterRule.
+//
+// Правила, кроме required, не проверяются для пустых значений: необязательное
+// пустое поле считается корректным.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError — нарушение одного правила одним полем
+type FieldError struct {
+	// Field — имя поля в JSON
+	Field string `json:"field"`
+	// Rule — имя нарушенного правила, например "required" или "max"
+	Rule string `json:"rule"`
+	// Param — параметр правила, например "100" для max=100
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors — все ошибки проверки структуры в порядке полей
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// RuleFunc проверяет значение поля; param — часть правила после "=" (может быть пустой).
+// Возвращает false, если значение нарушает правило.
+type RuleFunc func(value reflect.Value, param string) (bool, error)
+
+type rule struct {
+	check RuleFunc
+	// message — шаблон сообщения об ошибке, %s заменяется на параметр правила
+	message string
+}
+
+// Validator проверяет структуры по тегам validate. Безопасен для параллельного использования.
+type Validator struct {
+	mu    sync.RWMutex
+	rules map[string]rule
+	// fields кэширует разобранные теги по типу структуры
+	fields sync.Map
+}
+
+// New создает Validator со встроенными правилами
+func New() *Validator {
+	v := &Validator{rules: make(map[string]rule)}
+	v.RegisterRule("email", isEmail, "некорректный email")
+	v.RegisterRule("min", minRule, "должно быть не меньше %s")
+	v.RegisterRule("max", maxRule, "должно быть не больше %s")
+	v.RegisterRule("pattern", patternRule, "не соответствует формату")
+	return v
+}
+
+// Default используется функцией Struct
+var Default = New()
+
+// Struct проверяет структуру валидатором Default
+func Struct(s interface{}) error {
+	return Default.Struct(s)
+}
+
+// RegisterRule добавляет правило или заменяет существующее. message может содержать %s
+// для параметра правила. Правило required встроено и не заменяется.
+func (v *Validator) RegisterRule(name string, check RuleFunc, message string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.rules[name] = rule{check: check, message: message}
+}
+
+// fieldRules — разобранный тег одного поля
+type fieldRules struct {
+	index    int
+	name     string
+	required bool
+	rules    []ruleCall
+}
+
+type ruleCall struct {
+	name, param string
+}
+
+// Struct проверяет структуру (или указатель на нее). Возвращает Errors, если есть
+// нарушения, и обычную ошибку, если тег ссылается на неизвестное правило.
+func (v *Validator) Struct(s interface{}) error {
+	value := reflect.ValueOf(s)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return fmt.Errorf("validation: nil вместо структуры")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: ожидается структура, получено %s", value.Kind())
+	}
+
+	var errs Errors
+	for _, f := range v.typeRules(value.Type()) {
+		fieldValue := value.Field(f.index)
+		if isEmpty(fieldValue) {
+			if f.required {
+				errs = append(errs, FieldError{Field: f.name, Rule: "required", Message: "значение обязательно"})
+			}
+			continue
+		}
+		for _, call := range f.rules {
+			v.mu.RLock()
+			r, ok := v.rules[call.name]
+			v.mu.RUnlock()
+			if !ok {
+				return fmt.Errorf("validation: поле %s: неизвестное правило '%s'", f.name, call.name)
+			}
+			valid, err := r.check(fieldValue, call.param)
+			if err != nil {
+				return fmt.Errorf("validation: поле %s, правило %s: %w", f.name, call.name, err)
+			}
+			if !valid {
+				message := r.message
+				if strings.Contains(message, "%s") {
+					message = fmt.Sprintf(message, call.param)
+				}
+				errs = append(errs, FieldError{Field: f.name, Rule: call.name, Param: call.param, Message: message})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// typeRules разбирает теги validate структуры t и кэширует результат
+func (v *Validator) typeRules(t reflect.Type) []fieldRules {
+	if cached, ok := v.fields.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+	var result []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		f := fieldRules{index: i, name: jsonName(field)}
+		for tag != "" {
+			var part string
+			if strings.HasPrefix(tag, "pattern=") {
+				part, tag = tag, ""
+			} else {
+				part, tag, _ = strings.Cut(tag, ",")
+			}
+			name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "required" {
+				f.required = true
+				continue
+			}
+			f.rules = append(f.rules, ruleCall{name: name, param: param})
+		}
+		result = append(result, f)
+	}
+	v.fields.Store(t, result)
+	return result
+}
+
+// jsonName возвращает имя поля в JSON, чтобы ошибки ссылались на поля тела запроса
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// isEmpty проверяет, что значение нулевое; строки из одних пробелов считаются пустыми
+func isEmpty(value reflect.Value) bool {
+	if value.Kind() == reflect.String {
+		return strings.TrimSpace(value.String()) == ""
+	}
+	return value.IsZero()
+}
+
+func isEmail(value reflect.Value, _ string) (bool, error) {
+	if value.Kind() != reflect.String {
+		return false, fmt.Errorf("правило применимо только к строкам")
+	}
+	s := value.String()
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return false, nil
+	}
+	// mail.ParseAddress допускает адреса без точки в домене ("user@localhost")
+	_, domain, _ := strings.Cut(s, "@")
+	return strings.Contains(domain, ".") && !strings.HasSuffix(domain, "."), nil
+}
+
+// size возвращает длину строки в символах или значение числа
+func size(value reflect.Value) (float64, error) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len()), nil
+	default:
+		return 0, fmt.Errorf("правило неприменимо к типу %s", value.Kind())
+	}
+}
+
+func minRule(value reflect.Value, param string) (bool, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false, fmt.Errorf("некорректный параметр '%s'", param)
+	}
+	n, err := size(value)
+	return n >= limit, err
+}
+
+func maxRule(value reflect.Value, param string) (bool, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false, fmt.Errorf("некорректный параметр '%s'", param)
+	}
+	n, err := size(value)
+	return n <= limit, err
+}
+
+// patterns кэширует скомпилированные выражения правила pattern
+var patterns sync.Map
+
+func patternRule(value reflect.Value, param string) (bool, error) {
+	if value.Kind() != reflect.String {
+		return false, fmt.Errorf("правило применимо только к строкам")
+	}
+	re, ok := patterns.Load(param)
+	if !ok {
+		compiled, err := regexp.Compile(param)
+		if err != nil {
+			return false, fmt.Errorf("некорректное выражение '%s': %w", param, err)
+		}
+		re, _ = patterns.LoadOrStore(param, compiled)
+	}
+	return re.(*regexp.Regexp).MatchString(value.String()), nil
+}
@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type profile struct {
+	Name     string `json:"name" validate:"required,min=2,max=5"`
+	Email    string `json:"email,omitempty" validate:"email"`
+	Age      int    `json:"age" validate:"max=150"`
+	Code     string `json:"code" validate:"pattern=^[A-Z]{2,3}$"`
+	Nickname string `validate:"even"`
+	Ignored  string
+}
+
+func fieldRulesOf(err error) []string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	result := make([]string, len(errs))
+	for i, e := range errs {
+		result[i] = e.Field + ":" + e.Rule
+	}
+	return result
+}
+
+func TestStruct(t *testing.T) {
+	v := New()
+	v.RegisterRule("even", func(value reflect.Value, _ string) (bool, error) {
+		return len(value.String())%2 == 0, nil
+	}, "длина должна быть четной")
+
+	testCases := []struct {
+		name     string
+		input    interface{}
+		expected []string
+	}{
+		{"Корректная структура", profile{Name: "Анна", Email: "anna@example.com", Age: 30, Code: "RU", Nickname: "ab"}, nil},
+		{"Указатель на структуру", &profile{Name: "Анна"}, nil},
+		{"Пустые необязательные поля не проверяются", profile{Name: "Ян"}, nil},
+		{"Обязательное поле из пробелов", profile{Name: "   "}, []string{"name:required"}},
+		{"Длина в символах, а не в байтах", profile{Name: "Ярослав"}, []string{"name:max"}},
+		{"Несколько ошибок по порядку полей", profile{Name: "Я", Email: "not-an-email", Age: 200, Code: "ru", Nickname: "abc"},
+			[]string{"name:min", "email:email", "age:max", "code:pattern", "Nickname:even"}},
+		{"Email без домена верхнего уровня", profile{Name: "Анна", Email: "anna@localhost"}, []string{"email:email"}},
+		{"Email с отображаемым именем", profile{Name: "Анна", Email: "Anna <anna@example.com>"}, []string{"email:email"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.Struct(tc.input)
+			got := fieldRulesOf(err)
+			if tc.expected == nil {
+				if err != nil {
+					t.Fatalf("ожидалось отсутствие ошибок, получено %v", err)
+				}
+				return
+			}
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("ожидались ошибки %v, получено %v (%v)", tc.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestStructMessages(t *testing.T) {
+	err := New().Struct(struct {
+		Title string `json:"title" validate:"max=3"`
+	}{Title: "длинный"})
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("ожидалась одна ошибка, получено %v", err)
+	}
+	if errs[0].Param != "3" || errs[0].Message != "должно быть не больше 3" {
+		t.Errorf("неверная ошибка: %+v", errs[0])
+	}
+}
+
+func TestStructConfigurationErrors(t *testing.T) {
+	// Правило even не зарегистрировано в новом валидаторе
+	err := New().Struct(profile{Name: "Анна", Nickname: "ab"})
+	var errs Errors
+	if err == nil || errors.As(err, &errs) {
+		t.Errorf("для неизвестного правила ожидалась ошибка конфигурации, получено %v", err)
+	}
+	if err := New().Struct("не структура"); err == nil {
+		t.Errorf("для не-структуры ожидалась ошибка")
+	}
+}
+
+func TestPatternWithCommas(t *testing.T) {
+	type code struct {
+		Value string `json:"value" validate:"max=10,pattern=^[a-c]{1,2}$"`
+	}
+	v := New()
+	if err := v.Struct(code{Value: "ab"}); err != nil {
+		t.Errorf("значение соответствует выражению, получено %v", err)
+	}
+	if got := fieldRulesOf(v.Struct(code{Value: "abc"})); len(got) != 1 || got[0] != "value:pattern" {
+		t.Errorf("ожидалось нарушение pattern, получено %v", got)
+	}
+}
@@ -1,132 +1,456 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	_ "github.com/lib/pq"
 
+	"github.com/casanera/GiperboreyaTechnologies/internal/auth"
+	"github.com/casanera/GiperboreyaTechnologies/internal/config"
 	"github.com/casanera/GiperboreyaTechnologies/internal/handlers"
+	"github.com/casanera/GiperboreyaTechnologies/internal/health"
+	"github.com/casanera/GiperboreyaTechnologies/internal/logging"
+	"github.com/casanera/GiperboreyaTechnologies/internal/metrics"
+	"github.com/casanera/GiperboreyaTechnologies/internal/migrations"
+	"github.com/casanera/GiperboreyaTechnologies/internal/models"
+	"github.com/casanera/GiperboreyaTechnologies/internal/server"
 	"github.com/casanera/GiperboreyaTechnologies/internal/storage"
+	"github.com/casanera/GiperboreyaTechnologies/internal/tracing"
 )
 
 var db *sql.DB
 
-func routeHandler(userH *handlers.UserHandler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("API Запрос: Метод=%s, Путь=%s", r.Method, r.URL.Path)
-
-		pathRemainder := strings.TrimPrefix(r.URL.Path, "/api/v1/users")
-		isSpecificUserPath := pathRemainder != "" && pathRemainder != "/" // будет true для /1, /abc и т.д.
-
-		switch r.Method {
-		case http.MethodGet:
-			userH.GetUserHandler(w, r) // GetUserHandler должен сам разобрать путь
-		case http.MethodPost:
-			// POST только на /api/v1/users (т.е. pathRemainder должен быть "/" или "")
-			if !isSpecificUserPath || pathRemainder == "/" {
-				userH.CreateUserHandler(w, r)
-			} else {
-				http.Error(w, "Метод POST применим только к /api/v1/users", http.StatusMethodNotAllowed)
+// openDB подключается к PostgreSQL и ждет готовности базы, повторяя попытки
+// cfg.ConnectRetries раз с паузой cfg.ConnectRetryInterval
+func openDB(cfg config.DatabaseConfig) *sql.DB {
+	slog.Info("Подключение к PostgreSQL", "dsn", cfg.Redacted())
+	db, err := sql.Open("postgres", cfg.DSN())
+	if err != nil {
+		fatal("Ошибка при вызове sql.Open для PostgreSQL", "error", err)
+	}
+
+	for i := 0; i < cfg.ConnectRetries; i++ {
+		slog.Debug("Проверка соединения с БД", "attempt", i+1, "max_attempts", cfg.ConnectRetries)
+		err = db.Ping()
+		if err == nil {
+			slog.Info("Успешное подключение к PostgreSQL")
+			break
+		}
+		if i+1 < cfg.ConnectRetries {
+			slog.Warn("Не удалось подключиться к БД, повтор", "attempt", i+1, "retry_in", cfg.ConnectRetryInterval, "error", err)
+			time.Sleep(cfg.ConnectRetryInterval)
+		}
+	}
+	if err != nil {
+		fatal("Не удалось установить соединение с PostgreSQL", "attempts", cfg.ConnectRetries, "error", err)
+	}
+	return db
+}
+
+// runMigrateCommand реализует режим "migrate up|down [N]|status"
+func runMigrateCommand(db *sql.DB, args []string) error {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		return fmt.Errorf("использование: %s migrate up|down [N]|status", os.Args[0])
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		slog.Info("Миграции применены", "applied", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("некорректное количество шагов '%s': %w", args[1], err)
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		slog.Info("Миграции откачены", "reverted", len(reverted))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "ожидает"
+			if s.Applied {
+				state = "применена " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("неизвестная команда migrate '%s', ожидается up, down или status", args[0])
+	}
+	return nil
+}
+
+// runAPIKeyCommand реализует режим "apikey create NAME SCOPES [TTL]|list|revoke ID" — выпуск
+// первого ключа keys:admin и управление ключами без HTTP. SCOPES перечисляются через запятую,
+// TTL — срок действия ключа, например 720h; без него ключ бессрочный.
+func runAPIKeyCommand(db *sql.DB, args []string) error {
+	usage := fmt.Errorf("использование: %s apikey create NAME SCOPES [TTL]|list|revoke ID", os.Args[0])
+	if len(args) == 0 {
+		return usage
+	}
+	// Ключ можно выпустить до первого запуска сервера, поэтому схема приводится к актуальной
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if _, err := migrator.Up(ctx); err != nil {
+		return err
+	}
+	keys := storage.NewPostgresAPIKeyStorage(db)
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			return usage
+		}
+		key := models.APIKey{Name: args[1], Scopes: strings.Split(args[2], ",")}
+		for _, scope := range key.Scopes {
+			if !auth.KnownScope(scope) {
+				return fmt.Errorf("неизвестная область доступа '%s', ожидается одна из: %s", scope, strings.Join(auth.Scopes, ", "))
 			}
-		case http.MethodPut:
-			// PUT только на /api/v1/users/{id} (т.е. isSpecificUserPath должен быть true)
-			if isSpecificUserPath {
-				userH.UpdateUserHandler(w, r)
-			} else {
-				http.Error(w, "Для PUT запроса требуется ID пользователя в пути", http.StatusBadRequest)
+		}
+		if len(args) > 3 {
+			ttl, err := time.ParseDuration(args[3])
+			if err != nil || ttl <= 0 {
+				return fmt.Errorf("некорректный срок действия '%s', ожидается положительная длительность, например 720h", args[3])
 			}
-		case http.MethodDelete:
-			// DELETE только на /api/v1/users/{id}
-			if isSpecificUserPath {
-				userH.DeleteUserHandler(w, r)
-			} else {
-				http.Error(w, "Для DELETE запроса требуется ID пользователя в пути", http.StatusBadRequest)
+			expires := time.Now().Add(ttl)
+			key.ExpiresAt = &expires
+		}
+		secret, prefix, hash, err := auth.GenerateAPIKey()
+		if err != nil {
+			return err
+		}
+		key.Prefix, key.Hash = prefix, hash
+		if err := keys.CreateAPIKey(ctx, &key); err != nil {
+			return err
+		}
+		slog.Info("Выпущен ключ API", "key_id", key.ID, "key_name", key.Name, "scopes", key.Scopes)
+		// Ключ выводится один раз: в базе хранится только его хэш
+		fmt.Println(secret)
+	case "list":
+		list, err := keys.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, k := range list {
+			state := "действует"
+			switch {
+			case k.RevokedAt != nil:
+				state = "отозван " + k.RevokedAt.Format(time.RFC3339)
+			case !k.Active(now):
+				state = "истек " + k.ExpiresAt.Format(time.RFC3339)
 			}
-		default:
-			http.Error(w, "Метод не разрешен для данного API пути", http.StatusMethodNotAllowed)
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\n", k.ID, k.Prefix, k.Name, strings.Join(k.Scopes, ","), state)
+		}
+	case "revoke":
+		if len(args) < 2 {
+			return usage
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("некорректный ID ключа '%s': %w", args[1], err)
+		}
+		if _, err := keys.RevokeAPIKey(ctx, id); err != nil {
+			return err
 		}
+		slog.Info("Ключ API отозван", "key_id", id)
+	default:
+		return fmt.Errorf("неизвестная команда apikey '%s', ожидается create, list или revoke", args[0])
 	}
+	return nil
 }
 
-func main() {
-	log.Println("Запуск backend приложения с CRUD...")
+// setupAuth создает обработчик аутентификации по секции auth настроек.
+// Возвращает nil, если аутентификация отключена.
+func setupAuth(cfg config.AuthConfig, keys storage.APIKeyStorage, credentials storage.CredentialStorage, operationTimeout time.Duration) *handlers.AuthHandler {
+	if cfg.Disabled {
+		slog.Warn("Аутентификация отключена (AUTH_DISABLED): API доступен без ключей, только для разработки")
+		return nil
+	}
+	sessions, err := auth.NewSessionCodec([]byte(cfg.SessionSecret), cfg.SessionTTL)
+	if err != nil {
+		fatal("Некорректные настройки сессий", "error", err)
+	}
+	if cfg.SessionSecret == "" {
+		slog.Warn("AUTH_SESSION_SECRET не задан: сессии фронтенда подписываются случайным ключом и завершаются при перезапуске")
+	}
+	authenticator := auth.NewAuthenticator(keys, sessions)
+	if cfg.OIDCIssuer != "" {
+		roleScopes, err := auth.ParseRoleScopes(cfg.OIDCRoleScopes)
+		if err != nil {
+			fatal("Некорректное соответствие ролей OIDC", "error", err)
+		}
+		jwks := auth.NewJWKSCache(cfg.OIDCJWKSURL)
+		jwks.RefreshInterval = cfg.OIDCJWKSRefreshInterval
+		verifier := auth.NewJWTVerifier(jwks, cfg.OIDCIssuer, cfg.OIDCAudience)
+		verifier.ClockSkew = cfg.OIDCClockSkew
+		verifier.RolesClaim = cfg.OIDCRolesClaim
+		verifier.RoleScopes = roleScopes
+		authenticator.JWT = verifier
+		slog.Info("Включена проверка JWT провайдера OIDC", "issuer", cfg.OIDCIssuer,
+			"audience", cfg.OIDCAudience, "jwks_url", cfg.OIDCJWKSURL, "roles", len(roleScopes))
+	}
+	passwords := auth.NewPasswordAuth(credentials)
+	passwords.SessionTTL = cfg.UserSessionTTL
+	passwords.IdleTimeout = cfg.UserSessionIdleTimeout
+	passwords.MaxFailures = cfg.LoginMaxFailures
+	passwords.LockoutDuration = cfg.LockoutDuration
+	passwords.Throttle = nil
+	if cfg.LoginRateLimit > 0 {
+		passwords.Throttle = auth.NewThrottle(cfg.LoginRateLimit, cfg.LoginRateWindow)
+	}
+	authenticator.Passwords = passwords
+	slog.Info("Вход пользователей по паролю", "session_ttl", passwords.SessionTTL, "idle_timeout", passwords.IdleTimeout,
+		"max_failures", passwords.MaxFailures, "lockout", passwords.LockoutDuration, "rate_limit", cfg.LoginRateLimit)
 
-	dbHost := os.Getenv("DB_HOST") // Для Docker Compose это будет имя сервиса 'db'
-	dbPort := os.Getenv("DB_PORT")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
+	authHandler := handlers.NewAuthHandler(authenticator)
+	authHandler.CookieSecure = cfg.CookieSecure
+	authHandler.OperationTimeout = operationTimeout
+	return authHandler
+}
+
+// fatal пишет ошибку в журнал и завершает процесс
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
 
-	// Проверка обязательных переменных окружения
-	if dbHost == "" || dbPort == "" || dbUser == "" || dbPassword == "" || dbName == "" {
-		log.Fatalf("Одна или несколько переменных окружения для БД не установлены.")
+// loadConfig собирает настройки из файла, окружения и флагов (см. пакет config).
+// С флагом --print-config выводит итоговые настройки и завершает процесс.
+func loadConfig() (*config.Config, []string) {
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Print(config.Usage())
+		os.Exit(0)
 	}
+	if opts != nil && opts.PrintConfig {
+		if printErr := cfg.PrintConfig(os.Stdout); printErr != nil {
+			fmt.Fprintf(os.Stderr, "Не удалось вывести настройки: %v\n", printErr)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Некорректные настройки:\n%v\n\n%s", err, config.Usage())
+		os.Exit(2)
+	}
+	if opts.PrintConfig {
+		os.Exit(0)
+	}
+	return cfg, opts.Args
+}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// setupLogging настраивает журнал по секции log настроек. Журнал по умолчанию пакета slog
+// заменяется, поэтому и записи через пакет log попадают в тот же формат.
+func setupLogging(cfg config.LogConfig) {
+	level, err := logging.ParseLevel(cfg.Level)
+	if err != nil {
+		fatal("Некорректный уровень журнала", "error", err)
+	}
+	logger, err := logging.New(os.Stderr, logging.Config{Level: level, Format: cfg.Format, ShowPII: cfg.ShowPII})
+	if err != nil {
+		fatal("Некорректные настройки журнала", "error", err)
+	}
+	slog.SetDefault(logger)
+	if cfg.ShowPII {
+		slog.Warn("Маскирование персональных данных в журнале отключено (LOG_SHOW_PII), только для локальной отладки")
+	}
+}
 
-	log.Println("Попытка подключения к PostgreSQL...")
-	var err error
-	db, err = sql.Open("postgres", connStr)
+// setupTracing настраивает трассировку OpenTelemetry с экспортером exporter (otlp, stdout,
+// none). Остальные параметры — стандартные переменные OTEL_*, например
+// OTEL_EXPORTER_OTLP_ENDPOINT и OTEL_SERVICE_NAME.
+func setupTracing(exporter string) tracing.ShutdownFunc {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{Exporter: exporter})
 	if err != nil {
-		log.Fatalf("Ошибка при вызове sql.Open для PostgreSQL: %v", err)
+		fatal("Не удалось настроить трассировку", "error", err)
 	}
+	if exporter != "" && exporter != tracing.ExporterNone {
+		slog.Info("Трассировка включена", "exporter", exporter)
+	}
+	return shutdown
+}
 
-	maxRetries := 15
-	for i := 0; i < maxRetries; i++ {
-		log.Printf("Проверка соединения с БД (попытка %d/%d)...", i+1, maxRetries)
-		err = db.Ping()
-		if err == nil {
-			log.Println("Успешное подключение к PostgreSQL!")
-			break
+func main() {
+	cfg, args := loadConfig()
+	setupLogging(cfg.Log)
+
+	if len(args) > 0 && args[0] == "migrate" {
+		db := openDB(cfg.Database)
+		defer db.Close()
+		if err := runMigrateCommand(db, args[1:]); err != nil {
+			fatal("Ошибка миграции", "error", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "apikey" {
+		db := openDB(cfg.Database)
+		defer db.Close()
+		if err := runAPIKeyCommand(db, args[1:]); err != nil {
+			fatal("Ошибка команды apikey", "error", err)
 		}
-		log.Printf("Не удалось подключиться к БД: %v. Ожидание 5 секунд перед следующей попыткой...", err)
-		time.Sleep(5 * time.Second)
+		return
+	}
+
+	slog.Info("Запуск backend приложения с CRUD")
+
+	// SIGINT и SIGTERM (docker compose stop/restart) запускают корректную остановку
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing := setupTracing(cfg.Tracing.Exporter)
+
+	db = openDB(cfg.Database)
+
+	// Применение ожидающих миграций схемы
+	migrator, err := migrations.New(db)
+	if err != nil {
+		fatal("Не удалось загрузить миграции", "error", err)
 	}
+	applied, err := migrator.Up(context.Background())
 	if err != nil {
-		log.Fatalf("Не удалось установить соединение с PostgreSQL после %d попыток: %v. Завершение работы.", maxRetries, err)
+		fatal("Не удалось применить миграции", "error", err)
 	}
+	slog.Info("Схема БД актуальна", "applied", len(applied))
+
+	// Проверки готовности: БД доступна и схема актуальна
+	checker := health.NewChecker()
+	checker.Add("database", db.PingContext)
+	checker.Add("migrations", migrator.CheckApplied)
+
+	// Метрики Prometheus: пул соединений, операции хранилища и HTTP-запросы
+	metricsRegistry := metrics.NewRegistry()
+	metrics.RegisterDBStats(metricsRegistry, db.Stats)
 
 	// Инициализация хранилища
 	userStore := storage.NewPostgresUserStorage(db)
-
-	// Попытка создать таблицу users, если она не существует
-	if err := userStore.CreateUsersTableIfNotExists(); err != nil {
-		log.Fatalf("Не удалось создать/проверить таблицу пользователей: %v", err)
-	}
+	instrumentedStore := storage.NewInstrumentedUserStorage(userStore, metricsRegistry)
 
 	// Инициализация обработчика
-	userHandler := handlers.NewUserHandler(userStore)
+	userHandler := handlers.NewUserHandler(instrumentedStore)
+	userHandler.Audit = userStore.Audit
+	userHandler.OperationTimeout = cfg.Database.OperationTimeout
+	slog.Info("Таймаут операций с хранилищем", "timeout", userHandler.OperationTimeout)
 
-	// Настройка маршрутизатора
-	mux := http.NewServeMux()
+	// Ключи API, сессии фронтенда и вход пользователей по паролю. Без аутентификации
+	// управлять ключами и паролями через API незачем, а открытые маршруты /api/v1/keys
+	// позволили бы выпустить ключ кому угодно.
+	apiKeyStore := storage.NewPostgresAPIKeyStorage(db)
+	credentialStore := storage.NewPostgresCredentialStorage(db)
+	authHandler := setupAuth(cfg.Auth, apiKeyStore, credentialStore, userHandler.OperationTimeout)
+	var apiKeyHandler *handlers.APIKeyHandler
+	var sessionHandler *handlers.UserSessionHandler
+	if authHandler != nil {
+		apiKeyHandler = handlers.NewAPIKeyHandler(apiKeyStore)
+		apiKeyHandler.OperationTimeout = userHandler.OperationTimeout
+		sessionHandler = handlers.NewUserSessionHandler(authHandler.Authenticator.Passwords)
+		sessionHandler.CookieSecure = cfg.Auth.CookieSecure
+		sessionHandler.OperationTimeout = userHandler.OperationTimeout
+	}
 
-	// API маршруты
-	mux.HandleFunc("/api/v1/users/", routeHandler(userHandler)) // routeHandler уже есть выше
+	// Фоновая очистка корзины
+	purger := storage.NewTrashPurger(instrumentedStore)
+	purger.OperationTimeout = userHandler.OperationTimeout
+	purger.Retention = cfg.Trash.Retention
+	purger.Interval = cfg.Trash.PurgeInterval
+	slog.Info("Очистка корзины", "retention", purger.Retention, "interval", purger.Interval)
+	purgerDone := make(chan struct{})
+	go func() {
+		defer close(purgerDone)
+		purger.Run(ctx)
+	}()
 
-	// Раздача статических файлов для всех остальных путей
-	// Создаем обработчик для статических файлов из папки "static"
-	staticFileServer := http.FileServer(http.Dir("./static"))
-	// Все запросы, не начинающиеся с /api/, будут обработаны staticFileServer
-	// Если запрошен "/", FileServer автоматически попытается отдать "index.html" из "./static"
-	mux.Handle("/", staticFileServer)
+	// Маршруты API и раздача фронтенда из папки http.static_dir
+	handler := server.New(server.Config{
+		Users:        userHandler,
+		StaticDir:    cfg.HTTP.StaticDir,
+		MaxBodyBytes: cfg.HTTP.MaxBodyBytes,
+		Metrics:      metricsRegistry,
+		Health:       checker,
+		Auth:         authHandler,
+		APIKeys:      apiKeyHandler,
+		Sessions:     sessionHandler,
+	})
 
-	appPort := os.Getenv("APP_PORT")
-	if appPort == "" {
-		appPort = "8080"
+	appPort := strconv.Itoa(cfg.HTTP.Port)
+	srv := server.NewHTTPServer(":"+appPort, handler, server.Timeouts{
+		ReadHeader: cfg.HTTP.ReadHeaderTimeout,
+		Read:       cfg.HTTP.ReadTimeout,
+		Write:      cfg.HTTP.WriteTimeout,
+		Idle:       cfg.HTTP.IdleTimeout,
+	})
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		fatal("Ошибка при запуске HTTP-сервера", "error", err)
 	}
+	scheme := "http"
+	if cfg.HTTP.TLSEnabled() {
+		tlsConfig, reloader, err := server.NewTLSConfig(cfg.HTTP.TLSOptions())
+		if err != nil {
+			fatal("Некорректные настройки TLS", "error", err)
+		}
+		// Сертификаты перечитываются при изменении файлов, например после продления
+		go reloader.Watch(ctx, cfg.HTTP.TLSReloadInterval)
+		srv.TLSConfig = tlsConfig
+		ln = tls.NewListener(ln, tlsConfig)
+		scheme = "https"
+		slog.Info("HTTPS включен", "min_version", cfg.HTTP.TLSMinVersion, "client_auth", cfg.HTTP.TLSClientAuth, "not_after", reloader.NotAfter())
+	}
+	slog.Info("Сервер (с фронтендом) запускается", "addr", scheme+"://localhost:"+appPort, "api", "/api/v1/users")
 
-	log.Printf("Сервер (с фронтендом) запускается на http://localhost:%s", appPort)
-	log.Printf("API пользователей доступно по /api/v1/users")
-	log.Printf("Фронтенд доступен по адресу: http://localhost:%s/", appPort)
+	serveErr := server.Serve(ctx, srv, ln, server.ShutdownOptions{
+		OnDrain:    checker.StartDraining,
+		DrainDelay: cfg.HTTP.ShutdownDrainDelay,
+		Timeout:    cfg.HTTP.ShutdownTimeout,
+	})
+	if serveErr != nil {
+		slog.Error("Ошибка HTTP-сервера", "error", serveErr)
+	}
 
-	if err := http.ListenAndServe(":"+appPort, mux); err != nil {
-		log.Fatalf("Ошибка при запуске HTTP-сервера: %v", err)
+	// Запросы завершены: останавливаем фоновые задачи и только затем закрываем пул соединений
+	stop()
+	<-purgerDone
+	if err := db.Close(); err != nil {
+		slog.Error("Ошибка при закрытии соединений с БД", "error", err)
+	}
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("Ошибка при остановке экспорта трассировки", "error", err)
+	}
+	slog.Info("Приложение остановлено")
+	if serveErr != nil {
+		os.Exit(1)
 	}
 }
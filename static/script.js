@@ -1,7 +1,9 @@
 
 
 // URL нашего API
-const API_BASE_URL = '/api/v1/users/';
+const API_BASE_URL = '/api/v1/users';
+// Сессия фронтенда: ключ API обменивается на cookie, и сам ключ в браузере не хранится
+const AUTH_SESSION_URL = '/api/v1/auth/session';
 
 // Получаем ссылки на элементы DOM
 const userForm = document.getElementById('userForm');
@@ -10,35 +12,178 @@ const nameInput = document.getElementById('name');
 const emailInput = document.getElementById('email');
 const usersTableBody = document.getElementById('usersTableBody');
 const clearFormButton = document.getElementById('clearFormButton');
+const loginForm = document.getElementById('loginForm');
+const apiKeyInput = document.getElementById('apiKey');
+const loginError = document.getElementById('loginError');
+const appSection = document.getElementById('appSection');
+const sessionBar = document.getElementById('sessionBar');
+const sessionName = document.getElementById('sessionName');
+const logoutButton = document.getElementById('logoutButton');
 
 let isEditing = false; // Флаг, находимся ли мы в режиме редактирования
+let editingVersion = null; // Версия редактируемого пользователя, отправляется в If-Match
+let messages = {}; // Каталог сообщений интерфейса на выбранном языке, загружается с /api/v1/i18n/{lang}
 
 console.log("DEBUG_SCRIPT: Скрипт script.js загружен. Переменные DOM:", 
     { userForm, userIdInput, nameInput, emailInput, usersTableBody, clearFormButton }
 );
 
+// --- ЛОКАЛИЗАЦИЯ ---
+
+// Возвращает сообщение интерфейса по ключу каталога с подстановкой params ({name}).
+// fallback используется, пока каталог не загружен или если в нем нет ключа.
+function t(key, fallback, params = {}) {
+    const template = messages[key] || fallback;
+    return template.replace(/\{(\w+)\}/g, (match, name) => (name in params ? params[name] : match));
+}
+
+// Загружает каталог сообщений на языке браузера и переводит элементы с атрибутом data-i18n.
+// Сервер сам выбирает ближайший поддерживаемый язык (для "en-US" — "en").
+async function loadMessages() {
+    try {
+        const response = await fetch(`/api/v1/i18n/${encodeURIComponent(navigator.language || '')}`);
+        if (!response.ok) {
+            throw new Error(`HTTP ${response.status}`);
+        }
+        const catalog = await response.json();
+        messages = catalog.messages || {};
+        document.documentElement.lang = catalog.lang;
+        document.querySelectorAll('[data-i18n]').forEach(el => {
+            el.textContent = t(el.dataset.i18n, el.textContent);
+        });
+        console.log(`DEBUG_I18N: Загружен каталог сообщений '${catalog.lang}'`);
+    } catch (error) {
+        console.warn('ПРЕДУПРЕЖДЕНИЕ: Не удалось загрузить каталог сообщений, используются тексты по умолчанию:', error);
+    }
+}
+
+// --- СЕССИЯ ---
+
+// Показывает форму входа вместо списка пользователей. message — причина, например истекшая сессия
+function showLogin(message = '') {
+    console.log("DEBUG_AUTH: showLogin - Требуется вход", message);
+    appSection.style.display = 'none';
+    sessionBar.style.display = 'none';
+    loginForm.style.display = 'block';
+    loginError.textContent = message;
+    apiKeyInput.focus();
+}
+
+// Показывает список пользователей. session — ответ GET /api/v1/auth/session или null,
+// если аутентификация на сервере отключена
+function showApp(session) {
+    console.log("DEBUG_AUTH: showApp - Сессия:", session);
+    loginForm.style.display = 'none';
+    appSection.style.display = 'block';
+    if (session) {
+        sessionName.textContent = t('ui.signed_in_as', 'Вход выполнен: {name}', { name: session.name });
+        sessionBar.style.display = 'flex';
+    } else {
+        sessionBar.style.display = 'none';
+    }
+}
+
+// Проверяет ответ API на 401 (сессия истекла или ключ отозван) и показывает форму входа.
+// Возвращает true, если ответ обработан.
+function handleUnauthorized(response) {
+    if (response.status !== 401) {
+        return false;
+    }
+    console.warn("DEBUG_AUTH: API ответил 401, сессия недействительна");
+    showLogin(t('ui.session_expired', 'Сессия истекла или ключ отозван, войдите снова.'));
+    return true;
+}
+
+// Узнает, открыта ли сессия. 404 означает, что аутентификация на сервере отключена
+async function loadSession() {
+    try {
+        const response = await fetch(AUTH_SESSION_URL);
+        if (response.status === 404) {
+            showApp(null);
+            return true;
+        }
+        if (!response.ok) {
+            showLogin();
+            return false;
+        }
+        showApp(await response.json());
+        return true;
+    } catch (error) {
+        console.error('КРИТИЧЕСКАЯ ОШИБКА при проверке сессии (loadSession):', error);
+        showLogin(error.message);
+        return false;
+    }
+}
+
 // --- ФУНКЦИИ ДЛЯ ВЗАИМОДЕЙСТВИЯ С API ---
 
-// Функция для получения всех пользователей
+// Функция для получения всех пользователей (API отдает список постранично, проходим все страницы)
 async function fetchUsers() {
     console.log("DEBUG_API: fetchUsers - Начало вызова");
     try {
-        const response = await fetch(API_BASE_URL);
-        console.log("DEBUG_API: fetchUsers - Ответ от fetch:", response);
-        if (!response.ok) {
-            const errorText = await response.text(); // Попробуем получить текст ошибки
-            console.error(`DEBUG_API: fetchUsers - Ошибка HTTP: ${response.status} ${response.statusText}. Тело ошибки: ${errorText}`);
-            throw new Error(`Ошибка HTTP: ${response.status} ${response.statusText}. Сервер ответил: ${errorText}`);
-        }
-        const users = await response.json();
+        const users = [];
+        let cursor = '';
+        do {
+            const url = cursor ? `${API_BASE_URL}?cursor=${encodeURIComponent(cursor)}` : API_BASE_URL;
+            const response = await fetch(url);
+            console.log("DEBUG_API: fetchUsers - Ответ от fetch:", response);
+            if (handleUnauthorized(response)) {
+                return;
+            }
+            if (!response.ok) {
+                const errorText = await response.text(); // Попробуем получить текст ошибки
+                console.error(`DEBUG_API: fetchUsers - Ошибка HTTP: ${response.status} ${response.statusText}. Тело ошибки: ${errorText}`);
+                throw new Error(t('ui.http_error', 'Ошибка HTTP {status}: {reason}', { status: response.status, reason: errorText || response.statusText }));
+            }
+            const page = await response.json();
+            users.push(...(page.users || []));
+            cursor = page.next_cursor || '';
+        } while (cursor);
         console.log("DEBUG_API: fetchUsers - Получены пользователи:", users);
-        displayUsers(users || []);
+        displayUsers(users);
     } catch (error) {
         console.error('КРИТИЧЕСКАЯ ОШИБКА при загрузке пользователей (fetchUsers):', error);
-        usersTableBody.innerHTML = `<tr><td colspan="4" style="color:red; text-align:center;">Не удалось загрузить пользователей: ${error.message}</td></tr>`;
+        usersTableBody.innerHTML = '';
+        const cell = usersTableBody.insertRow().insertCell();
+        cell.colSpan = 4;
+        cell.style.color = 'red';
+        cell.style.textAlign = 'center';
+        cell.textContent = t('ui.load_failed', 'Не удалось загрузить пользователей: {reason}', { reason: error.message });
     }
 }
 
+// Показывает сообщения проверки под полями формы (ответ 422 содержит массив errors с полем и сообщением)
+function showFieldErrors(errors) {
+    clearFieldErrors();
+    errors.forEach(err => {
+        const target = userForm.querySelector(`.field-error[data-field="${err.field}"]`);
+        if (!target) {
+            console.warn(`DEBUG_DOM: showFieldErrors - Нет места для ошибки поля '${err.field}':`, err);
+            return;
+        }
+        target.textContent = target.textContent ? `${target.textContent}; ${err.message}` : err.message;
+    });
+}
+
+// Убирает сообщения проверки из формы
+function clearFieldErrors() {
+    userForm.querySelectorAll('.field-error').forEach(el => { el.textContent = ''; });
+}
+
+// Разбирает ответ 422: если в нем есть ошибки по полям, показывает их в форме и возвращает true
+async function handleValidationErrors(response) {
+    if (response.status !== 422) {
+        return false;
+    }
+    const errorData = await response.clone().json().catch(() => ({}));
+    if (!errorData.errors || errorData.errors.length === 0) {
+        return false;
+    }
+    console.warn("DEBUG_API: Данные не прошли проверку на сервере:", errorData.errors);
+    showFieldErrors(errorData.errors);
+    return true;
+}
+
 // Функция для создания пользователя
 async function createUser(user) {
     console.log("DEBUG_API: createUser - Начало вызова. Данные:", user);
@@ -51,44 +196,62 @@ async function createUser(user) {
             body: JSON.stringify(user),
         });
         console.log("DEBUG_API: createUser - Ответ от fetch:", response);
+        if (handleUnauthorized(response) || await handleValidationErrors(response)) {
+            return null;
+        }
         if (!response.ok) {
             const errorData = await response.json().catch(async () => ({ message: await response.text() || response.statusText }));
             console.error(`DEBUG_API: createUser - Ошибка HTTP ${response.status}:`, errorData);
-            throw new Error(`Ошибка HTTP ${response.status}: ${errorData.message || response.statusText}`);
+            throw new Error(t('ui.http_error', 'Ошибка HTTP {status}: {reason}', { status: response.status, reason: errorData.detail || errorData.message || response.statusText }));
         }
         const createdUser = await response.json();
         console.log("DEBUG_API: createUser - Пользователь создан:", createdUser);
         return createdUser;
     } catch (error) {
         console.error('КРИТИЧЕСКАЯ ОШИБКА при создании пользователя (createUser):', error);
-        alert(`Не удалось создать пользователя: ${error.message}`);
+        alert(t('ui.create_failed', 'Не удалось создать пользователя: {reason}', { reason: error.message }));
         return null;
     }
 }
 
-// Функция для обновления пользователя
-async function updateUser(id, user) {
-    console.log(`DEBUG_API: updateUser - Начало вызова. ID: ${id}, Данные:`, user);
+// Функция для обновления пользователя. version защищает от перезаписи чужих изменений (If-Match)
+async function updateUser(id, user, version) {
+    console.log(`DEBUG_API: updateUser - Начало вызова. ID: ${id}, версия: ${version}, Данные:`, user);
     try {
+        const headers = {
+            'Content-Type': 'application/json',
+        };
+        if (version) {
+            headers['If-Match'] = `"${version}"`;
+        }
         const response = await fetch(`${API_BASE_URL}/${id}`, {
             method: 'PUT',
-            headers: {
-                'Content-Type': 'application/json',
-            },
+            headers,
             body: JSON.stringify(user),
         });
         console.log("DEBUG_API: updateUser - Ответ от fetch:", response);
+        if (handleUnauthorized(response)) {
+            return null;
+        }
+        if (response.status === 412) {
+            console.warn(`DEBUG_API: updateUser - Пользователь ID ${id} был изменен другим администратором`);
+            throw new Error(t('ui.version_conflict', 'пользователь был изменен другим администратором. Список обновлен, проверьте данные и повторите изменение'));
+        }
+        if (await handleValidationErrors(response)) {
+            return null;
+        }
         if (!response.ok) {
             const errorData = await response.json().catch(async () => ({ message: await response.text() || response.statusText }));
             console.error(`DEBUG_API: updateUser - Ошибка HTTP ${response.status}:`, errorData);
-            throw new Error(`Ошибка HTTP ${response.status}: ${errorData.message || response.statusText}`);
+            throw new Error(t('ui.http_error', 'Ошибка HTTP {status}: {reason}', { status: response.status, reason: errorData.detail || errorData.message || response.statusText }));
         }
         const updatedUser = await response.json();
         console.log("DEBUG_API: updateUser - Пользователь обновлен:", updatedUser);
         return updatedUser;
     } catch (error) {
         console.error(`КРИТИЧЕСКАЯ ОШИБКА при обновлении пользователя ${id} (updateUser):`, error);
-        alert(`Не удалось обновить пользователя: ${error.message}`);
+        alert(t('ui.update_failed', 'Не удалось обновить пользователя: {reason}', { reason: error.message }));
+        await fetchUsers();
         return null;
     }
 }
@@ -101,6 +264,9 @@ async function deleteUser(id) {
             method: 'DELETE',
         });
         console.log("DEBUG_API: deleteUser - Ответ от fetch:", response);
+        if (handleUnauthorized(response)) {
+            return false;
+        }
         if (response.status === 204) {
             console.log(`DEBUG_API: deleteUser - Пользователь ID ${id} успешно удален (статус 204).`);
             return true; 
@@ -111,19 +277,19 @@ async function deleteUser(id) {
             if (errorText) {
                 try {
                     const errorData = JSON.parse(errorText); // Попытка распарсить как JSON
-                    errorMessage = errorData.error || errorData.message || errorText; // Ищем поле error или message
+                    errorMessage = errorData.detail || errorData.message || errorText; // Ошибки API приходят в формате problem+json с полем detail
                 } catch (e) {
                     errorMessage = errorText; // Если не JSON, используем как текст
                 }
             }
             console.error(`DEBUG_API: deleteUser - Ошибка HTTP ${response.status}: ${errorMessage}`);
-            throw new Error(`Ошибка HTTP ${response.status}: ${errorMessage}`);
+            throw new Error(t('ui.http_error', 'Ошибка HTTP {status}: {reason}', { status: response.status, reason: errorMessage }));
         }
         console.log(`DEBUG_API: deleteUser - Пользователь ID ${id} успешно удален (статус ${response.status}).`);
         return true; 
     } catch (error) {
         console.error(`КРИТИЧЕСКАЯ ОШИБКА при удалении пользователя ${id} (deleteUser):`, error);
-        alert(`Не удалось удалить пользователя: ${error.message}`);
+        alert(t('ui.delete_failed', 'Не удалось удалить пользователя: {reason}', { reason: error.message }));
         return false;
     }
 }
@@ -137,23 +303,34 @@ function displayUsers(users) {
 
     if (!users || users.length === 0) {
         console.log("DEBUG_DOM: displayUsers - Пользователи не найдены или массив пуст.");
-        usersTableBody.innerHTML = '<tr><td colspan="4">Пользователи не найдены.</td></tr>';
+        usersTableBody.innerHTML = `<tr><td colspan="4">${t('ui.no_users', 'Пользователи не найдены.')}</td></tr>`;
         return;
     }
 
     users.forEach(user => {
         const row = usersTableBody.insertRow();
-        // ПРЯМАЯ ВСТАВКА ДАННЫХ (без escapeHTML для отладки)
-        // ВНИМАНИЕ: В ПРОДАШЕНЕ ЗДЕСЬ ДОЛЖНО БЫТЬ ЭКРАНИРОВАНИЕ ДЛЯ БЕЗОПАСНОСТИ!
-        row.innerHTML = `
-            <td>${user.id}</td>
-            <td>${user.name}</td> 
-            <td>${user.email}</td>
-            <td class="actions">
-                <button class="edit-btn" data-id="${user.id}" data-name="${user.name}" data-email="${user.email}">Редактировать</button>
-                <button class="delete-btn" data-id="${user.id}">Удалить</button>
-            </td>
-        `;
+        // Имя и email задают пользователи, поэтому они выводятся только как текст
+        row.insertCell().textContent = user.id;
+        row.insertCell().textContent = user.name;
+        row.insertCell().textContent = user.email;
+
+        const actions = row.insertCell();
+        actions.className = 'actions';
+
+        const editButton = document.createElement('button');
+        editButton.className = 'edit-btn';
+        editButton.textContent = t('ui.edit', 'Редактировать');
+        editButton.dataset.id = user.id;
+        editButton.dataset.name = user.name;
+        editButton.dataset.email = user.email;
+        editButton.dataset.version = user.version;
+
+        const deleteButton = document.createElement('button');
+        deleteButton.className = 'delete-btn';
+        deleteButton.textContent = t('ui.delete', 'Удалить');
+        deleteButton.dataset.id = user.id;
+
+        actions.append(editButton, ' ', deleteButton);
     });
     console.log("DEBUG_DOM: displayUsers - Таблица обновлена.");
 }
@@ -172,7 +349,7 @@ if (userForm) {
         console.log(`DEBUG_EVENT: submit - Данные из формы: id='${id}', name='${name}', email='${email}', isEditing=${isEditing}`);
 
         if (!name || !email) {
-            alert('Имя и Email обязательны для заполнения.');
+            alert(t('ui.required_fields', 'Имя и Email обязательны для заполнения.'));
             console.log("DEBUG_EVENT: submit - Ошибка валидации: Пустые имя или email");
             return;
         }
@@ -183,7 +360,7 @@ if (userForm) {
         try {
             if (isEditing && id) {
                 console.log(`DEBUG_EVENT: submit - Шаг 2: Вызываем updateUser для ID ${id}`);
-                result = await updateUser(id, userData);
+                result = await updateUser(id, userData, editingVersion);
                 console.log("DEBUG_EVENT: submit - Результат updateUser:", result);
             } else {
                 console.log("DEBUG_EVENT: submit - Шаг 2: Вызываем createUser");
@@ -200,7 +377,7 @@ if (userForm) {
             }
         } catch (apiError) {
             console.error("КРИТИЧЕСКАЯ ОШИБКА при вызове API из обработчика формы 'submit':", apiError);
-            alert("Произошла неожиданная ошибка при отправке данных. Пожалуйста, проверьте консоль.");
+            alert(t('ui.unexpected_submit', 'Произошла неожиданная ошибка при отправке данных. Пожалуйста, проверьте консоль.'));
         }
     });
 } else {
@@ -223,9 +400,10 @@ if (usersTableBody) {
                 userIdInput.value = id;
                 nameInput.value = name; 
                 emailInput.value = email;
+                editingVersion = target.dataset.version;
                 isEditing = true;
                 clearFormButton.style.display = 'inline-block';
-                userForm.querySelector('button[type="submit"]').textContent = 'Обновить';
+                userForm.querySelector('button[type="submit"]').textContent = t('ui.update', 'Обновить');
                 nameInput.focus();
                 window.scrollTo({ top: 0, behavior: 'smooth' });
             }
@@ -235,7 +413,7 @@ if (usersTableBody) {
                 const id = target.dataset.id;
                 console.log(`DEBUG_EVENT: click - Удаление ID: ${id}`);
 
-                if (confirm(`Вы уверены, что хотите удалить пользователя с ID ${id}?`)) {
+                if (confirm(t('ui.confirm_delete', 'Вы уверены, что хотите удалить пользователя с ID {id}?', { id }))) {
                     console.log(`DEBUG_EVENT: click - Пользователь подтвердил удаление ID: ${id}. Вызываем deleteUser.`);
                     const success = await deleteUser(id);
                     console.log(`DEBUG_EVENT: click - Результат deleteUser для ID ${id}:`, success);
@@ -251,7 +429,7 @@ if (usersTableBody) {
             }
         } catch (handlerError) {
             console.error("КРИТИЧЕСКАЯ ОШИБКА в обработчике кликов по таблице:", handlerError);
-            alert("Произошла неожиданная ошибка при обработке действия. Пожалуйста, проверьте консоль.");
+            alert(t('ui.unexpected_action', 'Произошла неожиданная ошибка при обработке действия. Пожалуйста, проверьте консоль.'));
         }
     });
 } else {
@@ -269,26 +447,74 @@ if (clearFormButton) {
 }
 
 
+// Вход: ключ API обменивается на cookie сессии
+if (loginForm) {
+    loginForm.addEventListener('submit', async (event) => {
+        event.preventDefault();
+        loginError.textContent = '';
+        try {
+            const response = await fetch(AUTH_SESSION_URL, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ api_key: apiKeyInput.value.trim() }),
+            });
+            if (!response.ok) {
+                const errorData = await response.json().catch(() => ({}));
+                console.warn(`DEBUG_AUTH: Вход не выполнен, HTTP ${response.status}:`, errorData);
+                loginError.textContent = t('ui.login_failed', 'Ключ не принят: {reason}', { reason: errorData.detail || response.statusText });
+                return;
+            }
+            apiKeyInput.value = '';
+            showApp(await response.json());
+            await fetchUsers();
+        } catch (error) {
+            console.error('КРИТИЧЕСКАЯ ОШИБКА при входе:', error);
+            loginError.textContent = t('ui.login_failed', 'Ключ не принят: {reason}', { reason: error.message });
+        }
+    });
+}
+
+// Выход: сервер удаляет cookie сессии
+if (logoutButton) {
+    logoutButton.addEventListener('click', async () => {
+        try {
+            await fetch(AUTH_SESSION_URL, { method: 'DELETE' });
+        } catch (error) {
+            console.error('КРИТИЧЕСКАЯ ОШИБКА при выходе:', error);
+        }
+        resetForm();
+        usersTableBody.innerHTML = '';
+        showLogin();
+    });
+}
+
 // Функция для сброса формы и режима редактирования
 function resetForm() {
     console.log("DEBUG_FN: resetForm - Начало");
     if(userForm) userForm.reset();
     if(userIdInput) userIdInput.value = '';
     isEditing = false;
+    editingVersion = null;
+    clearFieldErrors();
     if(clearFormButton) clearFormButton.style.display = 'none';
-    if(userForm) userForm.querySelector('button[type="submit"]').textContent = 'Сохранить';
+    if(userForm) userForm.querySelector('button[type="submit"]').textContent = t('ui.save', 'Сохранить');
     console.log("DEBUG_FN: resetForm - Форма сброшена");
 }
 
 
 // --- ИНИЦИАЛИЗАЦИЯ ---
 // Загружаем пользователей при первой загрузке страницы
-document.addEventListener('DOMContentLoaded', () => {
+document.addEventListener('DOMContentLoaded', async () => {
     console.log("DEBUG_INIT: DOMContentLoaded - DOM полностью загружен и разобран.");
     // Проверяем, существуют ли ключевые элементы перед вызовом fetchUsers
     if (usersTableBody && userForm && nameInput && emailInput && userIdInput) {
-        console.log("DEBUG_INIT: Все ключевые DOM элементы найдены. Вызываем fetchUsers().");
-        fetchUsers();
+        console.log("DEBUG_INIT: Все ключевые DOM элементы найдены. Загружаем каталог сообщений и вызываем fetchUsers().");
+        await loadMessages();
+        if (await loadSession()) {
+            fetchUsers();
+        }
     } else {
         console.error("КРИТИЧЕСКАЯ ОШИБКА ПРИ ИНИЦИАЛИЗАЦИИ: Один или несколько ключевых DOM элементов не найдены! Не могу продолжить.");
         if (!usersTableBody) console.error("Ошибка: usersTableBody не найден.");